@@ -2,9 +2,11 @@ package agent
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/tmc/langchaingo/agents"
@@ -17,18 +19,44 @@ import (
 const (
 	defaultLlmModel = "gpt-4o-mini"
 	defaultMaxTurns = 10
-	contextPrompt   = "You are a helpful booking assistant for a dental clinic, helping clients book appointments. " +
-		"The clinic has multiple employees, each performing different services with different duration and prices." +
-		"You can use multiple tools.  Always use service and employee names, never ids." +
-		"Bookings can be made at multiple of 15 minutes, never anything else." +
+	// defaultBusinessPrompt introduces the business when the tenant doesn't
+	// provide its own prompt
+	defaultBusinessPrompt = "You are a helpful booking assistant for a dental clinic, helping clients book appointments. " +
+		"The clinic has multiple employees, each performing different services with different duration and prices."
+	// bookingRulesPrompt is shared by all the businesses, formatted with the
+	// slot granularity in minutes
+	bookingRulesPrompt = "You can use multiple tools.  Always use service and employee names, never ids." +
+		"Bookings can be made at multiple of %d minutes, never anything else." +
+		"Never guess when the business is open or closed, check the business calendar." +
+		"The business can have several branches, each employee works at one branch per day and not all services are offered everywhere." +
+		"If there is more than one branch, ask which one the client prefers unless it's clear from the chosen employee, and always tell the client the branch of the appointment." +
+		"When the client has no exact time in mind, search for the available slots and offer a few of them." +
 		"Clients can book appointments with one of them and they need to specify a service, a date and a time, a name and a phone number." +
 		"It's important to only answer relevant questions about the services provided, do not provide information about unrelated topics." +
-		"Ask the name and phone number as the final info if not already provided. Ask for confirmation before performing the final booking.\n\n" +
+		"Answer questions about what a service involves, how to prepare for it or the aftercare only with the details from getServiceDetails, never from general knowledge; if the clinic gives no details, say so." +
+		"Some services have an age restriction: ask the age of the client the appointment is for and give it to the booking tools, which reject clients outside the age range." +
+		"Ask the name and phone number as the final info if not already provided. Ask for confirmation before performing the final booking." +
+		"As soon as you know the client's phone number, look them up with getCustomer: welcome returning clients back by name and mention their last visit, and confirm the name they booked with instead of asking for it again." +
+		"Checking the availability holds the slot for the client for a few minutes, book it before the hold expires." +
+		"Some services require a deposit, paid online: mention it before booking, and after booking tell the client the amount and give them the payment link, explaining that the appointment is only confirmed once it is paid before the deadline." +
+		"Appointments can also be booked as a recurring series, e.g. every 6 months, which is booked and cancelled as a whole." +
+		"When a client wants several services in one visit, find back-to-back times with findBundleSlots and book them together with bookBundle." +
+		"When a client books for several people at once, e.g. themselves and their children, ask the name and service of each one, find times with findGroupSlots and book them all with bookGroup under the client's name and phone number." +
+		"After a booking with followUpInMonths, offer the client to book the next appointment too, finding times with getFollowUpSlots." +
+		"If no suitable time is free, offer to add the client to the waitlist: when a slot frees up it is held for them for a limited time and they are notified." +
+		"When a client comes back about a waitlist offer, look it up with getMyWaitlist and claim it after they confirm." +
+		"Clients can cancel or reschedule an appointment using their booking number and the phone number used when booking." +
+		"If they don't remember their appointments or booking number, look them up by phone number.\n\n" +
 		"{{.tool_descriptions}}"
 )
 
 type AgentFactory interface {
 	CreateAgent() (Agent, error)
+	// Reload calls apply, which changes what the agents work with, e.g. the
+	// catalog, and replaces the business prompt of the agents created from now
+	// on, in one step: no agent is created in between. The running agents keep
+	// their prompt, and so do the new ones if apply fails.
+	Reload(businessPrompt string, apply func() error) error
 }
 
 type Agent interface {
@@ -42,16 +70,27 @@ type agentConfig struct {
 }
 
 type openAIAgentFactory struct {
-	llm         *openai.LLM
-	agentTools  []langchaintools.Tool
-	agentConfig *agentConfig
+	llm        *openai.LLM
+	agentTools []langchaintools.Tool
+	// mu guards the prompt, which can be reloaded while agents are created
+	mu       sync.RWMutex
+	prompt   string
+	timezone *time.Location
+	// slotGranularity is what the booking times are multiples of
+	slotGranularity time.Duration
+	agentConfig     *agentConfig
 }
 
 type openAIAgent struct {
-	executor *agents.Executor
+	executor  *agents.Executor
+	sessionID string
 }
 
-func NewOpenaiAgentFactory(agentTools []langchaintools.Tool, debugMode bool) AgentFactory {
+// NewOpenaiAgentFactory creates agents using the tools of a business.
+// businessPrompt introduces the business to the LLM, an empty one uses the
+// dental clinic default. The current time is given to the LLM in the business
+// timezone, and the booking times are multiples of slotGranularity.
+func NewOpenaiAgentFactory(agentTools []langchaintools.Tool, businessPrompt string, timezone *time.Location, slotGranularity time.Duration, debugMode bool) AgentFactory {
 	openAIKey := os.Getenv("OPENAI_API_KEY")
 	if openAIKey == "" {
 		log.Fatalf("OPENAI_API_KEY is required")
@@ -77,23 +116,52 @@ func NewOpenaiAgentFactory(agentTools []langchaintools.Tool, debugMode bool) Age
 		}
 	}
 
-	return &openAIAgentFactory{
-		llm:        llm,
-		agentTools: agentTools,
+	factory := &openAIAgentFactory{
+		llm:             llm,
+		agentTools:      agentTools,
+		timezone:        timezone,
+		slotGranularity: slotGranularity,
 		agentConfig: &agentConfig{
 			llmModel:  llmModel,
 			maxTurns:  maxTurns,
 			debugMode: debugMode,
 		},
 	}
+	factory.prompt = factory.fullPrompt(businessPrompt)
+	return factory
+}
+
+// fullPrompt returns the prompt of the agents of the business, with the
+// booking rules.
+func (f *openAIAgentFactory) fullPrompt(businessPrompt string) string {
+	if businessPrompt == "" {
+		businessPrompt = defaultBusinessPrompt
+	}
+	return businessPrompt + " " + fmt.Sprintf(bookingRulesPrompt, int(f.slotGranularity/time.Minute))
+}
+
+func (f *openAIAgentFactory) Reload(businessPrompt string, apply func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := apply(); err != nil {
+		return err
+	}
+	f.prompt = f.fullPrompt(businessPrompt)
+	return nil
 }
 
 func (f *openAIAgentFactory) CreateAgent() (Agent, error) {
+	f.mu.RLock()
+	prompt := f.prompt
+	f.mu.RUnlock()
+
 	memory := memory.NewConversationBuffer()
 
 	agent := agents.NewConversationalAgent(f.llm,
 		f.agentTools,
-		agents.WithPromptPrefix(contextPrompt+"\n\nCurrent time is "+time.Now().Format("2006-01-02 15:04:05, Monday")),
+		agents.WithPromptPrefix(prompt+"\n\nCurrent time is "+time.Now().In(f.timezone).Format("2006-01-02 15:04:05, Monday")+
+			" in the "+f.timezone.String()+" time zone, all the dates and times are in this time zone except at branches with their own time zone, where they are in the branch time zone."),
 		agents.WithMemory(memory),
 	)
 
@@ -103,10 +171,11 @@ func (f *openAIAgentFactory) CreateAgent() (Agent, error) {
 	)
 
 	return &openAIAgent{
-		executor: executor,
+		executor:  executor,
+		sessionID: newSessionID(),
 	}, nil
 }
 
 func (a *openAIAgent) GetCompletion(prompt string) (string, error) {
-	return chains.Run(context.Background(), a.executor, prompt)
+	return chains.Run(withSession(context.Background(), a.sessionID), a.executor, prompt)
 }
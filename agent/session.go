@@ -0,0 +1,29 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type sessionKey struct{}
+
+// newSessionID returns a random identifier for a conversation.
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withSession returns a copy of ctx carrying the session id, the tools use it
+// to tell apart the slots held by the current conversation.
+func withSession(ctx context.Context, sessionId string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sessionId)
+}
+
+// sessionFromContext returns the session id carried by ctx, or an empty
+// string if there is none.
+func sessionFromContext(ctx context.Context) string {
+	sessionId, _ := ctx.Value(sessionKey{}).(string)
+	return sessionId
+}
@@ -3,10 +3,16 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"valighita/bookings-ai-agent/payments"
 	"valighita/bookings-ai-agent/repository"
+	"valighita/bookings-ai-agent/waitlist"
 
 	langchaintools "github.com/tmc/langchaingo/tools"
 )
@@ -36,7 +42,8 @@ func (t *getServicesTool) Name() string {
 }
 
 func (t *getServicesTool) Description() string {
-	return "Get the list of services and their details (duration and price) offered by business."
+	return "Get the list of services and their details offered by business: category, description, duration, price, " +
+		"deposit (paid online when booking, 0 if none), preparation and aftercare instructions and age restriction (MinAge and MaxAge in years, 0 means no limit)."
 }
 
 func (t *getServicesTool) Call(ctx context.Context, input string) (string, error) {
@@ -45,6 +52,103 @@ func (t *getServicesTool) Call(ctx context.Context, input string) (string, error
 	return makeResult(services, "Failed to get services", err), nil
 }
 
+type getServiceDetailsTool struct {
+	servicesRepository  repository.ServiceRepository
+	employeesRepository repository.EmployeeRepository
+	locationsRepository repository.LocationRepository
+	logFunc             func(format string, v ...interface{})
+}
+
+type serviceDetails struct {
+	Name        string  `json:"name"`
+	Category    string  `json:"category,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Duration    uint    `json:"duration"`
+	Price       float64 `json:"price"`
+	// Deposit is paid online when booking
+	Deposit     float64 `json:"deposit,omitempty"`
+	Preparation string  `json:"preparation,omitempty"`
+	Aftercare   string  `json:"aftercare,omitempty"`
+	MinAge      uint    `json:"minAge,omitempty"`
+	MaxAge      uint    `json:"maxAge,omitempty"`
+	// AllowedForAge is set when the age of the client was given
+	AllowedForAge    *bool    `json:"allowedForAge,omitempty"`
+	FollowUpInMonths uint     `json:"followUpInMonths,omitempty"`
+	Employees        []string `json:"employees"`
+	// Branches offering the service, empty when all of them do
+	Branches []string `json:"branches,omitempty"`
+}
+
+func (t *getServiceDetailsTool) Name() string {
+	return "getServiceDetails"
+}
+
+func (t *getServiceDetailsTool) Description() string {
+	return "Get everything the clinic says about a service: what it involves, how to prepare for it, the aftercare, " +
+		"the age restriction, the employees performing it and the branches offering it." +
+		"Input is a JSON object with the following string fields: service, age. service is required." +
+		"age is optional, the age in years of the client the appointment is for: allowedForAge tells if they can have the service." +
+		"Duration is in minutes, minAge and maxAge are in years. deposit is the part of the price paid online when booking, if any."
+}
+
+func (t *getServiceDetailsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("getServiceDetails called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	service, err := t.servicesRepository.GetServiceByName(inputMap["service"])
+	if err != nil || service == nil {
+		return makeResult(nil, "service not found", err), nil
+	}
+
+	details := serviceDetails{
+		Name:             service.Name,
+		Category:         service.Category,
+		Description:      service.Description,
+		Duration:         service.Duration,
+		Price:            service.Price,
+		Deposit:          service.Deposit,
+		Preparation:      service.Preparation,
+		Aftercare:        service.Aftercare,
+		MinAge:           service.MinAge,
+		MaxAge:           service.MaxAge,
+		FollowUpInMonths: service.RecallMonths,
+		Employees:        []string{},
+	}
+
+	if ageArg := inputMap["age"]; ageArg != "" {
+		age, err := strconv.ParseUint(ageArg, 10, 0)
+		if err != nil {
+			return makeResult(nil, "invalid age argument, it must be a number of years", err), nil
+		}
+		allowed := service.AllowsAge(uint(age))
+		details.AllowedForAge = &allowed
+	}
+
+	employees, err := t.employeesRepository.GetEmployeesForServiceId(service.ID)
+	if err != nil {
+		return makeResult(nil, "Failed to get the service details", err), nil
+	}
+	for _, employee := range employees {
+		details.Employees = append(details.Employees, employee.Name)
+	}
+	slices.Sort(details.Employees)
+
+	for _, locationId := range service.LocationsIds {
+		location, err := getLocationName(t.locationsRepository, locationId)
+		if err != nil {
+			return makeResult(nil, "Failed to get the service details", err), nil
+		}
+		details.Branches = append(details.Branches, location)
+	}
+
+	return makeResult(details, "Failed to get the service details", nil), nil
+}
+
 type getEmployeesTool struct {
 	employeesRepository repository.EmployeeRepository
 	logFunc             func(format string, v ...interface{})
@@ -55,7 +159,7 @@ func (t *getEmployeesTool) Name() string {
 }
 
 func (t *getEmployeesTool) Description() string {
-	return "Get the list of employees and the services they offer."
+	return "Get the list of employees, the services they offer and their weekly working hours."
 }
 
 func (t *getEmployeesTool) Call(ctx context.Context, input string) (string, error) {
@@ -140,6 +244,100 @@ func (t *getEmployeesForServiceTool) Call(ctx context.Context, input string) (st
 	return makeResult(employees, "Failed to get employees for service", err), nil
 }
 
+type getLocationsTool struct {
+	locationsRepository repository.LocationRepository
+	logFunc             func(format string, v ...interface{})
+}
+
+func (t *getLocationsTool) Name() string {
+	return "getLocations"
+}
+
+func (t *getLocationsTool) Description() string {
+	return "Get the list of branches of the clinic with their address, time zone and opening hours." +
+		"Branches without opening hours are open during the clinic opening hours."
+}
+
+func (t *getLocationsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("getLocations called with ctx=%v ; input=%v\n", ctx, input)
+	locations, err := t.locationsRepository.GetLocations()
+	return makeResult(locations, "Failed to get locations", err), nil
+}
+
+type getClinicCalendarTool struct {
+	calendarRepository repository.CalendarRepository
+	timezone           *time.Location
+	logFunc            func(format string, v ...interface{})
+}
+
+type closure struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+func (t *getClinicCalendarTool) Name() string {
+	return "getClinicCalendar"
+}
+
+func (t *getClinicCalendarTool) Description() string {
+	return "Get the opening hours of the clinic and the days it is closed (public holidays and other closures) with the reason." +
+		"Input is a JSON object with the following optional fields: from, to." +
+		"The dates should be in the format YYYY-MM-DD, by default the next 30 days are returned."
+}
+
+func (t *getClinicCalendarTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("getClinicCalendar called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	if err := json.Unmarshal([]byte(input), &inputMap); err != nil {
+		inputMap = map[string]string{}
+	}
+
+	from := repository.StartOfDay(time.Now(), t.timezone)
+	if fromArg := inputMap["from"]; fromArg != "" {
+		var err error
+		from, err = time.ParseInLocation("2006-01-02", fromArg, t.timezone)
+		if err != nil {
+			return makeResult(nil, "invalid from argument", err), nil
+		}
+	}
+	to := from.AddDate(0, 0, 30)
+	if toArg := inputMap["to"]; toArg != "" {
+		parsedTo, err := time.ParseInLocation("2006-01-02", toArg, t.timezone)
+		if err != nil {
+			return makeResult(nil, "invalid to argument", err), nil
+		}
+		to = parsedTo.AddDate(0, 0, 1)
+	}
+
+	openingHours, err := t.calendarRepository.GetOpeningHours()
+	if err != nil {
+		return makeResult(nil, "Failed to get opening hours", err), nil
+	}
+	closures, err := t.calendarRepository.GetClosures(from, to)
+	if err != nil {
+		return makeResult(nil, "Failed to get closures", err), nil
+	}
+
+	result := struct {
+		OpeningHours repository.WeeklySchedule `json:"openingHours"`
+		Closures     []closure                 `json:"closures"`
+	}{
+		OpeningHours: openingHours,
+		Closures:     make([]closure, 0, len(closures)),
+	}
+	for _, c := range closures {
+		result.Closures = append(result.Closures, closure{
+			From:   c.Start.In(t.timezone).Format("2006-01-02 15:04"),
+			To:     c.End.In(t.timezone).Format("2006-01-02 15:04"),
+			Reason: c.Reason,
+		})
+	}
+
+	return makeResult(result, "Failed to get clinic calendar", nil), nil
+}
+
 //		Function: func(args map[string]interface{}, contextVariables map[string]interface{}) langchaingo.Result {
 //			debugPrintf("checkAvailability called with args=%v ; contextVars=%v\n", args, contextVariables)
 //
@@ -148,6 +346,10 @@ func (t *getEmployeesForServiceTool) Call(ctx context.Context, input string) (st
 type checkAvailabilityTool struct {
 	employeesRepository repository.EmployeeRepository
 	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	holdTTL             time.Duration
+	timezone            *time.Location
 	logFunc             func(format string, v ...interface{})
 }
 
@@ -157,8 +359,10 @@ func (t *checkAvailabilityTool) Name() string {
 
 func (t *checkAvailabilityTool) Description() string {
 	return "Check if an employee is available for a booking at a given time and date." +
-		"Input is a JSON object with the following fields: employee, service, date, time." +
-		"All fields are required and the date and time should be in the format YYYY-MM-DD and HH:MM"
+		"Input is a JSON object with the following fields: employee, service, date, time, location." +
+		"The date and time should be in the format YYYY-MM-DD and HH:MM" +
+		"location is the branch name, optional: by default the branch where the employee works that day, returned in the result." +
+		"If available, the slot is held for the client until heldUntil, replacing any slot held before."
 }
 
 func (t *checkAvailabilityTool) Call(ctx context.Context, input string) (string, error) {
@@ -210,17 +414,208 @@ func (t *checkAvailabilityTool) Call(ctx context.Context, input string) (string,
 	if !ok {
 		return makeResult(nil, "invalid time argument", fmt.Errorf("time is not a string")), nil
 	}
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+
+	// The client only needs one slot at a time, release the previous hold so
+	// it doesn't block the slot being checked or other clients.
+	sessionId := sessionFromContext(ctx)
+	if err := t.bookingsRepository.ReleaseSessionHolds(sessionId); err != nil {
+		return makeResult(nil, "Failed to check availability", err), nil
+	}
 
 	t.logFunc("checking availability for employeeId: %d serviceId: %d date: %s time: %s",
 		employee.ID, service.ID, date, time)
-	available, err := t.employeesRepository.CheckAvailability(employee.ID, service.ID, date, time)
-	return makeResult(available, "Failed to check availability", err), nil
+	available, err := t.employeesRepository.CheckAvailability(employee.ID, service.ID, locationId, date, time)
+	if err != nil || !available || sessionId == "" {
+		return makeResult(map[string]bool{"available": available}, "Failed to check availability", err), nil
+	}
+
+	hold, err := t.holdSlot(sessionId, employee, service, locationId, date, time)
+	if errors.Is(err, repository.ErrSlotNotAvailable) {
+		return makeResult(map[string]bool{"available": false}, "Failed to check availability", nil), nil
+	}
+	if err != nil {
+		return makeResult(nil, "Failed to hold the slot", err), nil
+	}
+
+	location, err := getLocationName(t.locationsRepository, hold.Booking.LocationID)
+	return makeResult(map[string]any{
+		"available": true,
+		"location":  location,
+		"heldUntil": hold.ExpiresAt.In(t.timezone).Format("2006-01-02 15:04"),
+	}, "Failed to check availability", err), nil
+}
+
+func (t *checkAvailabilityTool) holdSlot(sessionId string, employee *repository.Employee, service *repository.Service, locationId uint, date string, timeOfDay string) (*repository.Hold, error) {
+	dateTime, locationId, err := parseBranchTime(t.locationsRepository, t.timezone, employee, locationId, date, timeOfDay)
+	if err != nil {
+		return nil, err
+	}
+
+	hold := &repository.Hold{
+		SessionID: sessionId,
+		ExpiresAt: time.Now().Add(t.holdTTL),
+		Booking: repository.Booking{
+			EmployeeID:      employee.ID,
+			ServiceID:       service.ID,
+			LocationID:      locationId,
+			BookingDateTime: dateTime,
+		},
+	}
+	hold.Booking.SetServiceSnapshot(service)
+
+	return hold, t.bookingsRepository.HoldSlot(hold)
+}
+
+type findAvailableSlotsTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	locationsRepository repository.LocationRepository
+	timezone            *time.Location
+	logFunc             func(format string, v ...interface{})
+}
+
+const (
+	defaultSlotsLimit = 5
+	maxSlotsLimit     = 20
+	defaultSlotsDays  = 7
+)
+
+type slot struct {
+	Employee string `json:"employee"`
+	Location string `json:"location,omitempty"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+}
+
+func (t *findAvailableSlotsTool) Name() string {
+	return "findAvailableSlots"
+}
+
+func (t *findAvailableSlotsTool) Description() string {
+	return "Find the earliest free times for a service, optionally with a specific employee." +
+		"Input is a JSON object with the following string fields: service, employee, location, from, to, limit." +
+		"service is required, employee is optional and all employees offering the service are searched if missing." +
+		"location is the branch name, optional and all branches are searched if missing." +
+		"from and to are optional dates in the format YYYY-MM-DD, by default the search starts today and spans 7 days." +
+		"limit is the maximum number of results, 5 by default." +
+		"Use it to offer the client a few concrete options instead of checking times one by one."
+}
+
+func (t *findAvailableSlotsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("findAvailableSlots called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	serviceArg, ok := inputMap["service"]
+	if !ok || serviceArg == "" {
+		return makeResult(nil, "invalid service argument", fmt.Errorf("service is not a string")), nil
+	}
+	service, err := t.servicesRepository.GetServiceByName(serviceArg)
+	if err != nil || service == nil {
+		return makeResult(nil, "service not found", err), nil
+	}
+
+	var employeeId uint
+	if employeeArg := inputMap["employee"]; employeeArg != "" {
+		employee, err := t.employeesRepository.GetEmployeeByName(employeeArg)
+		if err != nil || employee == nil {
+			return makeResult(nil, "employee not found", err), nil
+		}
+		employeeId = employee.ID
+	}
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+
+	from, to, limit, result := getSlotsSearch(inputMap, t.timezone)
+	if result != "" {
+		return result, nil
+	}
+
+	slots, err := t.employeesRepository.FindAvailableSlots(service.ID, locationId, from, to, employeeId, limit)
+	if err != nil {
+		return makeResult(nil, "Failed to find available slots", err), nil
+	}
+
+	freeSlots, err := toSlots(t.employeesRepository, t.locationsRepository, t.timezone, slots)
+	return makeResult(freeSlots, "Failed to find available slots", err), nil
+}
+
+// getSlotsSearch parses the from, to and limit fields of the tool input, with
+// the dates in the business time zone. to is exclusive. On failure the
+// returned string is the tool result to send back.
+func getSlotsSearch(inputMap map[string]string, timezone *time.Location) (time.Time, time.Time, int, string) {
+	var err error
+	from := repository.StartOfDay(time.Now(), timezone)
+	if fromArg := inputMap["from"]; fromArg != "" {
+		from, err = time.ParseInLocation("2006-01-02", fromArg, timezone)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, makeResult(nil, "invalid from argument", err)
+		}
+	}
+	to := from.AddDate(0, 0, defaultSlotsDays)
+	if toArg := inputMap["to"]; toArg != "" {
+		parsedTo, err := time.ParseInLocation("2006-01-02", toArg, timezone)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, makeResult(nil, "invalid to argument", err)
+		}
+		to = parsedTo.AddDate(0, 0, 1)
+	}
+
+	limit := defaultSlotsLimit
+	if limitArg := inputMap["limit"]; limitArg != "" {
+		limit, err = strconv.Atoi(limitArg)
+		if err != nil || limit <= 0 {
+			return time.Time{}, time.Time{}, 0, makeResult(nil, "invalid limit argument", fmt.Errorf("limit is not a positive integer"))
+		}
+		limit = min(limit, maxSlotsLimit)
+	}
+
+	return from, to, limit, ""
+}
+
+// toSlots converts the slots to the tool output, with employee and branch
+// names and the times in the time zone of the branch.
+func toSlots(employeesRepository repository.EmployeeRepository, locationsRepository repository.LocationRepository, timezone *time.Location, slots []*repository.Slot) ([]slot, error) {
+	freeSlots := make([]slot, 0, len(slots))
+	for _, s := range slots {
+		employee, err := employeesRepository.GetEmployeeById(s.EmployeeID)
+		if err != nil {
+			return nil, err
+		}
+		location, err := getLocationName(locationsRepository, s.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		start := branchTime(locationsRepository, timezone, s.LocationID, s.Start)
+		freeSlots = append(freeSlots, slot{
+			Employee: employee.Name,
+			Location: location,
+			Date:     start.Format("2006-01-02"),
+			Time:     start.Format("15:04"),
+		})
+	}
+
+	return freeSlots, nil
 }
 
 type bookAppointmentTool struct {
 	employeesRepository repository.EmployeeRepository
 	servicesRepository  repository.ServiceRepository
 	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	payments            *payments.Payments
+	timezone            *time.Location
+	phoneCountry        string
 	logFunc             func(format string, v ...interface{})
 }
 
@@ -230,10 +625,23 @@ func (t *bookAppointmentTool) Name() string {
 
 func (t *bookAppointmentTool) Description() string {
 	return "Book an appointment with an employee for a specific service, date, and time." +
-		"Input is a JSON object with the following fields: employee, service, date, time, name, phone." +
-		"All fields are required and the date and time should be in the format YYYY-MM-DD and HH:MM"
+		"Input is a JSON object with the following fields: employee, service, date, time, name, phone, location, age." +
+		"All fields are required except location and age, and the date and time should be in the format YYYY-MM-DD and HH:MM" +
+		"location is the branch name, by default the branch where the employee works that day." +
+		ageDescription +
+		"Returns the booking number, give it to the client as they need it to cancel or reschedule." +
+		"followUpInMonths is set when the client should come back for the service, e.g. for the next cleaning." +
+		depositDescription
 }
 
+// ageDescription tells the agent when the booking tools need the age.
+const ageDescription = "age is the age in years of the person attending, required when the service has an age restriction."
+
+// depositDescription tells the agent what to do with the deposit returned by
+// the booking tools.
+const depositDescription = "When deposit is returned the service requires one: tell the client the amount and currency and give them the paymentLink, " +
+	"the booking stays pending until it is paid and is cancelled if it isn't paid before payBefore."
+
 func (t *bookAppointmentTool) Call(ctx context.Context, input string) (string, error) {
 	t.logFunc("bookAppointment called with args: %v ; %v\n", ctx, input)
 
@@ -287,16 +695,19 @@ func (t *bookAppointmentTool) Call(ctx context.Context, input string) (string, e
 	if !ok || name == "" {
 		return makeResult(nil, "invalid name argument", fmt.Errorf("name is not a string")), nil
 	}
-	phone, ok := inputMap["phone"]
-	if !ok || phone == "" {
-		return makeResult(nil, "invalid phone argument", fmt.Errorf("phone is not a string")), nil
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
 	}
-
-	if ok, err := t.employeesRepository.CheckAvailability(employee.ID, service.ID, date, bookingTime); !ok || err != nil {
-		return makeResult(nil, "employee is not available", err), nil
+	if result := checkAge(inputMap["age"], service); result != "" {
+		return result, nil
 	}
 
-	dateTime, err := time.Parse("2006-01-02 15:04", date+" "+bookingTime)
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	dateTime, locationId, err := parseBranchTime(t.locationsRepository, t.timezone, employee, locationId, date, bookingTime)
 	if err != nil {
 		return makeResult(nil, "invalid date and time", err), nil
 	}
@@ -304,53 +715,2066 @@ func (t *bookAppointmentTool) Call(ctx context.Context, input string) (string, e
 	t.logFunc("booking appointment for employeeId: %d serviceId: %d date: %s time: %s name: %s phone: %s",
 		employee.ID, service.ID, date, bookingTime, name, phone)
 
+	// Convert the slot held while checking the availability, if any
+	hold, err := t.findSessionHold(sessionFromContext(ctx), employee.ID, service.ID, locationId, dateTime)
+	if err != nil {
+		return makeResult(nil, "Failed to save booking", err), nil
+	}
+	if hold != nil {
+		booking, err := t.bookingsRepository.ConfirmHold(hold.ID, name, phone)
+		if err == nil {
+			return t.bookingResult(booking), nil
+		}
+		if !errors.Is(err, repository.ErrHoldNotFound) {
+			return makeResult(nil, "Failed to save booking", err), nil
+		}
+		// The hold expired in the meantime, try to book the slot anyway
+	}
+
+	available, err := t.employeesRepository.CheckAvailability(employee.ID, service.ID, locationId, date, bookingTime)
+	if err == nil && !available {
+		err = repository.ErrSlotNotAvailable
+	}
+	if err != nil {
+		return makeResult(nil, "employee is not available", err), nil
+	}
+
 	booking := repository.Booking{
 		ServiceID:       service.ID,
 		EmployeeID:      employee.ID,
+		LocationID:      locationId,
 		BookingDateTime: dateTime,
 		CustomerName:    name,
 		CustomerPhone:   phone,
 	}
+	booking.SetServiceSnapshot(service)
 
-	err = t.bookingsRepository.SaveBooking(&booking)
-	return makeResult("ok", "Failed to save booking", err), nil
+	err = t.bookingsRepository.ReserveBooking(&booking)
+	if errors.Is(err, repository.ErrSlotNotAvailable) {
+		return makeResult(nil, "the slot was just taken by another client, offer a different time", err), nil
+	}
+	if err != nil {
+		return makeResult(nil, "Failed to save booking", err), nil
+	}
+	return t.bookingResult(&booking), nil
 }
 
-func GetAgentTools(bookingsRepository repository.BookingRepository, servicesRepository repository.ServiceRepository, employeeRepository repository.EmployeeRepository, debug bool) []langchaintools.Tool {
+// bookingResult returns the booking number and the branch of a new booking,
+// the deposit to pay if any, and when the client should come back for the
+// service, if they should.
+func (t *bookAppointmentTool) bookingResult(booking *repository.Booking) string {
+	location, err := getLocationName(t.locationsRepository, booking.LocationID)
+	if err != nil {
+		return makeResult(nil, "Failed to save booking", err)
+	}
+	service, err := t.servicesRepository.GetServiceById(booking.ServiceID)
+	if err != nil {
+		return makeResult(nil, "Failed to save booking", err)
+	}
+	depositDue, depositResult := requestDeposit(t.payments, t.timezone, []*repository.Booking{booking})
+	if depositResult != "" {
+		return depositResult
+	}
 
-	logFunc := func(format string, v ...interface{}) {
-		if debug {
-			log.Printf(format, v...)
+	result := map[string]any{
+		"booking":  booking.ID,
+		"location": location,
+	}
+	if depositDue != nil {
+		result["deposit"] = depositDue
+	}
+	if service.RecallMonths != 0 {
+		result["followUpInMonths"] = service.RecallMonths
+	}
+	return makeResult(result, "Failed to save booking", nil)
+}
+
+// deposit is what the client pays online to confirm new bookings.
+type deposit struct {
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	PaymentLink string  `json:"paymentLink"`
+	PayBefore   string  `json:"payBefore"`
+}
+
+// requestDeposit asks for the deposit of new bookings, nil if none is due. On
+// failure the bookings are cancelled and the returned string is the tool
+// result to send back.
+func requestDeposit(deposits *payments.Payments, timezone *time.Location, bookings []*repository.Booking) (*deposit, string) {
+	requested, err := deposits.RequestDeposit(bookings)
+	if err != nil {
+		return nil, makeResult(nil, "Failed to request the deposit, the booking was cancelled", err)
+	}
+	if requested == nil {
+		return nil, ""
+	}
+
+	return &deposit{
+		Amount:      requested.Amount,
+		Currency:    requested.Currency,
+		PaymentLink: requested.Link,
+		PayBefore:   requested.DueAt.In(timezone).Format("2006-01-02 15:04"),
+	}, ""
+}
+
+// findSessionHold returns the active hold of the session for the given slot,
+// or nil if there is none.
+func (t *bookAppointmentTool) findSessionHold(sessionId string, employeeId uint, serviceId uint, locationId uint, dateTime time.Time) (*repository.Hold, error) {
+	if sessionId == "" {
+		return nil, nil
+	}
+
+	holds, err := t.bookingsRepository.GetSessionHolds(sessionId)
+	if err != nil {
+		return nil, err
+	}
+	for _, hold := range holds {
+		if hold.Booking.EmployeeID == employeeId && hold.Booking.ServiceID == serviceId &&
+			hold.Booking.LocationID == locationId && hold.Booking.BookingDateTime.Equal(dateTime) {
+			return hold, nil
 		}
 	}
 
-	return []langchaintools.Tool{
-		&getServicesTool{
-			servicesRepository: servicesRepository,
-			logFunc:            logFunc,
-		},
-		&getEmployeesTool{
-			employeesRepository: employeeRepository,
-			logFunc:             logFunc,
-		},
-		&getServicesForEmployeeTool{
-			employeesRepository: employeeRepository,
-			logFunc:             logFunc,
-		},
-		&getEmployeesForServiceTool{
-			employeesRepository: employeeRepository,
-			servicesRepository:  servicesRepository,
-			logFunc:             logFunc,
-		},
-		&checkAvailabilityTool{
-			employeesRepository: employeeRepository,
-			servicesRepository:  servicesRepository,
-			logFunc:             logFunc,
-		},
-		&bookAppointmentTool{
-			employeesRepository: employeeRepository,
-			servicesRepository:  servicesRepository,
+	return nil, nil
+}
+
+// getLocationId looks up the optional branch referenced by the tool input and
+// returns its id, or 0 if no branch is given. On failure the returned string is
+// the tool result to send back.
+func getLocationId(locationsRepository repository.LocationRepository, inputMap map[string]string) (uint, string) {
+	locationArg := inputMap["location"]
+	if locationArg == "" {
+		return 0, ""
+	}
+
+	location, err := locationsRepository.GetLocationByName(locationArg)
+	if err != nil {
+		return 0, makeResult(nil, "branch not found, check the list of branches", err)
+	}
+
+	return location.ID, ""
+}
+
+// getLocationName returns the name of the branch, or an empty string for 0.
+func getLocationName(locationsRepository repository.LocationRepository, locationId uint) (string, error) {
+	if locationId == 0 {
+		return "", nil
+	}
+
+	location, err := locationsRepository.GetLocationById(locationId)
+	if err != nil {
+		return "", err
+	}
+
+	return location.Name, nil
+}
+
+// parseBranchTime parses a date and time of day given at a branch, in the time
+// zone of the branch. Without a branch, the one the employee works at on that
+// day is used and returned, if an employee is given.
+func parseBranchTime(locationsRepository repository.LocationRepository, timezone *time.Location, employee *repository.Employee, locationId uint, date string, timeOfDay string) (time.Time, uint, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, timezone)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if employee != nil {
+		locationId = employee.ResolveLocation(locationId, day.Weekday())
+	}
+
+	branchTimezone, err := repository.LocationTimezone(locationsRepository, locationId, timezone)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	dateTime, err := repository.ParseLocalTime("2006-01-02 15:04", date+" "+timeOfDay, branchTimezone)
+	return dateTime, locationId, err
+}
+
+// branchTime returns t in the time zone of the branch, or in the business time
+// zone when there is no branch or it can't be loaded.
+func branchTime(locationsRepository repository.LocationRepository, timezone *time.Location, locationId uint, t time.Time) time.Time {
+	branchTimezone, err := repository.LocationTimezone(locationsRepository, locationId, timezone)
+	if err != nil {
+		return t.In(timezone)
+	}
+	return t.In(branchTimezone)
+}
+
+// getPhone validates the phone argument of the tool input and returns it in
+// E.164 format, national numbers being of phoneCountry. On failure the
+// returned string is the tool result to send back, telling what to ask the
+// client.
+func getPhone(inputMap map[string]string, phoneCountry string) (string, string) {
+	phoneArg, ok := inputMap["phone"]
+	if !ok || phoneArg == "" {
+		return "", makeResult(nil, "invalid phone argument", fmt.Errorf("phone is not a string"))
+	}
+
+	phone, err := repository.ParsePhone(phoneArg, phoneCountry)
+	if err != nil {
+		return "", makeResult(nil, fmt.Sprintf("%v, ask the client again for their phone number", err), err)
+	}
+
+	return phone, ""
+}
+
+// checkAge validates the age argument of the tool input against the age
+// restriction of the services. The age is only required when one of them is
+// restricted. On failure the returned string is the tool result to send back.
+func checkAge(ageArg string, services ...*repository.Service) string {
+	restricted := slices.ContainsFunc(services, func(s *repository.Service) bool {
+		return s.MinAge != 0 || s.MaxAge != 0
+	})
+	if !restricted {
+		return ""
+	}
+	if ageArg == "" {
+		return makeResult(nil, "the service has an age restriction, ask the client the age of the person attending",
+			fmt.Errorf("age is required"))
+	}
+
+	age, err := strconv.ParseUint(ageArg, 10, 0)
+	if err != nil {
+		return makeResult(nil, "invalid age argument, it must be a number of years", err)
+	}
+	for _, service := range services {
+		if !service.AllowsAge(uint(age)) {
+			return makeResult(nil, fmt.Sprintf("%s is not offered at the age of %d, see the age restriction in getServiceDetails", service.Name, age),
+				fmt.Errorf("age %d is not allowed for service %d", age, service.ID))
+		}
+	}
+
+	return ""
+}
+
+// getCustomerBooking looks up the booking referenced by the tool input and
+// makes sure it belongs to the customer with the given phone number. On
+// failure the returned string is the tool result to send back.
+func getCustomerBooking(bookingsRepository repository.BookingRepository, phoneCountry string, inputMap map[string]string) (*repository.Booking, string) {
+	bookingArg, ok := inputMap["booking"]
+	if !ok || bookingArg == "" {
+		return nil, makeResult(nil, "invalid booking argument", fmt.Errorf("booking is not a string"))
+	}
+	bookingId, err := strconv.ParseUint(bookingArg, 10, 0)
+	if err != nil {
+		return nil, makeResult(nil, "invalid booking argument", err)
+	}
+	phone, result := getPhone(inputMap, phoneCountry)
+	if result != "" {
+		return nil, result
+	}
+
+	booking, err := bookingsRepository.GetBookingById(uint(bookingId))
+	if err != nil {
+		return nil, makeResult(nil, "booking not found", err)
+	}
+	if !repository.SamePhone(booking.CustomerPhone, phone, phoneCountry) {
+		return nil, makeResult(nil, "booking not found", fmt.Errorf("phone does not match booking %d", booking.ID))
+	}
+
+	return booking, ""
+}
+
+type getMyAppointmentsTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+type appointment struct {
+	Booking  uint   `json:"booking"`
+	Series   uint   `json:"series,omitempty"`
+	Bundle   uint   `json:"bundle,omitempty"`
+	Group    uint   `json:"group,omitempty"`
+	For      string `json:"for,omitempty"`
+	Employee string `json:"employee"`
+	Service  string `json:"service"`
+	Location string `json:"location,omitempty"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+	Status   string `json:"status"`
+	// PaymentLink and PayBefore are set while the deposit is unpaid
+	PaymentLink string `json:"paymentLink,omitempty"`
+	PayBefore   string `json:"payBefore,omitempty"`
+}
+
+func (t *getMyAppointmentsTool) Name() string {
+	return "getMyAppointments"
+}
+
+func (t *getMyAppointmentsTool) Description() string {
+	return "Get the upcoming appointments of a client, series is set for the ones part of a recurring series, " +
+		"bundle for the ones part of a visit where several services are booked back-to-back, " +
+		"group for the ones booked together for several people and for is the person the appointment is for, when it isn't the client. " +
+		"status is the state of the appointment, pending ones still need to be confirmed by the clinic, " +
+		"or their deposit paid at paymentLink before payBefore when these are set." +
+		"Input is a JSON object with the following string fields: phone, name." +
+		"phone is required and must be the phone number used when booking, name is optional."
+}
+
+func (t *getMyAppointmentsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("getMyAppointments called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
+	}
+
+	bookings, err := t.bookingsRepository.GetUpcomingBookingsByCustomer(phone, inputMap["name"])
+	if err != nil {
+		return makeResult(nil, "Failed to get appointments", err), nil
+	}
+
+	appointments := make([]appointment, 0, len(bookings))
+	for _, booking := range bookings {
+		employee, err := t.employeesRepository.GetEmployeeById(booking.EmployeeID)
+		if err != nil {
+			return makeResult(nil, "Failed to get appointments", err), nil
+		}
+		service, err := t.servicesRepository.GetServiceById(booking.ServiceID)
+		if err != nil {
+			return makeResult(nil, "Failed to get appointments", err), nil
+		}
+		location, err := getLocationName(t.locationsRepository, booking.LocationID)
+		if err != nil {
+			return makeResult(nil, "Failed to get appointments", err), nil
+		}
+
+		start := branchTime(t.locationsRepository, t.timezone, booking.LocationID, booking.BookingDateTime)
+		bookingAppointment := appointment{
+			Booking:  booking.ID,
+			Series:   booking.SeriesID,
+			Bundle:   booking.BundleID,
+			Group:    booking.GroupID,
+			For:      booking.AttendeeName,
+			Employee: employee.Name,
+			Service:  service.Name,
+			Location: location,
+			Date:     start.Format("2006-01-02"),
+			Time:     start.Format("15:04"),
+			Status:   string(booking.Status),
+		}
+		if booking.AwaitingPayment() && booking.PaymentLink != "" {
+			bookingAppointment.PaymentLink = booking.PaymentLink
+			bookingAppointment.PayBefore = booking.PaymentDueAt.In(t.timezone).Format("2006-01-02 15:04")
+		}
+		appointments = append(appointments, bookingAppointment)
+	}
+
+	return makeResult(appointments, "Failed to get appointments", nil), nil
+}
+
+type getCustomerTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	customersRepository repository.CustomerRepository
+	locationsRepository repository.LocationRepository
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+type customerProfile struct {
+	Returning bool   `json:"returning"`
+	Name      string `json:"name,omitempty"`
+	Visits    int    `json:"visits,omitempty"`
+	// The latest appointment the client attended, if any
+	LastVisit            *appointment `json:"lastVisit,omitempty"`
+	UpcomingAppointments int          `json:"upcomingAppointments,omitempty"`
+}
+
+func (t *getCustomerTool) Name() string {
+	return "getCustomer"
+}
+
+func (t *getCustomerTool) Description() string {
+	return "Look up a client by phone number to know if they are a returning client." +
+		"Input is a JSON object with the following string fields: phone. phone is required." +
+		"For a returning client it gives the name they booked with, how many appointments they attended, their last visit " +
+		"and how many upcoming appointments they have."
+}
+
+func (t *getCustomerTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("getCustomer called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
+	}
+
+	customer, err := t.customersRepository.GetCustomerByPhone(phone)
+	if errors.Is(err, repository.ErrCustomerNotFound) {
+		return makeResult(customerProfile{Returning: false}, "Failed to get the client", nil), nil
+	}
+	if err != nil {
+		return makeResult(nil, "Failed to get the client", err), nil
+	}
+
+	bookings, err := t.bookingsRepository.GetBookingsByCustomerId(customer.ID)
+	if err != nil {
+		return makeResult(nil, "Failed to get the client", err), nil
+	}
+
+	now := time.Now()
+	profile := customerProfile{Returning: true, Name: customer.Name}
+	for _, booking := range bookings {
+		if !booking.Status.Occupies() {
+			continue
+		}
+		if booking.BookingDateTime.Before(now) {
+			profile.Visits++
+		} else {
+			profile.UpcomingAppointments++
+		}
+	}
+
+	if lastVisit := repository.LastVisit(bookings, now); lastVisit != nil {
+		employee, err := t.employeesRepository.GetEmployeeById(lastVisit.EmployeeID)
+		if err != nil {
+			return makeResult(nil, "Failed to get the client", err), nil
+		}
+		service, err := t.servicesRepository.GetServiceById(lastVisit.ServiceID)
+		if err != nil {
+			return makeResult(nil, "Failed to get the client", err), nil
+		}
+		location, err := getLocationName(t.locationsRepository, lastVisit.LocationID)
+		if err != nil {
+			return makeResult(nil, "Failed to get the client", err), nil
+		}
+
+		start := branchTime(t.locationsRepository, t.timezone, lastVisit.LocationID, lastVisit.BookingDateTime)
+		profile.LastVisit = &appointment{
+			Booking:  lastVisit.ID,
+			Series:   lastVisit.SeriesID,
+			Bundle:   lastVisit.BundleID,
+			Group:    lastVisit.GroupID,
+			For:      lastVisit.AttendeeName,
+			Employee: employee.Name,
+			Service:  service.Name,
+			Location: location,
+			Date:     start.Format("2006-01-02"),
+			Time:     start.Format("15:04"),
+			Status:   string(lastVisit.Status),
+		}
+	}
+
+	return makeResult(profile, "Failed to get the client", nil), nil
+}
+
+type cancelAppointmentTool struct {
+	bookingsRepository repository.BookingRepository
+	waitlist           *waitlist.Waitlist
+	payments           *payments.Payments
+	phoneCountry       string
+	logFunc            func(format string, v ...interface{})
+}
+
+func (t *cancelAppointmentTool) Name() string {
+	return "cancelAppointment"
+}
+
+func (t *cancelAppointmentTool) Description() string {
+	return "Cancel an upcoming appointment." +
+		"Input is a JSON object with the following string fields: booking, phone." +
+		"All fields are required: booking is the booking number and phone the phone number used when booking." +
+		"A deposit paid for the appointment is refunded. Ask for confirmation before cancelling."
+}
+
+func (t *cancelAppointmentTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("cancelAppointment called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	booking, result := getCustomerBooking(t.bookingsRepository, t.phoneCountry, inputMap)
+	if booking == nil {
+		return result, nil
+	}
+
+	err = t.bookingsRepository.CancelBooking(booking.ID)
+	if err != nil {
+		return makeResult(nil, "Failed to cancel booking", err), nil
+	}
+	t.waitlist.Wake() // Offer the freed slot to the waitlist
+
+	if err := t.payments.RefundDeposits([]*repository.Booking{booking}); err != nil {
+		return makeResult(nil, refundFailed, err), nil
+	}
+	return makeResult("ok", "Failed to cancel booking", nil), nil
+}
+
+// refundFailed tells the agent what to say when a deposit couldn't be given
+// back to the client.
+const refundFailed = "the appointment was cancelled but the deposit could not be refunded automatically, tell the client the clinic will refund it"
+
+type rescheduleAppointmentTool struct {
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	waitlist            *waitlist.Waitlist
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+func (t *rescheduleAppointmentTool) Name() string {
+	return "rescheduleAppointment"
+}
+
+func (t *rescheduleAppointmentTool) Description() string {
+	return "Move an upcoming appointment to a new date and time, with the same employee, service and branch." +
+		"Input is a JSON object with the following string fields: booking, phone, date, time." +
+		"All fields are required: booking is the booking number and phone the phone number used when booking." +
+		"The date and time should be in the format YYYY-MM-DD and HH:MM." +
+		"If the new time is not available the original appointment is kept. Ask for confirmation before rescheduling."
+}
+
+func (t *rescheduleAppointmentTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("rescheduleAppointment called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	booking, result := getCustomerBooking(t.bookingsRepository, t.phoneCountry, inputMap)
+	if booking == nil {
+		return result, nil
+	}
+
+	date, ok := inputMap["date"]
+	if !ok {
+		return makeResult(nil, "invalid date argument", fmt.Errorf("date is not a string")), nil
+	}
+	bookingTime, ok := inputMap["time"]
+	if !ok {
+		return makeResult(nil, "invalid time argument", fmt.Errorf("time is not a string")), nil
+	}
+	dateTime, _, err := parseBranchTime(t.locationsRepository, t.timezone, nil, booking.LocationID, date, bookingTime)
+	if err != nil {
+		return makeResult(nil, "invalid date and time", err), nil
+	}
+
+	err = t.bookingsRepository.RescheduleBooking(booking.ID, dateTime)
+	if errors.Is(err, repository.ErrBookedInBundle) {
+		return makeResult(nil, "the appointment is part of a visit with several services and can't be moved on its own, "+
+			"the original appointment is kept. To move the visit, cancel its appointments and book it again", err), nil
+	}
+	if errors.Is(err, repository.ErrBookedInGroup) {
+		return makeResult(nil, "the appointment is part of a group booked together and can't be moved on its own, "+
+			"the original appointment is kept. To move the group, cancel its appointments and book it again", err), nil
+	}
+	if errors.Is(err, repository.ErrEmployeeNotWorking) {
+		return makeResult(nil, "the employee can't take the appointment at the new time or at this branch on the new date, the original appointment is kept", err), nil
+	}
+	if errors.Is(err, repository.ErrSlotNotAvailable) {
+		return makeResult(nil, "employee is not available at the new time, the original appointment is kept", err), nil
+	}
+	if err == nil {
+		t.waitlist.Wake() // Offer the old slot to the waitlist
+	}
+	return makeResult("ok", "Failed to reschedule booking", err), nil
+}
+
+type bookRecurringAppointmentsTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	payments            *payments.Payments
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+type occurrence struct {
+	Booking  uint   `json:"booking,omitempty"`
+	Location string `json:"location,omitempty"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+}
+
+func (t *bookRecurringAppointmentsTool) Name() string {
+	return "bookRecurringAppointments"
+}
+
+func (t *bookRecurringAppointmentsTool) Description() string {
+	return "Book a series of recurring appointments with an employee for a service, e.g. a cleaning every 6 months." +
+		"Input is a JSON object with the following string fields: employee, service, date, time, name, phone, location, age, every, unit, count, until." +
+		"date and time are the first appointment, in the format YYYY-MM-DD and HH:MM. location and age are as for bookAppointment." +
+		"every is the number of weeks or months between appointments and unit is weeks or months." +
+		"Either count, the number of appointments including the first one, or until, the date of the last one, is required." +
+		"Either all the appointments are booked or none: the ones that can't be booked are returned as unavailable, offer a different time." +
+		"Returns the series number, needed to cancel the whole series, and the booking numbers. Ask for confirmation before booking." +
+		depositDescription + " The deposit covers all the appointments of the series."
+}
+
+func (t *bookRecurringAppointmentsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("bookRecurringAppointments called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	employee, err := t.employeesRepository.GetEmployeeByName(inputMap["employee"])
+	if err != nil || employee == nil {
+		return makeResult(nil, "employee not found", err), nil
+	}
+	service, err := t.servicesRepository.GetServiceByName(inputMap["service"])
+	if err != nil || service == nil {
+		return makeResult(nil, "service not found", err), nil
+	}
+	if !slices.Contains(employee.ServicesIds, service.ID) {
+		return makeResult(nil, "employee does not offer the service", fmt.Errorf("employee does not offer the service")), nil
+	}
+	if result := checkAge(inputMap["age"], service); result != "" {
+		return result, nil
+	}
+
+	name := inputMap["name"]
+	if name == "" {
+		return makeResult(nil, "invalid name argument", fmt.Errorf("name is not a string")), nil
+	}
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
+	}
+
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	start, _, err := parseBranchTime(t.locationsRepository, t.timezone, employee, locationId, inputMap["date"], inputMap["time"])
+	if err != nil {
+		return makeResult(nil, "invalid date and time", err), nil
+	}
+
+	recurrence, result := getRecurrence(inputMap, t.timezone)
+	if result != "" {
+		return result, nil
+	}
+	starts, err := recurrence.Occurrences(start)
+	if err != nil {
+		return makeResult(nil, err.Error(), err), nil
+	}
+
+	// The client may have held the first slot while checking it, release it
+	// so it doesn't conflict with the series.
+	if err := t.bookingsRepository.ReleaseSessionHolds(sessionFromContext(ctx)); err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+
+	t.logFunc("booking series for employeeId: %d serviceId: %d start: %s recurrence: %+v name: %s phone: %s",
+		employee.ID, service.ID, start, recurrence, name, phone)
+
+	var bookings []*repository.Booking
+	var unavailable []occurrence
+	// localStart returns an occurrence start in the time zone of its branch
+	localStart := func(occurrenceStart time.Time) time.Time {
+		occurrenceLocationId := employee.ResolveLocation(locationId, occurrenceStart.In(t.timezone).Weekday())
+		return branchTime(t.locationsRepository, t.timezone, occurrenceLocationId, occurrenceStart)
+	}
+	for _, occurrenceStart := range starts {
+		local := localStart(occurrenceStart)
+		date, bookingTime := local.Format("2006-01-02"), local.Format("15:04")
+		available, err := t.employeesRepository.CheckAvailability(employee.ID, service.ID, locationId, date, bookingTime)
+		if err != nil {
+			return makeResult(nil, "Failed to check availability", err), nil
+		}
+		if !available {
+			unavailable = append(unavailable, occurrence{Date: date, Time: bookingTime})
+			continue
+		}
+
+		booking := &repository.Booking{
+			ServiceID:       service.ID,
+			EmployeeID:      employee.ID,
+			LocationID:      employee.ResolveLocation(locationId, occurrenceStart.In(t.timezone).Weekday()),
+			BookingDateTime: occurrenceStart,
+			CustomerName:    name,
+			CustomerPhone:   phone,
+		}
+		booking.SetServiceSnapshot(service)
+		bookings = append(bookings, booking)
+	}
+
+	series := &repository.Series{Recurrence: recurrence}
+	if len(unavailable) == 0 {
+		err = t.bookingsRepository.ReserveSeries(series, bookings)
+	}
+	var conflictErr *repository.SeriesConflictError
+	if errors.As(err, &conflictErr) {
+		for _, conflict := range conflictErr.Conflicts {
+			local := localStart(conflict.Start)
+			unavailable = append(unavailable, occurrence{
+				Date: local.Format("2006-01-02"),
+				Time: local.Format("15:04"),
+			})
+		}
+	} else if err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+	if len(unavailable) > 0 {
+		slices.SortFunc(unavailable, func(a, b occurrence) int {
+			return strings.Compare(a.Date+a.Time, b.Date+b.Time)
+		})
+		return makeResult(map[string]any{
+			"booked":      false,
+			"unavailable": unavailable,
+		}, "Failed to save bookings", nil), nil
+	}
+
+	booked := make([]occurrence, 0, len(bookings))
+	for _, booking := range bookings {
+		location, err := getLocationName(t.locationsRepository, booking.LocationID)
+		if err != nil {
+			return makeResult(nil, "Failed to save bookings", err), nil
+		}
+		local := branchTime(t.locationsRepository, t.timezone, booking.LocationID, booking.BookingDateTime)
+		booked = append(booked, occurrence{
+			Booking:  booking.ID,
+			Location: location,
+			Date:     local.Format("2006-01-02"),
+			Time:     local.Format("15:04"),
+		})
+	}
+
+	depositDue, result := requestDeposit(t.payments, t.timezone, bookings)
+	if result != "" {
+		return result, nil
+	}
+
+	seriesResult := map[string]any{
+		"booked":   true,
+		"series":   series.ID,
+		"bookings": booked,
+	}
+	if depositDue != nil {
+		seriesResult["deposit"] = depositDue
+	}
+	return makeResult(seriesResult, "Failed to save bookings", nil), nil
+}
+
+// getRecurrence parses the recurrence fields of the tool input, with the until
+// date in the business time zone. On failure the returned string is the tool
+// result to send back.
+func getRecurrence(inputMap map[string]string, timezone *time.Location) (repository.Recurrence, string) {
+	var recurrence repository.Recurrence
+
+	every, err := strconv.ParseUint(inputMap["every"], 10, 0)
+	if err != nil || every == 0 {
+		return recurrence, makeResult(nil, "invalid every argument", fmt.Errorf("every is not a positive integer"))
+	}
+	recurrence.Interval = uint(every)
+	recurrence.Unit = repository.RecurrenceUnit(inputMap["unit"])
+
+	if countArg := inputMap["count"]; countArg != "" {
+		count, err := strconv.ParseUint(countArg, 10, 0)
+		if err != nil || count == 0 {
+			return recurrence, makeResult(nil, "invalid count argument", fmt.Errorf("count is not a positive integer"))
+		}
+		recurrence.Count = uint(count)
+	} else if untilArg := inputMap["until"]; untilArg != "" {
+		recurrence.Until, err = time.ParseInLocation("2006-01-02", untilArg, timezone)
+		if err != nil {
+			return recurrence, makeResult(nil, "invalid until argument", err)
+		}
+	}
+
+	if err := recurrence.Validate(); err != nil {
+		return recurrence, makeResult(nil, err.Error(), err)
+	}
+	return recurrence, ""
+}
+
+type cancelRecurringAppointmentsTool struct {
+	bookingsRepository repository.BookingRepository
+	waitlist           *waitlist.Waitlist
+	payments           *payments.Payments
+	phoneCountry       string
+	logFunc            func(format string, v ...interface{})
+}
+
+func (t *cancelRecurringAppointmentsTool) Name() string {
+	return "cancelRecurringAppointments"
+}
+
+func (t *cancelRecurringAppointmentsTool) Description() string {
+	return "Cancel all the upcoming appointments of a recurring series." +
+		"Input is a JSON object with the following string fields: booking, phone." +
+		"All fields are required: booking is the number of any booking of the series and phone the phone number used when booking." +
+		"To cancel a single appointment of the series use cancelAppointment instead. The deposits paid for the cancelled appointments are refunded. Ask for confirmation before cancelling."
+}
+
+func (t *cancelRecurringAppointmentsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("cancelRecurringAppointments called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	booking, result := getCustomerBooking(t.bookingsRepository, t.phoneCountry, inputMap)
+	if booking == nil {
+		return result, nil
+	}
+	if booking.SeriesID == 0 {
+		return makeResult(nil, "booking is not part of a recurring series, use cancelAppointment",
+			fmt.Errorf("booking %d has no series", booking.ID)), nil
+	}
+
+	cancelled, err := t.bookingsRepository.CancelSeries(booking.SeriesID)
+	if err != nil {
+		return makeResult(nil, "Failed to cancel series", err), nil
+	}
+	t.waitlist.Wake() // Offer the freed slots to the waitlist
+	if err := t.payments.RefundDeposits(cancelled); err != nil {
+		return makeResult(nil, refundFailed, err), nil
+	}
+
+	cancelledIds := make([]uint, 0, len(cancelled))
+	for _, booking := range cancelled {
+		cancelledIds = append(cancelledIds, booking.ID)
+	}
+	return makeResult(map[string]any{"cancelled": cancelledIds}, "Failed to cancel series", nil), nil
+}
+
+type findBundleSlotsTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	locationsRepository repository.LocationRepository
+	timezone            *time.Location
+	logFunc             func(format string, v ...interface{})
+}
+
+// visit is a bundle of services performed back-to-back, as returned by
+// findBundleSlots and bookBundle.
+type visit struct {
+	Bundle   uint        `json:"bundle,omitempty"`
+	Location string      `json:"location,omitempty"`
+	Date     string      `json:"date"`
+	End      string      `json:"end"`
+	Services []visitStep `json:"services"`
+	// Deposit to pay for the booked visit, if any
+	Deposit *deposit `json:"deposit,omitempty"`
+}
+
+type visitStep struct {
+	Booking  uint   `json:"booking,omitempty"`
+	Service  string `json:"service"`
+	Employee string `json:"employee"`
+	Time     string `json:"time"`
+}
+
+func (t *findBundleSlotsTool) Name() string {
+	return "findBundleSlots"
+}
+
+func (t *findBundleSlotsTool) Description() string {
+	return "Find the earliest times for a visit where several services are performed back-to-back, e.g. a cleaning followed by a whitening." +
+		"Input is a JSON object with the following string fields: services, location, from, to, limit." +
+		"services is required, the comma separated service names in the order they should be performed, at most 5." +
+		"location is the branch name, optional and all branches are searched if missing." +
+		"from and to are optional dates in the format YYYY-MM-DD, by default the search starts today and spans 7 days." +
+		"limit is the maximum number of results, 5 by default." +
+		"Each visit lists the start time and the employee of every service, which may be different employees, and when the visit ends."
+}
+
+func (t *findBundleSlotsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("findBundleSlots called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	services, result := getBundleServices(t.servicesRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	from, to, limit, result := getSlotsSearch(inputMap, t.timezone)
+	if result != "" {
+		return result, nil
+	}
+
+	servicesIds := make([]uint, 0, len(services))
+	for _, service := range services {
+		servicesIds = append(servicesIds, service.ID)
+	}
+	bundles, err := t.employeesRepository.FindBundleSlots(servicesIds, locationId, from, to, limit)
+	if err != nil {
+		return makeResult(nil, "Failed to find available visits", err), nil
+	}
+
+	visits := make([]visit, 0, len(bundles))
+	for _, bundle := range bundles {
+		// The whole visit is at the branch of the first service
+		visitTimezone, err := repository.LocationTimezone(t.locationsRepository, bundle.Steps[0].LocationID, t.timezone)
+		if err != nil {
+			return makeResult(nil, "Failed to find available visits", err), nil
+		}
+		steps := make([]visitStep, 0, len(bundle.Steps))
+		for i, s := range bundle.Steps {
+			employee, err := t.employeesRepository.GetEmployeeById(s.EmployeeID)
+			if err != nil {
+				return makeResult(nil, "Failed to find available visits", err), nil
+			}
+			steps = append(steps, visitStep{
+				Service:  services[i].Name,
+				Employee: employee.Name,
+				Time:     s.Start.In(visitTimezone).Format("15:04"),
+			})
+		}
+
+		location, err := getLocationName(t.locationsRepository, bundle.Steps[0].LocationID)
+		if err != nil {
+			return makeResult(nil, "Failed to find available visits", err), nil
+		}
+		last := bundle.Steps[len(bundle.Steps)-1]
+		end := last.Start.Add(time.Duration(services[len(services)-1].Duration) * time.Minute)
+		visits = append(visits, visit{
+			Location: location,
+			Date:     bundle.Steps[0].Start.In(visitTimezone).Format("2006-01-02"),
+			End:      end.In(visitTimezone).Format("15:04"),
+			Services: steps,
+		})
+	}
+
+	return makeResult(visits, "Failed to find available visits", nil), nil
+}
+
+// getBundleServices looks up the comma separated services of the tool input,
+// in order. On failure the returned string is the tool result to send back.
+func getBundleServices(servicesRepository repository.ServiceRepository, inputMap map[string]string) ([]*repository.Service, string) {
+	services, result := getServicesList(servicesRepository, inputMap["services"])
+	if result != "" {
+		return nil, result
+	}
+
+	if len(services) < 2 {
+		return nil, makeResult(nil, "invalid services argument, give at least 2 services or book a single service with bookAppointment",
+			fmt.Errorf("services has %d services", len(services)))
+	}
+	if len(services) > repository.MaxBundleServices {
+		return nil, makeResult(nil, fmt.Sprintf("at most %d services can be booked in one visit", repository.MaxBundleServices),
+			fmt.Errorf("services has %d services", len(services)))
+	}
+
+	return services, ""
+}
+
+// getServicesList looks up the services of a comma separated list, in order.
+// On failure the returned string is the tool result to send back.
+func getServicesList(servicesRepository repository.ServiceRepository, servicesArg string) ([]*repository.Service, string) {
+	var services []*repository.Service
+	for _, serviceArg := range strings.Split(servicesArg, ",") {
+		serviceArg = strings.TrimSpace(serviceArg)
+		if serviceArg == "" {
+			continue
+		}
+		service, err := servicesRepository.GetServiceByName(serviceArg)
+		if err != nil || service == nil {
+			return nil, makeResult(nil, fmt.Sprintf("service %q not found", serviceArg), err)
+		}
+		services = append(services, service)
+	}
+
+	return services, ""
+}
+
+type bookBundleTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	payments            *payments.Payments
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+func (t *bookBundleTool) Name() string {
+	return "bookBundle"
+}
+
+func (t *bookBundleTool) Description() string {
+	return "Book a visit where several services are performed back-to-back, each one starting when the previous one ends." +
+		"Input is a JSON object with the following string fields: services, employees, date, time, location, name, phone, age." +
+		"services is required, the comma separated service names in order, as given to findBundleSlots." +
+		"employees is optional, the comma separated employee names for each service in the same order, as returned by findBundleSlots." +
+		"When missing, any free employees are chosen. date and time are the start of the first service, in the format YYYY-MM-DD and HH:MM." +
+		"location and age are as for bookAppointment. name and phone are required." +
+		"Either all the services are booked or none. Returns the visit number and the booking number of each service. Ask for confirmation before booking." +
+		depositDescription
+}
+
+func (t *bookBundleTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("bookBundle called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	services, result := getBundleServices(t.servicesRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	if result := checkAge(inputMap["age"], services...); result != "" {
+		return result, nil
+	}
+	name := inputMap["name"]
+	if name == "" {
+		return makeResult(nil, "invalid name argument", fmt.Errorf("name is not a string")), nil
+	}
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
+	}
+
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	// Without a branch, the visit is at the one the first employee works at
+	// that day, when the client chose the employees
+	var firstEmployee *repository.Employee
+	if employeesArg := inputMap["employees"]; employeesArg != "" {
+		firstEmployee, _ = t.employeesRepository.GetEmployeeByName(strings.TrimSpace(strings.Split(employeesArg, ",")[0]))
+	}
+	start, _, err := parseBranchTime(t.locationsRepository, t.timezone, firstEmployee, locationId, inputMap["date"], inputMap["time"])
+	if err != nil {
+		return makeResult(nil, "invalid date and time", err), nil
+	}
+
+	// The client may have held a slot while checking it, release it so it
+	// doesn't conflict with the visit.
+	if err := t.bookingsRepository.ReleaseSessionHolds(sessionFromContext(ctx)); err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+
+	var employees []*repository.Employee
+	if inputMap["employees"] != "" {
+		employees, result = t.getEmployees(inputMap["employees"], services, start, locationId)
+	} else {
+		employees, result = t.findEmployees(services, start, locationId)
+	}
+	if result != "" {
+		return result, nil
+	}
+	locationId = employees[0].ResolveLocation(locationId, start.In(t.timezone).Weekday())
+
+	t.logFunc("booking bundle for services: %v start: %s name: %s phone: %s", inputMap["services"], start, name, phone)
+
+	bookings := make([]*repository.Booking, 0, len(services))
+	stepStart := start
+	for i, service := range services {
+		booking := &repository.Booking{
+			ServiceID:       service.ID,
+			EmployeeID:      employees[i].ID,
+			LocationID:      locationId,
+			BookingDateTime: stepStart,
+			CustomerName:    name,
+			CustomerPhone:   phone,
+		}
+		booking.SetServiceSnapshot(service)
+		bookings = append(bookings, booking)
+		stepStart = booking.EndDateTime()
+	}
+
+	if err := repository.ValidateBundle(bookings); err != nil {
+		return makeResult(nil, fmt.Sprintf("%v, choose other employees", err), err), nil
+	}
+
+	err = t.bookingsRepository.ReserveBundle(bookings)
+	if errors.Is(err, repository.ErrSlotNotAvailable) {
+		return makeResult(nil, "the visit was just taken by another client, offer a different time with findBundleSlots", err), nil
+	}
+	if err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+
+	location, err := getLocationName(t.locationsRepository, locationId)
+	if err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+	visitTimezone := branchTime(t.locationsRepository, t.timezone, locationId, start).Location()
+	booked := visit{
+		Bundle:   bookings[0].BundleID,
+		Location: location,
+		Date:     start.In(visitTimezone).Format("2006-01-02"),
+		End:      stepStart.In(visitTimezone).Format("15:04"),
+	}
+	for i, booking := range bookings {
+		booked.Services = append(booked.Services, visitStep{
+			Booking:  booking.ID,
+			Service:  services[i].Name,
+			Employee: employees[i].Name,
+			Time:     booking.BookingDateTime.In(visitTimezone).Format("15:04"),
+		})
+	}
+	booked.Deposit, result = requestDeposit(t.payments, t.timezone, bookings)
+	if result != "" {
+		return result, nil
+	}
+
+	return makeResult(booked, "Failed to save bookings", nil), nil
+}
+
+// getEmployees looks up the comma separated employees chosen for the services
+// and checks that each one is free for their service. On failure the returned
+// string is the tool result to send back.
+func (t *bookBundleTool) getEmployees(employeesArg string, services []*repository.Service, start time.Time, locationId uint) ([]*repository.Employee, string) {
+	names := strings.Split(employeesArg, ",")
+	if len(names) != len(services) {
+		return nil, makeResult(nil, "invalid employees argument, give one employee for each service",
+			fmt.Errorf("%d employees for %d services", len(names), len(services)))
+	}
+
+	employees := make([]*repository.Employee, 0, len(names))
+	for i, employeeName := range names {
+		employee, err := t.employeesRepository.GetEmployeeByName(strings.TrimSpace(employeeName))
+		if err != nil || employee == nil {
+			return nil, makeResult(nil, fmt.Sprintf("employee %q not found", employeeName), err)
+		}
+		if !slices.Contains(employee.ServicesIds, services[i].ID) {
+			return nil, makeResult(nil, fmt.Sprintf("%s does not offer %s", employee.Name, services[i].Name),
+				fmt.Errorf("employee does not offer the service"))
+		}
+		employees = append(employees, employee)
+	}
+
+	// The following services are at the branch of the first one
+	locationId = employees[0].ResolveLocation(locationId, start.In(t.timezone).Weekday())
+	visitTimezone := branchTime(t.locationsRepository, t.timezone, locationId, start).Location()
+	stepStart := start
+	for i, employee := range employees {
+		available, err := t.employeesRepository.CheckAvailability(employee.ID, services[i].ID, locationId,
+			stepStart.In(visitTimezone).Format("2006-01-02"), stepStart.In(visitTimezone).Format("15:04"))
+		if err != nil {
+			return nil, makeResult(nil, "Failed to check availability", err)
+		}
+		if !available {
+			return nil, makeResult(nil, fmt.Sprintf("%s is not available for %s at %s, offer a different time with findBundleSlots",
+				employee.Name, services[i].Name, stepStart.In(visitTimezone).Format("15:04")), repository.ErrSlotNotAvailable)
+		}
+		stepStart = stepStart.Add(time.Duration(services[i].Duration) * time.Minute)
+	}
+
+	return employees, ""
+}
+
+// findEmployees chooses free employees for the services of a visit starting
+// at start. On failure the returned string is the tool result to send back.
+func (t *bookBundleTool) findEmployees(services []*repository.Service, start time.Time, locationId uint) ([]*repository.Employee, string) {
+	servicesIds := make([]uint, 0, len(services))
+	for _, service := range services {
+		servicesIds = append(servicesIds, service.ID)
+	}
+
+	bundles, err := t.employeesRepository.FindBundleSlots(servicesIds, locationId, start, start.Add(time.Minute), 1)
+	if err != nil {
+		return nil, makeResult(nil, "Failed to check availability", err)
+	}
+	if len(bundles) == 0 || !bundles[0].Steps[0].Start.Equal(start) {
+		return nil, makeResult(nil, "the visit is not available at that time, offer a different time with findBundleSlots",
+			repository.ErrSlotNotAvailable)
+	}
+
+	employees := make([]*repository.Employee, 0, len(services))
+	for _, s := range bundles[0].Steps {
+		employee, err := t.employeesRepository.GetEmployeeById(s.EmployeeID)
+		if err != nil {
+			return nil, makeResult(nil, "Failed to check availability", err)
+		}
+		employees = append(employees, employee)
+	}
+
+	return employees, ""
+}
+
+type findGroupSlotsTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	locationsRepository repository.LocationRepository
+	timezone            *time.Location
+	logFunc             func(format string, v ...interface{})
+}
+
+// group is a booking for several attendees, as returned by findGroupSlots and
+// bookGroup.
+type group struct {
+	Group     uint            `json:"group,omitempty"`
+	Location  string          `json:"location,omitempty"`
+	Date      string          `json:"date"`
+	End       string          `json:"end"`
+	Attendees []groupAttendee `json:"attendees"`
+	// Deposit to pay for the booked group, if any
+	Deposit *deposit `json:"deposit,omitempty"`
+}
+
+type groupAttendee struct {
+	Booking  uint   `json:"booking,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Service  string `json:"service"`
+	Employee string `json:"employee"`
+	Time     string `json:"time"`
+}
+
+func (t *findGroupSlotsTool) Name() string {
+	return "findGroupSlots"
+}
+
+func (t *findGroupSlotsTool) Description() string {
+	return "Find the earliest times to book several people together, e.g. a parent and their children, each with their own service." +
+		"Input is a JSON object with the following string fields: services, location, from, to, limit." +
+		"services is required, the comma separated service of each attendee, at most 5, the same service can be repeated." +
+		"location is the branch name, optional and all branches are searched if missing." +
+		"from and to are optional dates in the format YYYY-MM-DD, by default the search starts today and spans 7 days." +
+		"limit is the maximum number of results, 5 by default." +
+		"Attendees are seen at the same time by different employees when possible, otherwise one after the other." +
+		"Each result lists the employee and time of every attendee, in the order of services, and when the last one ends."
+}
+
+func (t *findGroupSlotsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("findGroupSlots called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	services, result := getGroupServices(t.servicesRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	from, to, limit, result := getSlotsSearch(inputMap, t.timezone)
+	if result != "" {
+		return result, nil
+	}
+
+	servicesIds := make([]uint, 0, len(services))
+	for _, service := range services {
+		servicesIds = append(servicesIds, service.ID)
+	}
+	groupSlots, err := t.employeesRepository.FindGroupSlots(servicesIds, locationId, from, to, limit)
+	if err != nil {
+		return makeResult(nil, "Failed to find available times", err), nil
+	}
+
+	groups := make([]group, 0, len(groupSlots))
+	for _, groupSlot := range groupSlots {
+		g, err := toGroup(t.employeesRepository, t.locationsRepository, t.timezone, services, groupSlot.Slots)
+		if err != nil {
+			return makeResult(nil, "Failed to find available times", err), nil
+		}
+		groups = append(groups, *g)
+	}
+
+	return makeResult(groups, "Failed to find available times", nil), nil
+}
+
+// toGroup converts the slots of the attendees, who need the services, to the
+// tool output, with employee and branch names and the times in the time zone
+// of the branch.
+func toGroup(employeesRepository repository.EmployeeRepository, locationsRepository repository.LocationRepository, timezone *time.Location, services []*repository.Service, slots []*repository.Slot) (*group, error) {
+	location, err := getLocationName(locationsRepository, slots[0].LocationID)
+	if err != nil {
+		return nil, err
+	}
+	timezone, err = repository.LocationTimezone(locationsRepository, slots[0].LocationID, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &group{
+		Location: location,
+		Date:     slots[0].Start.In(timezone).Format("2006-01-02"),
+	}
+	var end time.Time
+	for i, s := range slots {
+		employee, err := employeesRepository.GetEmployeeById(s.EmployeeID)
+		if err != nil {
+			return nil, err
+		}
+		g.Attendees = append(g.Attendees, groupAttendee{
+			Service:  services[i].Name,
+			Employee: employee.Name,
+			Time:     s.Start.In(timezone).Format("15:04"),
+		})
+		if attendeeEnd := s.Start.Add(time.Duration(services[i].Duration) * time.Minute); attendeeEnd.After(end) {
+			end = attendeeEnd
+		}
+	}
+	g.End = end.In(timezone).Format("15:04")
+
+	return g, nil
+}
+
+// getGroupServices looks up the comma separated services of the attendees in
+// the tool input, in order. On failure the returned string is the tool result
+// to send back.
+func getGroupServices(servicesRepository repository.ServiceRepository, inputMap map[string]string) ([]*repository.Service, string) {
+	services, result := getServicesList(servicesRepository, inputMap["services"])
+	if result != "" {
+		return nil, result
+	}
+
+	if len(services) < 2 {
+		return nil, makeResult(nil, "invalid services argument, give the service of each attendee or book a single appointment with bookAppointment",
+			fmt.Errorf("services has %d services", len(services)))
+	}
+	if len(services) > repository.MaxGroupAttendees {
+		return nil, makeResult(nil, fmt.Sprintf("at most %d people can be booked together", repository.MaxGroupAttendees),
+			fmt.Errorf("services has %d services", len(services)))
+	}
+
+	return services, ""
+}
+
+type bookGroupTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	payments            *payments.Payments
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+func (t *bookGroupTool) Name() string {
+	return "bookGroup"
+}
+
+func (t *bookGroupTool) Description() string {
+	return "Book several people together under one contact, e.g. a parent and their children, each with their own service." +
+		"Input is a JSON object with the following string fields: names, services, ages, date, time, location, name, phone." +
+		"names is required, the comma separated names of the attendees, and services the service of each one in the same order." +
+		"ages is the comma separated age in years of each attendee in the same order, required when a service has an age restriction." +
+		"date and time are the start of the group as returned by findGroupSlots, in the format YYYY-MM-DD and HH:MM; the employees and times of the attendees are chosen as by findGroupSlots." +
+		"location is optional, as for bookAppointment. name and phone are the contact who is booking, required." +
+		"Either all the attendees are booked or none. Returns the group number and the employee, time and booking number of each attendee, confirm all of them to the client. Ask for confirmation before booking." +
+		depositDescription
+}
+
+func (t *bookGroupTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("bookGroup called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	services, result := getGroupServices(t.servicesRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	var names []string
+	for _, attendeeName := range strings.Split(inputMap["names"], ",") {
+		names = append(names, strings.TrimSpace(attendeeName))
+	}
+	if len(names) != len(services) || slices.Contains(names, "") {
+		return makeResult(nil, "invalid names argument, give the name of each attendee in the order of services",
+			fmt.Errorf("%d names for %d services", len(names), len(services))), nil
+	}
+	ages := make([]string, len(services))
+	if agesArg := inputMap["ages"]; agesArg != "" {
+		ages = strings.Split(agesArg, ",")
+		if len(ages) != len(services) {
+			return makeResult(nil, "invalid ages argument, give the age of each attendee in the order of services",
+				fmt.Errorf("%d ages for %d services", len(ages), len(services))), nil
+		}
+	}
+	for i, service := range services {
+		if result := checkAge(strings.TrimSpace(ages[i]), service); result != "" {
+			return result, nil
+		}
+	}
+	name := inputMap["name"]
+	if name == "" {
+		return makeResult(nil, "invalid name argument", fmt.Errorf("name is not a string")), nil
+	}
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
+	}
+
+	locationId, result := getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+	start, _, err := parseBranchTime(t.locationsRepository, t.timezone, nil, locationId, inputMap["date"], inputMap["time"])
+	if err != nil {
+		return makeResult(nil, "invalid date and time", err), nil
+	}
+
+	// The client may have held a slot while checking it, release it so it
+	// doesn't conflict with the group.
+	if err := t.bookingsRepository.ReleaseSessionHolds(sessionFromContext(ctx)); err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+
+	servicesIds := make([]uint, 0, len(services))
+	for _, service := range services {
+		servicesIds = append(servicesIds, service.ID)
+	}
+	groupSlots, err := t.employeesRepository.FindGroupSlots(servicesIds, locationId, start, start.Add(time.Minute), 1)
+	if err != nil {
+		return makeResult(nil, "Failed to check availability", err), nil
+	}
+	if len(groupSlots) == 0 || !groupSlots[0].Slots[0].Start.Equal(start) {
+		return makeResult(nil, "the group can't be booked at that time, offer a different time with findGroupSlots",
+			repository.ErrSlotNotAvailable), nil
+	}
+
+	t.logFunc("booking group for names: %v services: %v start: %s name: %s phone: %s", names, inputMap["services"], start, name, phone)
+
+	slots := groupSlots[0].Slots
+	bookings := make([]*repository.Booking, 0, len(slots))
+	for i, s := range slots {
+		booking := &repository.Booking{
+			ServiceID:       services[i].ID,
+			EmployeeID:      s.EmployeeID,
+			LocationID:      s.LocationID,
+			BookingDateTime: s.Start,
+			CustomerName:    name,
+			CustomerPhone:   phone,
+		}
+		// The contact may book for themselves too
+		if !strings.EqualFold(names[i], name) {
+			booking.AttendeeName = names[i]
+		}
+		booking.SetServiceSnapshot(services[i])
+		bookings = append(bookings, booking)
+	}
+
+	err = t.bookingsRepository.ReserveGroup(bookings)
+	if errors.Is(err, repository.ErrSlotNotAvailable) {
+		return makeResult(nil, "the time was just taken by another client, offer a different time with findGroupSlots", err), nil
+	}
+	if err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+
+	booked, err := toGroup(t.employeesRepository, t.locationsRepository, t.timezone, services, slots)
+	if err != nil {
+		return makeResult(nil, "Failed to save bookings", err), nil
+	}
+	booked.Group = bookings[0].GroupID
+	for i, booking := range bookings {
+		booked.Attendees[i].Booking = booking.ID
+		booked.Attendees[i].Name = names[i]
+	}
+	booked.Deposit, result = requestDeposit(t.payments, t.timezone, bookings)
+	if result != "" {
+		return result, nil
+	}
+
+	return makeResult(booked, "Failed to save bookings", nil), nil
+}
+
+type getFollowUpSlotsTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+func (t *getFollowUpSlotsTool) Name() string {
+	return "getFollowUpSlots"
+}
+
+func (t *getFollowUpSlotsTool) Description() string {
+	return "Find free times for the next appointment after a booking, for services clients should come back for, e.g. a cleaning every 6 months." +
+		"Input is a JSON object with the following string fields: booking, phone." +
+		"All fields are required: booking is the booking number and phone the phone number used when booking." +
+		"Prefers the same employee and branch. Offer the client to book one of the slots too, or a recurring series."
+}
+
+func (t *getFollowUpSlotsTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("getFollowUpSlots called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	booking, result := getCustomerBooking(t.bookingsRepository, t.phoneCountry, inputMap)
+	if booking == nil {
+		return result, nil
+	}
+	service, err := t.servicesRepository.GetServiceById(booking.ServiceID)
+	if err != nil {
+		return makeResult(nil, "Failed to find follow-up slots", err), nil
+	}
+	if service.RecallMonths == 0 {
+		return makeResult(map[string]any{"followUp": false}, "Failed to find follow-up slots", nil), nil
+	}
+
+	from := repository.StartOfDay(booking.BookingDateTime.In(t.timezone).AddDate(0, int(service.RecallMonths), 0), t.timezone)
+	to := from.AddDate(0, 0, defaultSlotsDays)
+
+	slots, err := t.employeesRepository.FindAvailableSlots(service.ID, booking.LocationID, from, to, booking.EmployeeID, defaultSlotsLimit)
+	if err == nil && len(slots) == 0 {
+		slots, err = t.employeesRepository.FindAvailableSlots(service.ID, booking.LocationID, from, to, 0, defaultSlotsLimit)
+	}
+	if err != nil {
+		return makeResult(nil, "Failed to find follow-up slots", err), nil
+	}
+
+	freeSlots, err := toSlots(t.employeesRepository, t.locationsRepository, t.timezone, slots)
+	return makeResult(map[string]any{
+		"followUp":      true,
+		"service":       service.Name,
+		"inMonths":      service.RecallMonths,
+		"suggestedDate": from.Format("2006-01-02"),
+		"slots":         freeSlots,
+	}, "Failed to find follow-up slots", err), nil
+}
+
+type joinWaitlistTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	locationsRepository repository.LocationRepository
+	waitlist            *waitlist.Waitlist
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+func (t *joinWaitlistTool) Name() string {
+	return "joinWaitlist"
+}
+
+func (t *joinWaitlistTool) Description() string {
+	return "Add a client to the waitlist for a service when no suitable time is free." +
+		"Input is a JSON object with the following string fields: service, employee, location, from, to, name, phone, age." +
+		"service, from, name and phone are required. employee and location are optional, any employee or branch will do if missing." +
+		ageDescription +
+		"from and to are dates in the format YYYY-MM-DD, the client accepts any time between them, to is the same as from by default." +
+		"When a matching slot frees up, it is held for the client and they are notified, they have a limited time to claim it." +
+		"Returns the waitlist number."
+}
+
+func (t *joinWaitlistTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("joinWaitlist called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	service, err := t.servicesRepository.GetServiceByName(inputMap["service"])
+	if err != nil || service == nil {
+		return makeResult(nil, "service not found", err), nil
+	}
+	if result := checkAge(inputMap["age"], service); result != "" {
+		return result, nil
+	}
+
+	entry := &repository.WaitlistEntry{
+		ServiceID:    service.ID,
+		CustomerName: inputMap["name"],
+	}
+	if entry.CustomerName == "" {
+		return makeResult(nil, "invalid name argument", fmt.Errorf("name is not a string")), nil
+	}
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
+	}
+	entry.CustomerPhone = phone
+
+	if employeeArg := inputMap["employee"]; employeeArg != "" {
+		employee, err := t.employeesRepository.GetEmployeeByName(employeeArg)
+		if err != nil || employee == nil {
+			return makeResult(nil, "employee not found", err), nil
+		}
+		if !slices.Contains(employee.ServicesIds, service.ID) {
+			return makeResult(nil, "employee does not offer the service", fmt.Errorf("employee does not offer the service")), nil
+		}
+		entry.EmployeeID = employee.ID
+	}
+	entry.LocationID, result = getLocationId(t.locationsRepository, inputMap)
+	if result != "" {
+		return result, nil
+	}
+
+	entry.From, err = time.ParseInLocation("2006-01-02", inputMap["from"], t.timezone)
+	if err != nil {
+		return makeResult(nil, "invalid from argument", err), nil
+	}
+	to := entry.From
+	if toArg := inputMap["to"]; toArg != "" {
+		to, err = time.ParseInLocation("2006-01-02", toArg, t.timezone)
+		if err != nil {
+			return makeResult(nil, "invalid to argument", err), nil
+		}
+	}
+	entry.To = to.AddDate(0, 0, 1)
+
+	if err := t.waitlist.Join(entry); err != nil {
+		return makeResult(nil, "Failed to join the waitlist: "+err.Error(), err), nil
+	}
+	return makeResult(map[string]any{"waitlist": entry.ID}, "Failed to join the waitlist", nil), nil
+}
+
+type getMyWaitlistTool struct {
+	employeesRepository repository.EmployeeRepository
+	servicesRepository  repository.ServiceRepository
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	waitlistRepository  repository.WaitlistRepository
+	timezone            *time.Location
+	phoneCountry        string
+	logFunc             func(format string, v ...interface{})
+}
+
+type waitlistEntry struct {
+	Waitlist uint   `json:"waitlist"`
+	Service  string `json:"service"`
+	Employee string `json:"employee,omitempty"`
+	Location string `json:"location,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Status   string `json:"status"`
+	// The offered slot, if any
+	Offer     *slot  `json:"offer,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+func (t *getMyWaitlistTool) Name() string {
+	return "getMyWaitlist"
+}
+
+func (t *getMyWaitlistTool) Description() string {
+	return "Get the waitlist entries of a client and the slots offered to them." +
+		"Input is a JSON object with the following string fields: phone." +
+		"phone is required and must be the phone number used when joining the waitlist." +
+		"An entry with status offered has a slot held for the client until expiresAt, claim it with claimWaitlistOffer if they want it."
+}
+
+func (t *getMyWaitlistTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("getMyWaitlist called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	phone, result := getPhone(inputMap, t.phoneCountry)
+	if result != "" {
+		return result, nil
+	}
+
+	entries, err := t.waitlistRepository.GetEntriesByCustomer(phone)
+	if err != nil {
+		return makeResult(nil, "Failed to get the waitlist", err), nil
+	}
+
+	results := make([]waitlistEntry, 0, len(entries))
+	for _, entry := range entries {
+		result, err := t.describeEntry(entry)
+		if err != nil {
+			return makeResult(nil, "Failed to get the waitlist", err), nil
+		}
+		results = append(results, *result)
+	}
+
+	return makeResult(results, "Failed to get the waitlist", nil), nil
+}
+
+func (t *getMyWaitlistTool) describeEntry(entry *repository.WaitlistEntry) (*waitlistEntry, error) {
+	service, err := t.servicesRepository.GetServiceById(entry.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	location, err := getLocationName(t.locationsRepository, entry.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &waitlistEntry{
+		Waitlist: entry.ID,
+		Service:  service.Name,
+		Location: location,
+		From:     entry.From.In(t.timezone).Format("2006-01-02"),
+		To:       entry.To.In(t.timezone).AddDate(0, 0, -1).Format("2006-01-02"),
+		Status:   string(entry.Status),
+	}
+	if entry.EmployeeID != 0 {
+		employee, err := t.employeesRepository.GetEmployeeById(entry.EmployeeID)
+		if err != nil {
+			return nil, err
+		}
+		result.Employee = employee.Name
+	}
+
+	if entry.Status == repository.WaitlistOffered {
+		holds, err := t.bookingsRepository.GetSessionHolds(waitlist.SessionID(entry.ID))
+		if err != nil {
+			return nil, err
+		}
+		for _, hold := range holds {
+			if hold.ID != entry.OfferHoldID {
+				continue
+			}
+			offers, err := toSlots(t.employeesRepository, t.locationsRepository, t.timezone, []*repository.Slot{{
+				EmployeeID: hold.Booking.EmployeeID,
+				LocationID: hold.Booking.LocationID,
+				Start:      hold.Booking.BookingDateTime,
+			}})
+			if err != nil {
+				return nil, err
+			}
+			result.Offer = &offers[0]
+			result.ExpiresAt = hold.ExpiresAt.In(t.timezone).Format("2006-01-02 15:04")
+		}
+	}
+
+	return result, nil
+}
+
+type claimWaitlistOfferTool struct {
+	bookingsRepository  repository.BookingRepository
+	locationsRepository repository.LocationRepository
+	waitlist            *waitlist.Waitlist
+	payments            *payments.Payments
+	timezone            *time.Location
+	logFunc             func(format string, v ...interface{})
+}
+
+func (t *claimWaitlistOfferTool) Name() string {
+	return "claimWaitlistOffer"
+}
+
+func (t *claimWaitlistOfferTool) Description() string {
+	return "Book the slot offered to a client from the waitlist." +
+		"Input is a JSON object with the following string fields: waitlist, phone." +
+		"All fields are required: waitlist is the waitlist number and phone the phone number used when joining." +
+		"Ask for confirmation before claiming. Returns the booking number, give it to the client." +
+		depositDescription
+}
+
+func (t *claimWaitlistOfferTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("claimWaitlistOffer called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	entryId, err := strconv.ParseUint(inputMap["waitlist"], 10, 0)
+	if err != nil {
+		return makeResult(nil, "invalid waitlist argument", err), nil
+	}
+
+	booking, err := t.waitlist.Claim(uint(entryId), inputMap["phone"])
+	if errors.Is(err, waitlist.ErrNoOffer) || errors.Is(err, waitlist.ErrOfferExpired) {
+		return makeResult(nil, err.Error(), err), nil
+	}
+	if errors.Is(err, repository.ErrWaitlistEntryNotFound) {
+		return makeResult(nil, "waitlist entry not found", err), nil
+	}
+	if err != nil {
+		return makeResult(nil, "Failed to claim the offer", err), nil
+	}
+
+	location, err := getLocationName(t.locationsRepository, booking.LocationID)
+	if err != nil {
+		return makeResult(nil, "Failed to claim the offer", err), nil
+	}
+	depositDue, result := requestDeposit(t.payments, t.timezone, []*repository.Booking{booking})
+	if result != "" {
+		return result, nil
+	}
+
+	start := branchTime(t.locationsRepository, t.timezone, booking.LocationID, booking.BookingDateTime)
+	claimResult := map[string]any{
+		"booking":  booking.ID,
+		"location": location,
+		"date":     start.Format("2006-01-02"),
+		"time":     start.Format("15:04"),
+	}
+	if depositDue != nil {
+		claimResult["deposit"] = depositDue
+	}
+	return makeResult(claimResult, "Failed to claim the offer", nil), nil
+}
+
+type leaveWaitlistTool struct {
+	waitlist *waitlist.Waitlist
+	logFunc  func(format string, v ...interface{})
+}
+
+func (t *leaveWaitlistTool) Name() string {
+	return "leaveWaitlist"
+}
+
+func (t *leaveWaitlistTool) Description() string {
+	return "Remove a client from the waitlist, also declining the slot offered to them if any." +
+		"Input is a JSON object with the following string fields: waitlist, phone." +
+		"All fields are required: waitlist is the waitlist number and phone the phone number used when joining."
+}
+
+func (t *leaveWaitlistTool) Call(ctx context.Context, input string) (string, error) {
+	t.logFunc("leaveWaitlist called with ctx=%v ; input=%v\n", ctx, input)
+
+	var inputMap map[string]string
+	err := json.Unmarshal([]byte(input), &inputMap)
+	if err != nil {
+		return makeResult(nil, "invalid input", err), nil
+	}
+
+	entryId, err := strconv.ParseUint(inputMap["waitlist"], 10, 0)
+	if err != nil {
+		return makeResult(nil, "invalid waitlist argument", err), nil
+	}
+
+	err = t.waitlist.Leave(uint(entryId), inputMap["phone"])
+	if errors.Is(err, repository.ErrWaitlistEntryNotFound) {
+		return makeResult(nil, "waitlist entry not found", err), nil
+	}
+	return makeResult("ok", "Failed to leave the waitlist", err), nil
+}
+
+func GetAgentTools(repositories *repository.Repositories, customerWaitlist *waitlist.Waitlist, deposits *payments.Payments, holdTTL time.Duration, debug bool) []langchaintools.Tool {
+
+	logFunc := func(format string, v ...interface{}) {
+		if debug {
+			log.Printf(format, v...)
+		}
+	}
+
+	bookingsRepository := repositories.Bookings
+	servicesRepository := repositories.Services
+	employeeRepository := repositories.Employees
+	locationsRepository := repositories.Locations
+
+	return []langchaintools.Tool{
+		&getServicesTool{
+			servicesRepository: servicesRepository,
+			logFunc:            logFunc,
+		},
+		&getServiceDetailsTool{
+			servicesRepository:  servicesRepository,
+			employeesRepository: employeeRepository,
+			locationsRepository: locationsRepository,
+			logFunc:             logFunc,
+		},
+		&getEmployeesTool{
+			employeesRepository: employeeRepository,
+			logFunc:             logFunc,
+		},
+		&getServicesForEmployeeTool{
+			employeesRepository: employeeRepository,
+			logFunc:             logFunc,
+		},
+		&getEmployeesForServiceTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			logFunc:             logFunc,
+		},
+		&getLocationsTool{
+			locationsRepository: locationsRepository,
+			logFunc:             logFunc,
+		},
+		&getClinicCalendarTool{
+			calendarRepository: repositories.Calendar,
+			timezone:           repositories.Timezone,
+			logFunc:            logFunc,
+		},
+		&checkAvailabilityTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			holdTTL:             holdTTL,
+			timezone:            repositories.Timezone,
+			logFunc:             logFunc,
+		},
+		&findAvailableSlotsTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			locationsRepository: locationsRepository,
+			timezone:            repositories.Timezone,
+			logFunc:             logFunc,
+		},
+		&bookAppointmentTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			payments:            deposits,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&getMyAppointmentsTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&getCustomerTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			customersRepository: repositories.Customers,
+			locationsRepository: locationsRepository,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&cancelAppointmentTool{
+			bookingsRepository: bookingsRepository,
+			waitlist:           customerWaitlist,
+			payments:           deposits,
+			phoneCountry:       repositories.PhoneCountry,
+			logFunc:            logFunc,
+		},
+		&bookRecurringAppointmentsTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			payments:            deposits,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&findBundleSlotsTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			locationsRepository: locationsRepository,
+			timezone:            repositories.Timezone,
+			logFunc:             logFunc,
+		},
+		&bookBundleTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			payments:            deposits,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&findGroupSlotsTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			locationsRepository: locationsRepository,
+			timezone:            repositories.Timezone,
+			logFunc:             logFunc,
+		},
+		&bookGroupTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			payments:            deposits,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&cancelRecurringAppointmentsTool{
+			bookingsRepository: bookingsRepository,
+			waitlist:           customerWaitlist,
+			payments:           deposits,
+			phoneCountry:       repositories.PhoneCountry,
+			logFunc:            logFunc,
+		},
+		&getFollowUpSlotsTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&joinWaitlistTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			locationsRepository: locationsRepository,
+			waitlist:            customerWaitlist,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&getMyWaitlistTool{
+			employeesRepository: employeeRepository,
+			servicesRepository:  servicesRepository,
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			waitlistRepository:  repositories.Waitlist,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
+			logFunc:             logFunc,
+		},
+		&claimWaitlistOfferTool{
+			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			waitlist:            customerWaitlist,
+			payments:            deposits,
+			timezone:            repositories.Timezone,
+			logFunc:             logFunc,
+		},
+		&leaveWaitlistTool{
+			waitlist: customerWaitlist,
+			logFunc:  logFunc,
+		},
+		&rescheduleAppointmentTool{
 			bookingsRepository:  bookingsRepository,
+			locationsRepository: locationsRepository,
+			waitlist:            customerWaitlist,
+			timezone:            repositories.Timezone,
+			phoneCountry:        repositories.PhoneCountry,
 			logFunc:             logFunc,
 		},
 	}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"valighita/bookings-ai-agent/repository"
+)
+
+// catalog is the seed data of a business.
+type catalog struct {
+	// Timezone is the IANA name of the business time zone, the opening and
+	// working hours are given in it. Empty means UTC.
+	Timezone string
+	// PhoneCountry is the ISO 3166 code of the country whose national phone
+	// numbers are accepted without calling code. Empty means every number
+	// must be international.
+	PhoneCountry string
+	// Currency is the ISO 4217 code of the prices and deposits, required when
+	// a service takes a deposit
+	Currency  string
+	Locations map[uint]*repository.Location
+	Resources map[uint]*repository.Resource
+	Services  map[uint]*repository.Service
+	Employees map[uint]*repository.Employee
+	Calendar  repository.BusinessCalendar
+}
+
+// items returns the locations, resources, services and employees of the
+// catalog, what can be reloaded.
+func (c *catalog) items() *repository.Catalog {
+	return &repository.Catalog{
+		Locations: c.Locations,
+		Resources: c.Resources,
+		Services:  c.Services,
+		Employees: c.Employees,
+	}
+}
+
+// builtinCatalogs can be chosen by name in the tenants file. Each call returns
+// fresh data, so tenants using the same catalog don't share any state.
+var builtinCatalogs = map[string]func() *catalog{
+	"dental": dentalCatalog,
+}
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// validate checks the time zone, the phone country, the currency, the
+// locations, resources, services and employees, the references between them,
+// and the calendar of the catalog. Deposits are only accepted with a payment
+// provider to take them.
+func (c *catalog) validate(hasPaymentProvider bool) error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", c.Timezone, err)
+	}
+	if err := repository.ValidatePhoneCountry(c.PhoneCountry); err != nil {
+		return err
+	}
+	if c.Currency != "" && !currencyPattern.MatchString(c.Currency) {
+		return fmt.Errorf("invalid currency %q, expected an ISO 4217 code like EUR", c.Currency)
+	}
+
+	// Names must be unique as the agent looks the items up by name
+	if err := checkEntries("location", c.Locations, func(l *repository.Location) (uint, string) { return l.ID, l.Name }); err != nil {
+		return err
+	}
+	if err := checkEntries("resource", c.Resources, func(r *repository.Resource) (uint, string) { return r.ID, r.Name }); err != nil {
+		return err
+	}
+	if err := checkEntries("service", c.Services, func(s *repository.Service) (uint, string) { return s.ID, s.Name }); err != nil {
+		return err
+	}
+	if err := checkEntries("employee", c.Employees, func(e *repository.Employee) (uint, string) { return e.ID, e.Name }); err != nil {
+		return err
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(c.Locations)) {
+		location := c.Locations[id]
+		if err := location.Validate(); err != nil {
+			return fmt.Errorf("invalid location %s: %w", location.Name, err)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Resources)) {
+		if c.Resources[id].Capacity == 0 {
+			return fmt.Errorf("resource %s has no capacity", c.Resources[id].Name)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Services)) {
+		service := c.Services[id]
+		if err := service.Validate(); err != nil {
+			return fmt.Errorf("invalid service %s: %w", service.Name, err)
+		}
+		if service.Duration == 0 {
+			return fmt.Errorf("service %s has no duration", service.Name)
+		}
+		if service.Deposit > 0 && c.Currency == "" {
+			return fmt.Errorf("service %s takes a deposit but the catalog has no currency", service.Name)
+		}
+		if service.Deposit > 0 && !hasPaymentProvider {
+			return fmt.Errorf("service %s takes a deposit but no payment provider is configured, set PAYMENT_PROVIDER", service.Name)
+		}
+		if unknown, ok := unknownId(c.Resources, service.ResourcesIds); ok {
+			return fmt.Errorf("service %s uses unknown resource %d", service.Name, unknown)
+		}
+		if unknown, ok := unknownId(c.Locations, service.LocationsIds); ok {
+			return fmt.Errorf("service %s is offered at unknown location %d", service.Name, unknown)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Employees)) {
+		employee := c.Employees[id]
+		if unknown, ok := unknownId(c.Services, employee.ServicesIds); ok {
+			return fmt.Errorf("employee %s performs unknown service %d", employee.Name, unknown)
+		}
+		if err := employee.Schedule.Validate(); err != nil {
+			return fmt.Errorf("invalid schedule for employee %s: %w", employee.Name, err)
+		}
+		for _, weekday := range slices.Sorted(maps.Keys(employee.Locations)) {
+			if unknown, ok := unknownId(c.Locations, []uint{employee.Locations[weekday]}); ok {
+				return fmt.Errorf("employee %s works on %s at unknown location %d", employee.Name, weekday, unknown)
+			}
+		}
+	}
+
+	if err := c.Calendar.OpeningHours.Validate(); err != nil {
+		return fmt.Errorf("invalid opening hours: %w", err)
+	}
+	for _, holiday := range c.Calendar.Holidays {
+		// A leap year, so February 29 is accepted
+		date := time.Date(2024, holiday.Month, holiday.Day, 0, 0, 0, 0, time.UTC)
+		if holiday.Month < time.January || holiday.Month > time.December || date.Day() != holiday.Day {
+			return fmt.Errorf("holiday %s has an invalid date %d/%d", holiday.Name, holiday.Month, holiday.Day)
+		}
+	}
+	for _, closure := range c.Calendar.Closures {
+		if !closure.Start.Before(closure.End) {
+			return fmt.Errorf("closure %q ends before it starts", closure.Reason)
+		}
+	}
+	return nil
+}
+
+// checkEntries checks that the entries are keyed by their id, not 0, and that
+// their names are set and unique, ignoring case.
+func checkEntries[T any](kind string, entries map[uint]*T, describe func(*T) (uint, string)) error {
+	names := map[string]uint{}
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		if entries[key] == nil {
+			return fmt.Errorf("%s %d is empty", kind, key)
+		}
+		id, name := describe(entries[key])
+		if id == 0 || id != key {
+			return fmt.Errorf("%s %q has id %d, expected a positive id matching its key %d", kind, name, id, key)
+		}
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s %d has no name", kind, id)
+		}
+		if other, ok := names[strings.ToLower(name)]; ok {
+			return fmt.Errorf("%ss %d and %d have the same name %q", kind, other, id, name)
+		}
+		names[strings.ToLower(name)] = id
+	}
+	return nil
+}
+
+// unknownId returns the first of the ids that isn't a key of entries, if any.
+func unknownId[T any](entries map[uint]*T, ids []uint) (uint, bool) {
+	for _, id := range ids {
+		if _, ok := entries[id]; !ok {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// dentalCatalog returns the services, employees and calendar of a dental clinic.
+func dentalCatalog() *catalog {
+	// clinic branches
+	locations := map[uint]*repository.Location{
+		1: {
+			ID:      1,
+			Name:    "Old Town",
+			Address: "12 Market Street",
+		},
+		2: {
+			ID:      2,
+			Name:    "Riverside",
+			Address: "48 River Road",
+			OpeningHours: repository.WeeklySchedule{
+				time.Monday:    {{Start: "09:00", End: "18:00"}},
+				time.Tuesday:   {{Start: "09:00", End: "18:00"}},
+				time.Wednesday: {{Start: "09:00", End: "18:00"}},
+				time.Thursday:  {{Start: "09:00", End: "18:00"}},
+				time.Friday:    {{Start: "09:00", End: "18:00"}},
+			},
+		},
+	}
+
+	// rooms and equipment shared by the employees, all at the Old Town branch
+	resources := map[uint]*repository.Resource{
+		1: {
+			ID:       1,
+			Name:     "Surgery Room",
+			Capacity: 1,
+		},
+		2: {
+			ID:       2,
+			Name:     "X-Ray Machine",
+			Capacity: 1,
+		},
+	}
+
+	// services for a dental clinic
+	services := map[uint]*repository.Service{
+		1: {
+			ID:           1,
+			Name:         "Dental Cleaning",
+			Duration:     30,
+			Price:        100,
+			RecallMonths: 6,
+			Category:     "Preventive care",
+			Description:  "Professional removal of plaque and tartar above and below the gum line, followed by polishing and a check of the gums.",
+			Aftercare:    "Avoid coloured food and drinks such as coffee, tea and red wine for 2 hours.",
+		},
+		2: {
+			ID:          2,
+			Name:        "Dental Filling",
+			Duration:    60,
+			Price:       200,
+			Category:    "Restorative care",
+			Description: "The decayed part of the tooth is removed under local anaesthesia and the cavity is filled with a tooth-coloured composite.",
+			Aftercare:   "Don't eat until the numbness wears off, usually 2 to 3 hours, so you don't bite your cheek or tongue. Some sensitivity to cold for a few days is normal.",
+		},
+		3: {
+			ID:          3,
+			Name:        "Dental Crown",
+			Duration:    90,
+			Price:       300,
+			Deposit:     100,
+			Category:    "Restorative care",
+			Description: "The tooth is shaped under local anaesthesia and covered with a ceramic cap that restores its shape and strength. A temporary crown is fitted until the final one is ready.",
+			Aftercare:   "Avoid sticky and hard food on the temporary crown and floss by sliding the thread out sideways instead of pulling it up.",
+		},
+		4: {
+			ID:           4,
+			Name:         "Dental Implant",
+			Duration:     120,
+			Price:        400,
+			Deposit:      150,
+			BufferBefore: 15,
+			BufferAfter:  15,
+			ResourcesIds: []uint{1},
+			LocationsIds: []uint{1},
+			Category:     "Surgery",
+			Description:  "A titanium post is placed in the jawbone under local anaesthesia to replace the root of a missing tooth. The crown is fitted a few months later, once the implant has healed.",
+			Preparation:  "Bring a recent X-ray if you have one and tell us about any medication you take, especially blood thinners. Don't smoke for 24 hours before.",
+			Aftercare:    "Eat soft food and don't smoke for at least a week. Rinse gently with salt water from the day after the surgery.",
+			MinAge:       18,
+		},
+		5: {
+			ID:          5,
+			Name:        "Dental Extraction",
+			Duration:    45,
+			Price:       150,
+			BufferAfter: 15,
+			Category:    "Surgery",
+			Description: "The tooth is removed under local anaesthesia, for teeth that are too damaged to be restored or wisdom teeth causing problems.",
+			Preparation: "No fasting is needed, have a light meal beforehand. Tell us about any medication you take, especially blood thinners.",
+			Aftercare:   "Bite on the gauze for 30 minutes, don't rinse, spit or drink through a straw for 24 hours and avoid hot food and drinks on the first day.",
+		},
+		6: {
+			ID:           6,
+			Name:         "Dental X-Ray",
+			Duration:     15,
+			Price:        50,
+			ResourcesIds: []uint{2},
+			LocationsIds: []uint{1},
+			Category:     "Diagnostics",
+			Description:  "A panoramic X-ray of the whole mouth, showing the teeth, the roots and the jawbone.",
+			Preparation:  "Tell us if you are or might be pregnant.",
+		},
+	}
+
+	// regular clinic hours, with a lunch break
+	weekdaySchedule := repository.WeeklySchedule{
+		time.Monday:    {{Start: "09:00", End: "13:00"}, {Start: "14:00", End: "18:00"}},
+		time.Tuesday:   {{Start: "09:00", End: "13:00"}, {Start: "14:00", End: "18:00"}},
+		time.Wednesday: {{Start: "09:00", End: "13:00"}, {Start: "14:00", End: "18:00"}},
+		time.Thursday:  {{Start: "09:00", End: "13:00"}, {Start: "14:00", End: "18:00"}},
+		time.Friday:    {{Start: "09:00", End: "13:00"}, {Start: "14:00", End: "18:00"}},
+	}
+
+	// employees for a dental clinic
+	employees := map[uint]*repository.Employee{
+		1: {
+			ID:          1,
+			Name:        "Alice",
+			ServicesIds: []uint{1, 2, 3, 5},
+			Schedule:    weekdaySchedule,
+			Locations:   weekdayLocations(1),
+		},
+		2: {
+			ID:          2,
+			Name:        "Bob",
+			ServicesIds: []uint{1, 4},
+			Schedule: repository.WeeklySchedule{
+				time.Monday:    {{Start: "12:00", End: "20:00"}},
+				time.Wednesday: {{Start: "12:00", End: "20:00"}},
+				time.Friday:    {{Start: "12:00", End: "20:00"}},
+			},
+			Locations: repository.WeeklyLocations{
+				time.Monday:    1,
+				time.Wednesday: 2,
+				time.Friday:    2,
+			},
+		},
+		3: {
+			ID:          3,
+			Name:        "Charlie",
+			ServicesIds: []uint{1, 2, 3, 4},
+			Schedule:    weekdaySchedule,
+			Locations: repository.WeeklyLocations{
+				time.Monday:    2,
+				time.Tuesday:   2,
+				time.Wednesday: 2,
+				time.Thursday:  2,
+				time.Friday:    1,
+			},
+		},
+		4: {
+			ID:          4,
+			Name:        "David",
+			ServicesIds: []uint{1, 4, 5},
+			Schedule: repository.WeeklySchedule{
+				time.Tuesday:  {{Start: "08:00", End: "12:00"}, {Start: "16:00", End: "20:00"}},
+				time.Thursday: {{Start: "08:00", End: "12:00"}, {Start: "16:00", End: "20:00"}},
+				time.Saturday: {{Start: "09:00", End: "14:00"}},
+			},
+			Locations: repository.WeeklyLocations{
+				time.Tuesday:  1,
+				time.Thursday: 1,
+				time.Saturday: 1,
+			},
+		},
+		5: {
+			ID:          5,
+			Name:        "George",
+			ServicesIds: []uint{5, 6},
+			Schedule:    weekdaySchedule,
+			Locations:   weekdayLocations(1),
+		},
+	}
+
+	// clinic opening hours and public holidays
+	calendar := repository.BusinessCalendar{
+		OpeningHours: repository.WeeklySchedule{
+			time.Monday:    {{Start: "08:00", End: "20:00"}},
+			time.Tuesday:   {{Start: "08:00", End: "20:00"}},
+			time.Wednesday: {{Start: "08:00", End: "20:00"}},
+			time.Thursday:  {{Start: "08:00", End: "20:00"}},
+			time.Friday:    {{Start: "08:00", End: "20:00"}},
+			time.Saturday:  {{Start: "09:00", End: "14:00"}},
+		},
+		Holidays: []*repository.Holiday{
+			{Name: "New Year's Day", Month: time.January, Day: 1},
+			{Name: "Labour Day", Month: time.May, Day: 1},
+			{Name: "Christmas Day", Month: time.December, Day: 25},
+			{Name: "Boxing Day", Month: time.December, Day: 26},
+		},
+	}
+
+	return &catalog{
+		Timezone:     "Europe/Bucharest",
+		PhoneCountry: "RO",
+		Currency:     "RON",
+		Locations:    locations,
+		Resources:    resources,
+		Services:     services,
+		Employees:    employees,
+		Calendar:     calendar,
+	}
+}
+
+// weekdayLocations assigns an employee to the same branch from Monday to Friday.
+func weekdayLocations(locationId uint) repository.WeeklyLocations {
+	return repository.WeeklyLocations{
+		time.Monday:    locationId,
+		time.Tuesday:   locationId,
+		time.Wednesday: locationId,
+		time.Thursday:  locationId,
+		time.Friday:    locationId,
+	}
+}
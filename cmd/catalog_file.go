@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"valighita/bookings-ai-agent/repository"
+)
+
+// catalogFile is the JSON format of a catalog, see loadCatalogFile. Locations,
+// resources, services and employees are lists whose items refer to each other
+// by id, with the fields named as in the repository types, e.g. servicesIds,
+// except retired.
+type catalogFile struct {
+	Timezone     string                      `json:"timezone"`
+	PhoneCountry string                      `json:"phoneCountry"`
+	Currency     string                      `json:"currency"`
+	Locations    []*fileLocation             `json:"locations"`
+	Resources    []*fileResource             `json:"resources"`
+	Services     []*fileService              `json:"services"`
+	Employees    []*fileEmployee             `json:"employees"`
+	Calendar     repository.BusinessCalendar `json:"calendar"`
+}
+
+// retiredKey takes the place of the Retired field of the repository types in
+// the catalog file, where it is rejected: the items missing from the catalog
+// are the retired ones.
+type retiredKey struct{}
+
+func (retiredKey) UnmarshalJSON([]byte) error {
+	return errors.New(`"retired" is not a catalog field, remove the item from the catalog to retire it`)
+}
+
+type fileLocation struct {
+	repository.Location
+	Retired retiredKey `json:"retired"`
+}
+
+type fileResource struct {
+	repository.Resource
+	Retired retiredKey `json:"retired"`
+}
+
+type fileService struct {
+	repository.Service
+	Retired retiredKey `json:"retired"`
+}
+
+type fileEmployee struct {
+	repository.Employee
+	Retired retiredKey `json:"retired"`
+}
+
+// loadCatalogFile reads a catalog from the JSON file at path. Unknown fields
+// and duplicate ids are rejected; the rest is checked by catalog.validate.
+func loadCatalogFile(path string) (*catalog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var data catalogFile
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("parsing %s: unexpected data after the catalog", path)
+	}
+
+	c := &catalog{
+		Timezone:     data.Timezone,
+		PhoneCountry: data.PhoneCountry,
+		Currency:     data.Currency,
+		Calendar:     data.Calendar,
+	}
+	locations := fileItems(data.Locations, func(l *fileLocation) *repository.Location { return &l.Location })
+	if c.Locations, err = byId("location", locations, func(l *repository.Location) uint { return l.ID }); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	resources := fileItems(data.Resources, func(r *fileResource) *repository.Resource { return &r.Resource })
+	if c.Resources, err = byId("resource", resources, func(r *repository.Resource) uint { return r.ID }); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	services := fileItems(data.Services, func(s *fileService) *repository.Service { return &s.Service })
+	if c.Services, err = byId("service", services, func(s *repository.Service) uint { return s.ID }); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	employees := fileItems(data.Employees, func(e *fileEmployee) *repository.Employee { return &e.Employee })
+	if c.Employees, err = byId("employee", employees, func(e *repository.Employee) uint { return e.ID }); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// fileItems returns the repository items of the items of the catalog file,
+// keeping the empty ones for byId to reject.
+func fileItems[F any, T any](items []*F, item func(*F) *T) []*T {
+	result := make([]*T, len(items))
+	for i, fileItem := range items {
+		if fileItem != nil {
+			result[i] = item(fileItem)
+		}
+	}
+	return result
+}
+
+// byId maps the items by id, rejecting missing and duplicate ids.
+func byId[T any](kind string, items []*T, id func(*T) uint) (map[uint]*T, error) {
+	entries := make(map[uint]*T, len(items))
+	for i, item := range items {
+		if item == nil {
+			return nil, fmt.Errorf("%s %d is empty", kind, i+1)
+		}
+		itemId := id(item)
+		if itemId == 0 {
+			return nil, fmt.Errorf("%s %d has no id", kind, i+1)
+		}
+		if _, ok := entries[itemId]; ok {
+			return nil, fmt.Errorf("duplicate %s id %d", kind, itemId)
+		}
+		entries[itemId] = item
+	}
+	return entries, nil
+}
@@ -1,109 +1,307 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 	"valighita/bookings-ai-agent/agent"
+	"valighita/bookings-ai-agent/payments"
 	"valighita/bookings-ai-agent/repository"
 	memory_repository "valighita/bookings-ai-agent/repository/memory"
+	sqlite_repository "valighita/bookings-ai-agent/repository/sqlite"
 	"valighita/bookings-ai-agent/server"
+	"valighita/bookings-ai-agent/waitlist"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	defaultSqlitePath      = "bookings.db"
+	defaultSlotGranularity = 15 * time.Minute
+	defaultHoldTTL         = 10 * time.Minute
+	defaultClaimTTL        = 30 * time.Minute
+	defaultPaymentTTL      = 30 * time.Minute
+)
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("Error loading .env file: %w", err)
-	}
-
-	bookingsRepository := memory_repository.NewBookingsMemoryRepository()
-
-	// services for a dental clinic
-	servicesRepository := memory_repository.NewServicesMemoryRepository(map[uint]*repository.Service{
-		1: {
-			ID:       1,
-			Name:     "Dental Cleaning",
-			Duration: 30,
-			Price:    100,
-		},
-		2: {
-			ID:       2,
-			Name:     "Dental Filling",
-			Duration: 60,
-			Price:    200,
-		},
-		3: {
-			ID:       3,
-			Name:     "Dental Crown",
-			Duration: 90,
-			Price:    300,
-		},
-		4: {
-			ID:       4,
-			Name:     "Dental Implant",
-			Duration: 120,
-			Price:    400,
-		},
-		5: {
-			ID:       5,
-			Name:     "Dental Extraction",
-			Duration: 45,
-			Price:    150,
-		},
-		6: {
-			ID:       6,
-			Name:     "Dental X-Ray",
-			Duration: 15,
-			Price:    50,
-		},
-	})
-
-	// employees for a dental clinic
-	employeeRepository := memory_repository.NewEmployeeMemoryRepository(bookingsRepository, servicesRepository, map[uint]*repository.Employee{
-		1: {
-			ID:          1,
-			Name:        "Alice",
-			ServicesIds: []uint{1, 2, 3, 5},
-		},
-		2: {
-			ID:          2,
-			Name:        "Bob",
-			ServicesIds: []uint{1, 4},
-		},
-		3: {
-			ID:          3,
-			Name:        "Charlie",
-			ServicesIds: []uint{1, 2, 3, 4},
-		},
-		4: {
-			ID:          4,
-			Name:        "David",
-			ServicesIds: []uint{1, 4, 5},
-		},
-		5: {
-			ID:          5,
-			Name:        "George",
-			ServicesIds: []uint{5, 6},
-		},
-	})
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	configs, err := loadTenantConfigs(os.Getenv("TENANTS_FILE"))
+	if err != nil {
+		log.Fatalf("Error loading tenants: %v", err)
+	}
+
+	paymentProvider := os.Getenv("PAYMENT_PROVIDER")
+	if err := checkPaymentProvider(paymentProvider); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(configs, paymentProvider != "", os.Args[2:]))
+	}
+	if paymentProvider == "fake" {
+		log.Printf("Using the fake payment provider, opening a payment link pays it: don't use it to take real payments")
+	}
+
+	slotGranularity := defaultSlotGranularity
+	if slotMinutesStr := os.Getenv("SLOT_GRANULARITY_MINUTES"); slotMinutesStr != "" {
+		slotMinutes, err := strconv.Atoi(slotMinutesStr)
+		if err != nil || slotMinutes <= 0 {
+			log.Fatalf("SLOT_GRANULARITY_MINUTES must be a positive integer")
+		}
+		slotGranularity = time.Duration(slotMinutes) * time.Minute
+	}
+
+	holdTTL := defaultHoldTTL
+	if holdMinutesStr := os.Getenv("HOLD_TTL_MINUTES"); holdMinutesStr != "" {
+		holdMinutes, err := strconv.Atoi(holdMinutesStr)
+		if err != nil || holdMinutes <= 0 {
+			log.Fatalf("HOLD_TTL_MINUTES must be a positive integer")
+		}
+		holdTTL = time.Duration(holdMinutes) * time.Minute
+	}
+
+	claimTTL := defaultClaimTTL
+	if claimMinutesStr := os.Getenv("WAITLIST_CLAIM_MINUTES"); claimMinutesStr != "" {
+		claimMinutes, err := strconv.Atoi(claimMinutesStr)
+		if err != nil || claimMinutes <= 0 {
+			log.Fatalf("WAITLIST_CLAIM_MINUTES must be a positive integer")
+		}
+		claimTTL = time.Duration(claimMinutes) * time.Minute
+	}
+
+	paymentTTL := defaultPaymentTTL
+	if paymentMinutesStr := os.Getenv("PAYMENT_TTL_MINUTES"); paymentMinutesStr != "" {
+		paymentMinutes, err := strconv.Atoi(paymentMinutesStr)
+		if err != nil || paymentMinutes <= 0 {
+			log.Fatalf("PAYMENT_TTL_MINUTES must be a positive integer")
+		}
+		paymentTTL = time.Duration(paymentMinutes) * time.Minute
+	}
 
 	debugMode := os.Getenv("DEBUG_MODE") == "true"
-	agentTools := agent.GetAgentTools(bookingsRepository, servicesRepository, employeeRepository, debugMode)
-	agentFactory := agent.NewOpenaiAgentFactory(agentTools, debugMode)
+	tenants := make([]*server.Tenant, 0, len(configs))
+	configReloader := &reloader{hasPaymentProvider: paymentProvider != ""}
+	for _, config := range configs {
+		tenantCatalog, err := config.loadCatalog()
+		if err != nil {
+			log.Fatalf("Error loading the catalog of tenant %s: %v", config.ID, err)
+		}
+		repositories, err := newRepositories(os.Getenv("STORAGE_BACKEND"), tenantCatalog, paymentProvider != "", config.SqlitePath, slotGranularity)
+		if err != nil {
+			log.Fatalf("Error creating repositories for tenant %s: %v", config.ID, err)
+		}
+
+		var notifier waitlist.Notifier = waitlist.NewLogNotifier()
+		if config.WaitlistWebhook != "" {
+			notifier = waitlist.NewWebhookNotifier(config.WaitlistWebhook)
+		}
+		customerWaitlist := waitlist.NewWaitlist(repositories, notifier, claimTTL)
+		go customerWaitlist.Run(context.Background())
+
+		// Without a provider the catalog has no deposits, see validate, and
+		// the payment callback isn't served
+		provider := newPaymentProvider(paymentProvider, config.PublicURL+"/payments/callback")
+		deposits := payments.NewPayments(repositories, provider, tenantCatalog.Currency, paymentTTL, customerWaitlist)
+		go deposits.Run(context.Background())
+		var paymentCallback http.Handler
+		if provider != nil {
+			paymentCallback = deposits.Handler()
+		}
+
+		agentTools := agent.GetAgentTools(repositories, customerWaitlist, deposits, holdTTL, debugMode)
+		agentFactory := agent.NewOpenaiAgentFactory(agentTools, config.Prompt, repositories.Timezone, slotGranularity, debugMode)
+		configReloader.tenants = append(configReloader.tenants, &runningTenant{
+			id:           config.ID,
+			catalog:      tenantCatalog,
+			prompt:       config.Prompt,
+			repositories: repositories,
+			agentFactory: agentFactory,
+		})
+		tenants = append(tenants, &server.Tenant{
+			ID:              config.ID,
+			Name:            config.Name,
+			Hostnames:       config.Hostnames,
+			Username:        config.Username,
+			Password:        config.Password,
+			AgentFactory:    agentFactory,
+			PaymentCallback: paymentCallback,
+		})
+	}
+
+	go configReloader.reloadOnSignal()
 
 	if len(os.Args) > 1 && os.Args[1] == "cli" {
-		runCli(agentFactory)
+		tenant := tenants[0]
+		if len(os.Args) > 2 {
+			tenant = findTenant(tenants, os.Args[2])
+			if tenant == nil {
+				log.Fatalf("Unknown tenant %s", os.Args[2])
+			}
+		}
+		runCli(tenant.AgentFactory)
 	} else {
-		server.RunHttpServer(agentFactory)
+		server.RunHttpServer(tenants, configReloader.reload)
+	}
+}
+
+func findTenant(tenants []*server.Tenant, id string) *server.Tenant {
+	for _, tenant := range tenants {
+		if tenant.ID == id {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// checkPaymentProvider checks the name of the payment provider: empty when
+// none is configured, or fake for development.
+func checkPaymentProvider(name string) error {
+	switch name {
+	case "", "fake":
+		return nil
+	default:
+		return fmt.Errorf("unknown PAYMENT_PROVIDER %q, expected fake or none", name)
+	}
+}
+
+// newPaymentProvider creates the payment provider of a tenant, checked by
+// checkPaymentProvider, whose callbacks come in at callbackURL. It returns nil
+// when none is configured.
+func newPaymentProvider(name string, callbackURL string) payments.Provider {
+	if name == "fake" {
+		return payments.NewFakeProvider(callbackURL)
+	}
+	return nil
+}
+
+// newRepositories creates the repositories of a business for the given
+// storage backend, seeded with its catalog. With the sqlite backend the data
+// is stored at sqlitePath.
+func newRepositories(backend string, catalog *catalog, hasPaymentProvider bool, sqlitePath string, slotGranularity time.Duration) (*repository.Repositories, error) {
+	if err := catalog.validate(hasPaymentProvider); err != nil {
+		return nil, err
+	}
+	timezone, err := time.LoadLocation(catalog.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	switch backend {
+	case "", "memory":
+		locationsRepository := memory_repository.NewLocationsMemoryRepository(catalog.Locations)
+		resourcesRepository := memory_repository.NewResourcesMemoryRepository(catalog.Resources)
+		servicesRepository := memory_repository.NewServicesMemoryRepository(catalog.Services)
+		calendarRepository := memory_repository.NewCalendarMemoryRepository(catalog.Calendar)
+		customersRepository := memory_repository.NewCustomersMemoryRepository()
+		bookingsRepository := memory_repository.NewBookingsMemoryRepository(servicesRepository, calendarRepository, resourcesRepository, locationsRepository, customersRepository, timezone)
+		employeeRepository := memory_repository.NewEmployeeMemoryRepository(bookingsRepository, servicesRepository, calendarRepository, resourcesRepository, locationsRepository, slotGranularity, timezone, catalog.Employees)
+		catalogRepository := memory_repository.NewCatalogMemoryRepository(locationsRepository, resourcesRepository, servicesRepository, employeeRepository)
+		return &repository.Repositories{
+			Bookings:     bookingsRepository,
+			Services:     servicesRepository,
+			Employees:    employeeRepository,
+			Calendar:     calendarRepository,
+			Resources:    resourcesRepository,
+			Locations:    locationsRepository,
+			Waitlist:     memory_repository.NewWaitlistMemoryRepository(),
+			Customers:    customersRepository,
+			Catalog:      catalogRepository,
+			Timezone:     timezone,
+			PhoneCountry: catalog.PhoneCountry,
+		}, nil
+
+	case "sqlite":
+		db, err := sqlite_repository.Open(sqlitePath)
+		if err != nil {
+			return nil, fmt.Errorf("opening sqlite database: %w", err)
+		}
+
+		catalogRepository, err := sqlite_repository.NewCatalogSqliteRepository(db, catalog.items())
+		if err != nil {
+			return nil, fmt.Errorf("seeding the catalog: %w", err)
+		}
+		locationsRepository := sqlite_repository.NewLocationsSqliteRepository(db)
+		resourcesRepository := sqlite_repository.NewResourcesSqliteRepository(db)
+		servicesRepository := sqlite_repository.NewServicesSqliteRepository(db)
+		calendarRepository, err := sqlite_repository.NewCalendarSqliteRepository(db, catalog.Calendar)
+		if err != nil {
+			return nil, fmt.Errorf("seeding calendar: %w", err)
+		}
+		customersRepository, err := sqlite_repository.NewCustomersSqliteRepository(db, timezone, catalog.PhoneCountry)
+		if err != nil {
+			return nil, fmt.Errorf("linking bookings to customers: %w", err)
+		}
+		bookingsRepository := sqlite_repository.NewBookingsSqliteRepository(db, calendarRepository, locationsRepository, timezone)
+		employeeRepository := sqlite_repository.NewEmployeeSqliteRepository(db, servicesRepository, calendarRepository, locationsRepository, slotGranularity, timezone)
+		return &repository.Repositories{
+			Bookings:     bookingsRepository,
+			Services:     servicesRepository,
+			Employees:    employeeRepository,
+			Calendar:     calendarRepository,
+			Resources:    resourcesRepository,
+			Locations:    locationsRepository,
+			Waitlist:     sqlite_repository.NewWaitlistSqliteRepository(db, timezone),
+			Customers:    customersRepository,
+			Catalog:      catalogRepository,
+			Timezone:     timezone,
+			PhoneCountry: catalog.PhoneCountry,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q, expected memory or sqlite", backend)
+	}
+}
+
+// runValidate checks the given catalog files, or else the catalogs of the
+// tenants, without starting the server. It returns the exit code.
+func runValidate(configs []*tenantConfig, hasPaymentProvider bool, paths []string) int {
+	status := 0
+	if len(paths) > 0 {
+		for _, path := range paths {
+			// The errors of loadCatalogFile already name the file
+			fileCatalog, err := loadCatalogFile(path)
+			if err != nil {
+				fmt.Println(err)
+				status = 1
+				continue
+			}
+			if err := fileCatalog.validate(hasPaymentProvider); err != nil {
+				fmt.Printf("%s: %v\n", path, err)
+				status = 1
+				continue
+			}
+			fmt.Printf("%s: ok\n", path)
+		}
+		return status
+	}
+
+	for _, config := range configs {
+		tenantCatalog, err := config.loadCatalog()
+		if err == nil {
+			err = tenantCatalog.validate(hasPaymentProvider)
+		}
+		if err != nil {
+			fmt.Printf("tenant %s: %v\n", config.ID, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("tenant %s: ok\n", config.ID)
 	}
+	return status
 }
 
 func runCli(agentFactory agent.AgentFactory) {
 	agent, err := agentFactory.CreateAgent()
 	if err != nil {
-		log.Fatalf("Error creating agent: %w", err)
+		log.Fatalf("Error creating agent: %v", err)
 	}
 
 	for {
@@ -112,12 +310,12 @@ func runCli(agentFactory agent.AgentFactory) {
 
 		n, err := os.Stdin.Read(buffer)
 		if err != nil {
-			log.Fatalf("Error reading input: %w", err)
+			log.Fatalf("Error reading input: %v", err)
 		}
 
 		response, err := agent.GetCompletion(string(buffer[:n]))
 		if err != nil {
-			log.Fatalf("Error getting completion: %w", err)
+			log.Fatalf("Error getting completion: %v", err)
 		}
 
 		fmt.Printf("Response: %s\n", response)
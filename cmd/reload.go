@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"valighita/bookings-ai-agent/agent"
+	"valighita/bookings-ai-agent/repository"
+)
+
+// runningTenant is a tenant being served, with what a reload can change.
+type runningTenant struct {
+	id           string
+	catalog      *catalog
+	prompt       string
+	repositories *repository.Repositories
+	agentFactory agent.AgentFactory
+}
+
+// apply switches the tenant to the catalog and prompt. The new chats get both
+// at once: none of them is created in between.
+func (t *runningTenant) apply(next *catalog, prompt string) error {
+	err := t.agentFactory.Reload(prompt, func() error {
+		return t.repositories.Catalog.SetCatalog(next.items())
+	})
+	if err != nil {
+		return err
+	}
+	t.catalog = next
+	t.prompt = prompt
+	return nil
+}
+
+// reloader reloads the catalogs and prompts of the running tenants from their
+// configuration, without restarting the server: open chats and bookings are
+// kept, new chats use the new prompt.
+type reloader struct {
+	// mu serializes the reloads
+	mu      sync.Mutex
+	tenants []*runningTenant
+	// hasPaymentProvider tells whether the services can take deposits
+	hasPaymentProvider bool
+}
+
+// reloadOnSignal reloads the configuration on every SIGHUP.
+func (r *reloader) reloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := r.reload(); err != nil {
+			log.Printf("Error reloading the configuration, keeping the current one: %v", err)
+		}
+	}
+}
+
+// reload reads the tenants and their catalogs again and applies the new
+// locations, resources, services, employees and prompts. Everything is checked
+// first: if anything is invalid, or changes what can't be reloaded, nothing is
+// applied and the current configuration keeps serving. If applying fails for
+// a tenant, the ones already reloaded are switched back.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	configs, err := loadTenantConfigs(os.Getenv("TENANTS_FILE"))
+	if err != nil {
+		return err
+	}
+
+	byId := make(map[string]*tenantConfig, len(configs))
+	for _, config := range configs {
+		byId[config.ID] = config
+	}
+	if len(configs) != len(r.tenants) {
+		return fmt.Errorf("adding or removing tenants requires a restart")
+	}
+
+	catalogs := make([]*catalog, len(r.tenants))
+	for i, tenant := range r.tenants {
+		config, ok := byId[tenant.id]
+		if !ok {
+			return fmt.Errorf("tenant %s was removed, adding or removing tenants requires a restart", tenant.id)
+		}
+		newCatalog, err := config.loadCatalog()
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.id, err)
+		}
+		if err := newCatalog.validate(r.hasPaymentProvider); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.id, err)
+		}
+		if err := checkReloadable(tenant.catalog, newCatalog); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.id, err)
+		}
+		catalogs[i] = newCatalog
+	}
+
+	previous := make([]runningTenant, len(r.tenants))
+	for i, tenant := range r.tenants {
+		previous[i] = *tenant
+	}
+	for i, tenant := range r.tenants {
+		if err := tenant.apply(catalogs[i], byId[tenant.id].Prompt); err != nil {
+			r.rollback(previous[:i])
+			return fmt.Errorf("tenant %s: reloading the catalog: %w", tenant.id, err)
+		}
+	}
+
+	log.Printf("Configuration reloaded for %d tenant(s)\n", len(r.tenants))
+	return nil
+}
+
+// rollback switches the first tenants back to their previous catalog and
+// prompt, after a failed reload.
+func (r *reloader) rollback(previous []runningTenant) {
+	for i, tenant := range previous {
+		if err := r.tenants[i].apply(tenant.catalog, tenant.prompt); err != nil {
+			log.Printf("Error restoring the configuration of tenant %s: %v", tenant.id, err)
+		}
+	}
+}
+
+// checkReloadable checks that the new catalog only changes what can be
+// reloaded. The time zone, phone country and currency are used by every
+// repository and the payments, and the calendar isn't reloaded.
+func checkReloadable(current *catalog, next *catalog) error {
+	if next.Timezone != current.Timezone {
+		return fmt.Errorf("changing the time zone requires a restart")
+	}
+	if next.PhoneCountry != current.PhoneCountry {
+		return fmt.Errorf("changing the phone country requires a restart")
+	}
+	if next.Currency != current.Currency {
+		return fmt.Errorf("changing the currency requires a restart")
+	}
+
+	// Compared as JSON, the times of the closures have new *time.Location
+	currentCalendar, err := json.Marshal(current.Calendar)
+	if err != nil {
+		return err
+	}
+	nextCalendar, err := json.Marshal(next.Calendar)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(currentCalendar, nextCalendar) {
+		return fmt.Errorf("changing the calendar requires a restart")
+	}
+	return nil
+}
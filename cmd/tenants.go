@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultTenantID   = "default"
+	defaultTenantName = "Dental clinic"
+	defaultCatalog    = "dental"
+)
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// tenantConfig describes a business hosted by the server. Every tenant gets
+// its own repositories, so their data is never shared.
+type tenantConfig struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Hostnames []string `json:"hostnames"`
+	// Prompt introduces the business to the agent, empty for the default one
+	Prompt   string `json:"prompt"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Catalog is the name of a built-in catalog, dental by default
+	Catalog string `json:"catalog"`
+	// CatalogFile is the path of a JSON catalog used instead of a built-in one
+	CatalogFile string `json:"catalogFile"`
+	// Timezone is the IANA name of the business time zone, overriding the one
+	// of the catalog when set
+	Timezone string `json:"timezone"`
+	// PhoneCountry is the ISO 3166 code of the country of the national phone
+	// numbers, overriding the one of the catalog when set
+	PhoneCountry string `json:"phoneCountry"`
+	// SqlitePath is the database of the tenant with the sqlite backend,
+	// <id>.db by default
+	SqlitePath string `json:"sqlitePath"`
+	// WaitlistWebhook receives the waitlist offers to send to the customers,
+	// they are only logged when empty
+	WaitlistWebhook string `json:"waitlistWebhook"`
+	// PublicURL is where the customers reach the tenant, used for the payment
+	// links. By default the tenant path on localhost.
+	PublicURL string `json:"publicUrl"`
+}
+
+// loadTenantConfigs reads the tenants from the JSON file at path. When path is
+// empty, a single tenant is configured from the env vars.
+func loadTenantConfigs(path string) ([]*tenantConfig, error) {
+	if path == "" {
+		sqlitePath := os.Getenv("SQLITE_PATH")
+		if sqlitePath == "" {
+			sqlitePath = defaultSqlitePath
+		}
+		config := &tenantConfig{
+			ID:              defaultTenantID,
+			Name:            defaultTenantName,
+			Username:        os.Getenv("HTTP_SERVER_USERNAME"),
+			Password:        os.Getenv("HTTP_SERVER_PASSWORD"),
+			CatalogFile:     os.Getenv("CATALOG_FILE"),
+			Timezone:        os.Getenv("BUSINESS_TIMEZONE"),
+			PhoneCountry:    os.Getenv("DEFAULT_PHONE_COUNTRY"),
+			SqlitePath:      sqlitePath,
+			WaitlistWebhook: os.Getenv("WAITLIST_WEBHOOK_URL"),
+			PublicURL:       publicURL(defaultTenantID, os.Getenv("PUBLIC_URL")),
+		}
+		if config.CatalogFile == "" {
+			config.Catalog = defaultCatalog
+		}
+		return []*tenantConfig{config}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var configs []*tenantConfig
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&configs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("%s has no tenants", path)
+	}
+
+	ids := map[string]bool{}
+	sqlitePaths := map[string]string{}
+	for _, config := range configs {
+		if !tenantIDPattern.MatchString(config.ID) {
+			return nil, fmt.Errorf("invalid tenant id %q, use lowercase letters, digits and dashes", config.ID)
+		}
+		if ids[config.ID] {
+			return nil, fmt.Errorf("duplicate tenant id %q", config.ID)
+		}
+		ids[config.ID] = true
+
+		if config.Name == "" {
+			config.Name = config.ID
+		}
+		if config.Catalog != "" && config.CatalogFile != "" {
+			return nil, fmt.Errorf("tenant %s: set either catalog or catalogFile", config.ID)
+		}
+		if config.Catalog == "" && config.CatalogFile == "" {
+			config.Catalog = defaultCatalog
+		}
+		if _, ok := builtinCatalogs[config.Catalog]; !ok && config.CatalogFile == "" {
+			return nil, fmt.Errorf("tenant %s: unknown catalog %q", config.ID, config.Catalog)
+		}
+		if config.SqlitePath == "" {
+			config.SqlitePath = config.ID + ".db"
+		}
+		if other, ok := sqlitePaths[config.SqlitePath]; ok {
+			return nil, fmt.Errorf("tenants %s and %s use the same database %s", other, config.ID, config.SqlitePath)
+		}
+		sqlitePaths[config.SqlitePath] = config.ID
+		config.PublicURL = publicURL(config.ID, config.PublicURL)
+	}
+
+	return configs, nil
+}
+
+// loadCatalog returns a fresh copy of the catalog of the tenant, read from
+// its catalog file if it has one, with the time zone and phone country
+// overrides applied. It isn't validated.
+func (c *tenantConfig) loadCatalog() (*catalog, error) {
+	var tenantCatalog *catalog
+	if c.CatalogFile != "" {
+		var err error
+		if tenantCatalog, err = loadCatalogFile(c.CatalogFile); err != nil {
+			return nil, err
+		}
+	} else {
+		tenantCatalog = builtinCatalogs[c.Catalog]()
+	}
+
+	if c.Timezone != "" {
+		tenantCatalog.Timezone = c.Timezone
+	}
+	if c.PhoneCountry != "" {
+		tenantCatalog.PhoneCountry = c.PhoneCountry
+	}
+	return tenantCatalog, nil
+}
+
+// publicURL returns url without the trailing slash, or the path of the tenant
+// on localhost if url is empty.
+func publicURL(tenantId string, url string) string {
+	if url == "" {
+		port := os.Getenv("HTTP_SERVER_PORT")
+		if port == "" {
+			port = "8080"
+		}
+		return fmt.Sprintf("http://localhost:%s/t/%s", port, tenantId)
+	}
+	return strings.TrimSuffix(url, "/")
+}
@@ -0,0 +1,270 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"valighita/bookings-ai-agent/repository"
+	"valighita/bookings-ai-agent/waitlist"
+)
+
+// checkInterval is how often the unpaid bookings are checked for expired
+// payments.
+const checkInterval = time.Minute
+
+var ErrPaymentExpired = errors.New("the payment came in after the bookings expired")
+
+// Deposit is the payment asked for bookings that require a deposit.
+type Deposit struct {
+	PaymentID string
+	Amount    float64
+	Currency  string
+	Link      string
+	// DueAt is when the bookings are cancelled if the deposit isn't paid
+	DueAt time.Time
+}
+
+// Payments asks for the deposits of the bookings that require one, confirms
+// the bookings when the payment provider calls back, and cancels the ones not
+// paid in time, offering their slots to the waitlist.
+type Payments struct {
+	// mu serializes confirming and expiring the bookings, so a late payment
+	// is either confirmed or refunded
+	mu           sync.Mutex
+	repositories *repository.Repositories
+	provider     Provider
+	currency     string
+	paymentTTL   time.Duration
+	waitlist     *waitlist.Waitlist
+}
+
+// NewPayments returns the Payments of a business. provider is nil when none is
+// configured, then no deposit can be requested: the services must not ask for
+// any.
+func NewPayments(repositories *repository.Repositories, provider Provider, currency string, paymentTTL time.Duration, customerWaitlist *waitlist.Waitlist) *Payments {
+	return &Payments{
+		repositories: repositories,
+		provider:     provider,
+		currency:     currency,
+		paymentTTL:   paymentTTL,
+		waitlist:     customerWaitlist,
+	}
+}
+
+// Currency is the currency of the prices and deposits.
+func (p *Payments) Currency() string {
+	return p.currency
+}
+
+// RequestDeposit creates one payment for the deposits of the bookings awaiting
+// payment and links them to it. It returns nil if no deposit is due. The
+// deposit is due within the payment time, and before the first appointment.
+// If the payment can't be requested, the bookings are cancelled since they
+// could never be confirmed.
+func (p *Payments) RequestDeposit(bookings []*repository.Booking) (*Deposit, error) {
+	var unpaid []*repository.Booking
+	var amount float64
+	var ids []string
+	for _, booking := range bookings {
+		if !booking.AwaitingPayment() {
+			continue
+		}
+		unpaid = append(unpaid, booking)
+		amount += booking.Deposit
+		ids = append(ids, fmt.Sprintf("#%d", booking.ID))
+	}
+	if len(unpaid) == 0 {
+		return nil, nil
+	}
+
+	if p.provider == nil {
+		return nil, p.cancelUnpaid(unpaid, errors.New("no payment provider is configured"))
+	}
+
+	dueAt := time.Now().Add(p.paymentTTL)
+	for _, booking := range unpaid {
+		if booking.BookingDateTime.Before(dueAt) {
+			dueAt = booking.BookingDateTime
+		}
+	}
+
+	description := "Deposit for booking " + strings.Join(ids, ", ")
+	payment, err := p.provider.CreatePayment(amount, p.currency, description, dueAt)
+	if err != nil {
+		return nil, p.cancelUnpaid(unpaid, fmt.Errorf("creating payment: %w", err))
+	}
+
+	for _, booking := range unpaid {
+		if err := p.repositories.Bookings.SetBookingPayment(booking.ID, payment.ID, payment.Link, dueAt); err != nil {
+			return nil, p.cancelUnpaid(unpaid, err)
+		}
+		booking.PaymentID = payment.ID
+		booking.PaymentLink = payment.Link
+		booking.PaymentDueAt = dueAt
+	}
+
+	return &Deposit{
+		PaymentID: payment.ID,
+		Amount:    amount,
+		Currency:  p.currency,
+		Link:      payment.Link,
+		DueAt:     dueAt,
+	}, nil
+}
+
+// cancelUnpaid cancels the bookings whose deposit couldn't be requested and
+// returns err.
+func (p *Payments) cancelUnpaid(bookings []*repository.Booking, err error) error {
+	for _, booking := range bookings {
+		if cancelErr := p.repositories.Bookings.SetBookingStatus(booking.ID, repository.BookingCancelled); cancelErr != nil {
+			log.Printf("Error cancelling unpaid booking %d: %v", booking.ID, cancelErr)
+		}
+		booking.Status = repository.BookingCancelled
+	}
+	if p.waitlist != nil {
+		p.waitlist.Wake()
+	}
+	return err
+}
+
+// Confirm confirms the bookings paid by the payment. If all of them expired
+// before the payment came in, it is refunded and ErrPaymentExpired is
+// returned.
+func (p *Payments) Confirm(paymentId string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bookings, err := p.repositories.Bookings.GetBookingsByPaymentId(paymentId)
+	if err != nil {
+		return err
+	}
+
+	paid := 0
+	var amount float64
+	now := time.Now()
+	for _, booking := range bookings {
+		amount += booking.Deposit
+		if !booking.PaidAt.IsZero() {
+			paid++ // The provider called back again
+			continue
+		}
+		if !booking.AwaitingPayment() {
+			continue
+		}
+		if err := p.repositories.Bookings.MarkBookingPaid(booking.ID, now); err != nil {
+			return err
+		}
+		paid++
+	}
+
+	if paid == 0 {
+		if err := p.provider.Refund(paymentId, amount); err != nil {
+			return fmt.Errorf("refunding late payment: %w", err)
+		}
+		return ErrPaymentExpired
+	}
+	return nil
+}
+
+// RefundDeposits gives back the deposits paid for the bookings, once they are
+// cancelled. Bookings whose deposit wasn't paid are skipped.
+func (p *Payments) RefundDeposits(bookings []*repository.Booking) error {
+	// A payment may cover several bookings, e.g. a series, only the deposits
+	// of the cancelled ones are given back
+	refunds := map[string]float64{}
+	var paymentIds []string
+	for _, booking := range bookings {
+		if booking.Deposit <= 0 || booking.PaidAt.IsZero() {
+			continue
+		}
+		if _, ok := refunds[booking.PaymentID]; !ok {
+			paymentIds = append(paymentIds, booking.PaymentID)
+		}
+		refunds[booking.PaymentID] += booking.Deposit
+	}
+
+	if len(paymentIds) > 0 && p.provider == nil {
+		return errors.New("no payment provider is configured")
+	}
+
+	var errs []error
+	for _, paymentId := range paymentIds {
+		if err := p.provider.Refund(paymentId, refunds[paymentId]); err != nil {
+			errs = append(errs, fmt.Errorf("refunding payment %s: %w", paymentId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Handler handles the callbacks of the payment provider.
+func (p *Payments) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paymentId, err := p.provider.ParseCallback(r)
+		if err != nil {
+			log.Printf("Invalid payment callback: %v", err)
+			http.Error(w, "Invalid payment callback", http.StatusBadRequest)
+			return
+		}
+
+		err = p.Confirm(paymentId)
+		switch {
+		case errors.Is(err, repository.ErrPaymentNotFound):
+			http.Error(w, "Payment not found", http.StatusNotFound)
+		case errors.Is(err, ErrPaymentExpired):
+			fmt.Fprintln(w, "The booking expired before the payment, it will be refunded.")
+		case err != nil:
+			log.Printf("Error confirming payment %s: %v", paymentId, err)
+			http.Error(w, "Error confirming payment", http.StatusInternalServerError)
+		default:
+			fmt.Fprintln(w, "Payment received, the booking is confirmed.")
+		}
+	})
+}
+
+// Run cancels the bookings whose deposit wasn't paid in time, periodically
+// until ctx is done.
+func (p *Payments) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Process(); err != nil {
+			log.Printf("Error expiring unpaid bookings: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process cancels the bookings whose deposit wasn't paid in time and wakes up
+// the waitlist for the freed slots.
+func (p *Payments) Process() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bookings, err := p.repositories.Bookings.GetUnpaidBookings(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, booking := range bookings {
+		if err := p.repositories.Bookings.SetBookingStatus(booking.ID, repository.BookingCancelled); err != nil {
+			return err
+		}
+		log.Printf("Booking %d cancelled, its deposit wasn't paid by %s\n", booking.ID, booking.PaymentDueAt.Format("2006-01-02 15:04"))
+	}
+
+	if len(bookings) > 0 && p.waitlist != nil {
+		p.waitlist.Wake()
+	}
+	return nil
+}
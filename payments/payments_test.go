@@ -0,0 +1,259 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+	memory_repository "valighita/bookings-ai-agent/repository/memory"
+)
+
+func allWeek() repository.WeeklySchedule {
+	schedule := repository.WeeklySchedule{}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		schedule[weekday] = []repository.WorkingHours{{Start: "09:00", End: "17:00"}}
+	}
+	return schedule
+}
+
+// newTestPayments returns the Payments of a business with a service that asks
+// for a deposit of 10 and one that doesn't, with the fake provider.
+func newTestPayments(paymentTTL time.Duration) (*Payments, *FakeProvider) {
+	services := memory_repository.NewServicesMemoryRepository(map[uint]*repository.Service{
+		1: {ID: 1, Name: "Implant consultation", Duration: 30, Price: 50, Deposit: 10},
+		2: {ID: 2, Name: "Checkup", Duration: 30, Price: 30},
+	})
+	calendar := memory_repository.NewCalendarMemoryRepository(repository.BusinessCalendar{})
+	resources := memory_repository.NewResourcesMemoryRepository(map[uint]*repository.Resource{})
+	locations := memory_repository.NewLocationsMemoryRepository(map[uint]*repository.Location{})
+	bookings := memory_repository.NewBookingsMemoryRepository(services, calendar, resources, locations, memory_repository.NewCustomersMemoryRepository(), time.UTC)
+	employees := memory_repository.NewEmployeeMemoryRepository(bookings, services, calendar, resources, locations, 15*time.Minute, time.UTC, map[uint]*repository.Employee{
+		1: {ID: 1, Name: "Alice", ServicesIds: []uint{1, 2}, Schedule: allWeek()},
+	})
+
+	repositories := &repository.Repositories{
+		Bookings:  bookings,
+		Services:  services,
+		Employees: employees,
+		Calendar:  calendar,
+		Resources: resources,
+		Locations: locations,
+		Timezone:  time.UTC,
+	}
+	provider := NewFakeProvider("http://localhost/payments/callback")
+	return NewPayments(repositories, provider, "EUR", paymentTTL, nil), provider
+}
+
+// book reserves the service at hour tomorrow.
+func book(t *testing.T, p *Payments, serviceId uint, hour int) *repository.Booking {
+	t.Helper()
+
+	tomorrow := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	booking := &repository.Booking{
+		EmployeeID:      1,
+		ServiceID:       serviceId,
+		BookingDateTime: tomorrow.Add(time.Duration(hour) * time.Hour),
+		CustomerName:    "Jane",
+	}
+	if err := p.repositories.Bookings.ReserveBooking(booking); err != nil {
+		t.Fatalf("booking service %d: %v", serviceId, err)
+	}
+	return booking
+}
+
+func status(t *testing.T, p *Payments, id uint) *repository.Booking {
+	t.Helper()
+
+	booking, err := p.repositories.Bookings.GetBookingById(id)
+	if err != nil {
+		t.Fatalf("GetBookingById(%d): %v", id, err)
+	}
+	return booking
+}
+
+func TestRequestDeposit(t *testing.T) {
+	p, provider := newTestPayments(30 * time.Minute)
+	first := book(t, p, 1, 10)
+	second := book(t, p, 1, 11)
+	free := book(t, p, 2, 12)
+
+	deposit, err := p.RequestDeposit([]*repository.Booking{first, second, free})
+	if err != nil {
+		t.Fatalf("RequestDeposit: %v", err)
+	}
+	if deposit.Amount != 20 || deposit.Currency != "EUR" {
+		t.Errorf("deposit = %.2f %s, want 20.00 EUR", deposit.Amount, deposit.Currency)
+	}
+	if until := time.Until(deposit.DueAt); until <= 0 || until > 30*time.Minute {
+		t.Errorf("deposit due in %v, want within the payment time", until)
+	}
+	if payment := provider.GetPayment(deposit.PaymentID); payment == nil || payment.Amount != 20 || payment.Link != deposit.Link {
+		t.Errorf("provider payment = %+v, want 20 with the deposit link", payment)
+	}
+
+	for _, booking := range []*repository.Booking{first, second} {
+		stored := status(t, p, booking.ID)
+		if stored.PaymentID != deposit.PaymentID || stored.Status != repository.BookingPending {
+			t.Errorf("booking %d: payment %q, %s, want %q, pending", booking.ID, stored.PaymentID, stored.Status, deposit.PaymentID)
+		}
+	}
+	if stored := status(t, p, free.ID); stored.PaymentID != "" || stored.Status != repository.BookingConfirmed {
+		t.Errorf("booking without deposit: payment %q, %s, want none, confirmed", stored.PaymentID, stored.Status)
+	}
+
+	deposit, err = p.RequestDeposit([]*repository.Booking{free})
+	if deposit != nil || err != nil {
+		t.Errorf("RequestDeposit(no deposit due) = %+v, %v, want nil", deposit, err)
+	}
+}
+
+func TestRequestDepositWithoutProvider(t *testing.T) {
+	p, _ := newTestPayments(30 * time.Minute)
+	p.provider = nil
+	booking := book(t, p, 1, 10)
+
+	if _, err := p.RequestDeposit([]*repository.Booking{booking}); err == nil {
+		t.Fatal("RequestDeposit without a provider succeeded")
+	}
+	if stored := status(t, p, booking.ID); stored.Status != repository.BookingCancelled {
+		t.Errorf("booking is %s, want it cancelled", stored.Status)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	p, provider := newTestPayments(30 * time.Minute)
+	first := book(t, p, 1, 10)
+	second := book(t, p, 1, 11)
+	deposit, err := p.RequestDeposit([]*repository.Booking{first, second})
+	if err != nil {
+		t.Fatalf("RequestDeposit: %v", err)
+	}
+
+	// The provider may call back more than once
+	for range 2 {
+		if err := p.Confirm(deposit.PaymentID); err != nil {
+			t.Fatalf("Confirm: %v", err)
+		}
+	}
+	for _, booking := range []*repository.Booking{first, second} {
+		stored := status(t, p, booking.ID)
+		if stored.Status != repository.BookingConfirmed || stored.PaidAt.IsZero() {
+			t.Errorf("booking %d: %s, paid at %v, want it confirmed and paid", booking.ID, stored.Status, stored.PaidAt)
+		}
+	}
+	if refunded := provider.GetPayment(deposit.PaymentID).Refunded; refunded != 0 {
+		t.Errorf("refunded %.2f of a payment on time", refunded)
+	}
+
+	if err := p.Confirm("unknown"); !errors.Is(err, repository.ErrPaymentNotFound) {
+		t.Errorf("Confirm(unknown) = %v, want ErrPaymentNotFound", err)
+	}
+}
+
+func TestProcessAndLatePayment(t *testing.T) {
+	// The deposit is overdue as soon as it is requested
+	p, provider := newTestPayments(-time.Minute)
+	overdue := book(t, p, 1, 10)
+	free := book(t, p, 2, 11)
+	deposit, err := p.RequestDeposit([]*repository.Booking{overdue})
+	if err != nil {
+		t.Fatalf("RequestDeposit: %v", err)
+	}
+
+	if err := p.Process(); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if stored := status(t, p, overdue.ID); stored.Status != repository.BookingCancelled {
+		t.Errorf("overdue booking is %s, want it cancelled", stored.Status)
+	}
+	if stored := status(t, p, free.ID); stored.Status != repository.BookingConfirmed {
+		t.Errorf("booking without deposit is %s, want it kept", stored.Status)
+	}
+
+	// The slot is free again
+	book(t, p, 2, 10)
+
+	if err := p.Confirm(deposit.PaymentID); !errors.Is(err, ErrPaymentExpired) {
+		t.Fatalf("Confirm(late) = %v, want ErrPaymentExpired", err)
+	}
+	if refunded := provider.GetPayment(deposit.PaymentID).Refunded; refunded != 10 {
+		t.Errorf("refunded %.2f of the late payment, want 10", refunded)
+	}
+	if stored := status(t, p, overdue.ID); stored.Status != repository.BookingCancelled || !stored.PaidAt.IsZero() {
+		t.Errorf("late paid booking: %s, paid at %v, want it cancelled and unpaid", stored.Status, stored.PaidAt)
+	}
+}
+
+func TestRefundDeposits(t *testing.T) {
+	p, provider := newTestPayments(30 * time.Minute)
+	first := book(t, p, 1, 10)
+	second := book(t, p, 1, 11)
+	unpaid := book(t, p, 1, 12)
+	deposit, err := p.RequestDeposit([]*repository.Booking{first, second})
+	if err != nil {
+		t.Fatalf("RequestDeposit: %v", err)
+	}
+	if _, err := p.RequestDeposit([]*repository.Booking{unpaid}); err != nil {
+		t.Fatalf("RequestDeposit: %v", err)
+	}
+	if err := p.Confirm(deposit.PaymentID); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+
+	// Only the deposit of the cancelled booking is given back
+	if err := p.RefundDeposits([]*repository.Booking{status(t, p, first.ID), status(t, p, unpaid.ID)}); err != nil {
+		t.Fatalf("RefundDeposits: %v", err)
+	}
+	if refunded := provider.GetPayment(deposit.PaymentID).Refunded; refunded != 10 {
+		t.Errorf("refunded %.2f, want the 10 of one booking", refunded)
+	}
+	if refunded := provider.GetPayment(status(t, p, unpaid.ID).PaymentID).Refunded; refunded != 0 {
+		t.Errorf("refunded %.2f of an unpaid deposit", refunded)
+	}
+
+	// More than was paid can't be refunded
+	if err := p.RefundDeposits([]*repository.Booking{status(t, p, first.ID), status(t, p, first.ID), status(t, p, second.ID)}); err == nil {
+		t.Error("RefundDeposits refunded more than the payment")
+	}
+}
+
+func TestHandlerChecksTheSignature(t *testing.T) {
+	p, _ := newTestPayments(30 * time.Minute)
+	booking := book(t, p, 1, 10)
+	deposit, err := p.RequestDeposit([]*repository.Booking{booking})
+	if err != nil {
+		t.Fatalf("RequestDeposit: %v", err)
+	}
+	link, err := url.Parse(deposit.Link)
+	if err != nil {
+		t.Fatalf("parsing the link: %v", err)
+	}
+
+	forged := url.Values{"payment": {deposit.PaymentID}, "signature": {"00"}}
+	for name, query := range map[string]string{
+		"no signature":     url.Values{"payment": {deposit.PaymentID}}.Encode(),
+		"forged signature": forged.Encode(),
+	} {
+		recorder := httptest.NewRecorder()
+		p.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/payments/callback?"+query, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", name, recorder.Code, http.StatusBadRequest)
+		}
+	}
+	if stored := status(t, p, booking.ID); !stored.PaidAt.IsZero() {
+		t.Fatal("an unsigned callback paid the booking")
+	}
+
+	recorder := httptest.NewRecorder()
+	p.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/payments/callback?"+link.RawQuery, nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("signed link: status %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if stored := status(t, p, booking.ID); stored.PaidAt.IsZero() {
+		t.Error("the signed link didn't pay the booking")
+	}
+}
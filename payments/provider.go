@@ -0,0 +1,152 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Payment is a request for the customer to pay an amount online, e.g. a
+// deposit.
+type Payment struct {
+	ID string
+	// Link is the page where the customer pays
+	Link string
+}
+
+// Provider creates payment links and tells which payment a callback from the
+// payment page is about, e.g. a card processor.
+type Provider interface {
+	// CreatePayment creates a payment for amount in currency, payable until
+	// expiresAt.
+	CreatePayment(amount float64, currency string, description string, expiresAt time.Time) (*Payment, error)
+	// ParseCallback returns the ID of the payment a callback confirms as paid.
+	// The callback route has no authentication: it must check the callback
+	// comes from the provider, e.g. by its signature.
+	ParseCallback(r *http.Request) (string, error)
+	// Refund gives back amount of a payment, all of it when it came in too
+	// late or the deposit of the bookings cancelled after paying.
+	Refund(paymentId string, amount float64) error
+}
+
+// FakePayment is a payment created by the fake provider.
+type FakePayment struct {
+	Payment
+	Amount      float64
+	Currency    string
+	Description string
+	ExpiresAt   time.Time
+	// Refunded is the amount given back
+	Refunded float64
+}
+
+// FakeProvider is an in-process Provider for development and tests, it must
+// not be used to take real payments. Its payment links point straight to the
+// callback, so opening one pays it; they are signed so the other callbacks are
+// rejected.
+type FakeProvider struct {
+	mu          sync.Mutex
+	callbackURL string
+	secret      []byte
+	payments    map[string]*FakePayment
+	nextId      int
+}
+
+// NewFakeProvider returns a fake Provider whose links are callbackURL with the
+// payment ID and its signature in the query string.
+func NewFakeProvider(callbackURL string) *FakeProvider {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	return &FakeProvider{
+		callbackURL: callbackURL,
+		secret:      secret,
+		payments:    make(map[string]*FakePayment),
+		nextId:      1,
+	}
+}
+
+// sign returns the signature of the payment ID in the links.
+func (p *FakeProvider) sign(id string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *FakeProvider) CreatePayment(amount float64, currency string, description string, expiresAt time.Time) (*Payment, error) {
+	if amount <= 0 {
+		return nil, errors.New("payment amount must be positive")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := fmt.Sprintf("fake-%d", p.nextId)
+	p.nextId++
+	payment := &FakePayment{
+		Payment:     Payment{ID: id, Link: p.callbackURL + "?payment=" + url.QueryEscape(id) + "&signature=" + p.sign(id)},
+		Amount:      amount,
+		Currency:    currency,
+		Description: description,
+		ExpiresAt:   expiresAt,
+	}
+	p.payments[id] = payment
+
+	log.Printf("Fake payment %s of %.2f %s for %s: %s\n", id, amount, currency, description, payment.Link)
+	return &payment.Payment, nil
+}
+
+func (p *FakeProvider) ParseCallback(r *http.Request) (string, error) {
+	id := r.URL.Query().Get("payment")
+	signature, err := hex.DecodeString(r.URL.Query().Get("signature"))
+	if err != nil {
+		return "", fmt.Errorf("invalid signature for payment %q: %w", id, err)
+	}
+	expected, _ := hex.DecodeString(p.sign(id))
+	if !hmac.Equal(signature, expected) {
+		return "", fmt.Errorf("invalid signature for payment %q", id)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.payments[id]; !ok {
+		return "", fmt.Errorf("unknown payment %q", id)
+	}
+	return id, nil
+}
+
+func (p *FakeProvider) Refund(paymentId string, amount float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentId]
+	if !ok {
+		return fmt.Errorf("unknown payment %q", paymentId)
+	}
+	// Half a cent of leeway for the rounding of the deposits added up
+	if amount <= 0 || payment.Refunded+amount > payment.Amount+0.005 {
+		return fmt.Errorf("can't refund %.2f of payment %q, %.2f of %.2f already refunded", amount, paymentId, payment.Refunded, payment.Amount)
+	}
+
+	payment.Refunded += amount
+	log.Printf("Fake payment %s refunded %.2f %s\n", paymentId, amount, payment.Currency)
+	return nil
+}
+
+// GetPayment returns a payment created by the provider, or nil if there is no
+// such payment.
+func (p *FakeProvider) GetPayment(id string) *FakePayment {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.payments[id]
+}
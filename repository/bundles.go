@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaxBundleServices limits the number of services booked in one visit.
+const MaxBundleServices = 5
+
+// ErrBookedInBundle is returned when moving a single booking of a visit, its
+// services must stay back-to-back.
+var ErrBookedInBundle = errors.New("booking is part of a visit with several services, it can't be moved on its own")
+
+// BundleSlot is a visit where several services are performed back-to-back at
+// the same branch: each step starts when the previous one ends, possibly with
+// a different employee.
+type BundleSlot struct {
+	// Steps are the slots of the services, in the order they were requested
+	Steps []*Slot
+}
+
+// BundleStep is a service of a bundle, or of an attendee of a group, and the
+// employees who can perform it.
+type BundleStep struct {
+	Service   *Service
+	Employees []*Employee
+}
+
+// AvailabilityFunc reports whether the employee can perform the service at the
+// branch starting at start, without considering the other steps of a bundle.
+type AvailabilityFunc func(employee *Employee, service *Service, locationId uint, start time.Time) (bool, error)
+
+// FindBundleSlots implements EmployeeRepository.FindBundleSlots. The first
+// steps are tried with the employees in the given order; each bundle starts at
+// a multiple of granularity, and at most one bundle is returned per start
+// time.
+func FindBundleSlots(steps []*BundleStep, locationId uint, from time.Time, to time.Time, granularity time.Duration, limit int, isAvailable AvailabilityFunc) ([]*BundleSlot, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("bundle has no services")
+	}
+	if len(steps) > MaxBundleServices {
+		return nil, fmt.Errorf("a bundle can have at most %d services", MaxBundleServices)
+	}
+
+	bundles := []*BundleSlot{}
+	for start := FirstSlot(from, granularity); start.Before(to); start = start.Add(granularity) {
+		placed, err := placeBundleSteps(steps, locationId, start, nil, isAvailable)
+		if err != nil {
+			return nil, err
+		}
+		if placed == nil {
+			continue
+		}
+
+		bundles = append(bundles, &BundleSlot{Steps: placed})
+		if limit > 0 && len(bundles) == limit {
+			break
+		}
+	}
+
+	return bundles, nil
+}
+
+// placeBundleSteps finds employees for the steps following the placed ones,
+// the next one starting at start. It returns all the placed steps, or nil if
+// the remaining steps can't be placed.
+func placeBundleSteps(steps []*BundleStep, locationId uint, start time.Time, placed []*Slot, isAvailable AvailabilityFunc) ([]*Slot, error) {
+	if len(placed) == len(steps) {
+		return placed, nil
+	}
+
+	step := steps[len(placed)]
+	for _, employee := range step.Employees {
+		// The first step chooses the branch, the next ones must be there too
+		stepLocationId := locationId
+		if len(placed) == 0 {
+			stepLocationId = employee.ResolveLocation(locationId, start.Weekday())
+		}
+
+		if overlapsPlacedSteps(steps, placed, employee, step.Service, start) {
+			continue
+		}
+		available, err := isAvailable(employee, step.Service, stepLocationId, start)
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			continue
+		}
+
+		slot := &Slot{EmployeeID: employee.ID, LocationID: stepLocationId, Start: start}
+		end := start.Add(time.Duration(step.Service.Duration) * time.Minute)
+		result, err := placeBundleSteps(steps, stepLocationId, end, append(slices.Clone(placed), slot), isAvailable)
+		if err != nil || result != nil {
+			return result, err
+		}
+	}
+
+	return nil, nil
+}
+
+// overlapsPlacedSteps reports whether performing the service at start would
+// overlap, buffers included, a placed step using the same employee or a
+// resource it needs. The placed steps aren't booked yet, so isAvailable can't
+// see them.
+func overlapsPlacedSteps(steps []*BundleStep, placed []*Slot, employee *Employee, service *Service, start time.Time) bool {
+	blockedStart, blockedEnd := service.BlockedInterval(start)
+	for i, slot := range placed {
+		placedService := steps[i].Service
+		placedStart, placedEnd := placedService.BlockedInterval(slot.Start)
+		if !blockedStart.Before(placedEnd) || !placedStart.Before(blockedEnd) {
+			continue
+		}
+
+		if slot.EmployeeID == employee.ID {
+			return true
+		}
+		for _, resourceId := range service.ResourcesIds {
+			if slices.Contains(placedService.ResourcesIds, resourceId) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ValidateBundle checks that the bookings of a bundle are for the same phone
+// number at the same branch, each one starting when the previous one ends,
+// and that they don't need the same employee or resource at the same time,
+// buffers included.
+func ValidateBundle(bookings []*Booking) error {
+	if len(bookings) == 0 {
+		return errors.New("bundle has no bookings")
+	}
+	if len(bookings) > MaxBundleServices {
+		return fmt.Errorf("a bundle can have at most %d services", MaxBundleServices)
+	}
+
+	for i := 1; i < len(bookings); i++ {
+		if bookings[i].LocationID != bookings[i-1].LocationID {
+			return errors.New("the services of a bundle must be at the same branch")
+		}
+		if NormalizePhone(bookings[i].CustomerPhone) != NormalizePhone(bookings[0].CustomerPhone) {
+			return errors.New("the services of a bundle must be booked with the same phone number")
+		}
+		if !bookings[i].BookingDateTime.Equal(bookings[i-1].EndDateTime()) {
+			return fmt.Errorf("service %d of the bundle doesn't start when the previous one ends", i+1)
+		}
+	}
+
+	return checkSharing(bookings, "services", "bundle")
+}
+
+// checkSharing returns an error if two of the bookings need the same employee
+// or resource at the same time, buffers included. They aren't stored yet, so
+// the repositories can't detect it. items and whole name the bookings and the
+// group they belong to in the error.
+func checkSharing(bookings []*Booking, items string, whole string) error {
+	for i, booking := range bookings {
+		blockedStart, blockedEnd := booking.BlockedInterval()
+		for j, previous := range bookings[:i] {
+			previousStart, previousEnd := previous.BlockedInterval()
+			if !blockedStart.Before(previousEnd) || !previousStart.Before(blockedEnd) {
+				continue
+			}
+			if previous.EmployeeID == booking.EmployeeID || slices.ContainsFunc(booking.ResourcesIds, func(id uint) bool {
+				return slices.Contains(previous.ResourcesIds, id)
+			}) {
+				return fmt.Errorf("%s %d and %d of the %s need the same employee or resource at the same time", items, j+1, i+1, whole)
+			}
+		}
+	}
+
+	return nil
+}
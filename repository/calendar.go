@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrBusinessClosed = errors.New("business is closed")
+
+// Holiday is a public holiday observed every year on the same date. Holidays
+// that move from year to year are added as closures.
+type Holiday struct {
+	Name  string
+	Month time.Month
+	Day   int
+}
+
+// Closure is a period when the whole business is closed, e.g. a renovation.
+type Closure struct {
+	ID     uint
+	Start  time.Time
+	End    time.Time
+	Reason string
+}
+
+type CalendarRepository interface {
+	GetOpeningHours() (WeeklySchedule, error)
+	// GetClosures returns the closures, including the holidays, overlapping
+	// [from, to) in chronological order.
+	GetClosures(from time.Time, to time.Time) ([]*Closure, error)
+	AddClosure(closure *Closure) error
+	// IsOpen reports whether the business is open for the whole [start, end)
+	// interval, and if not, the reason why it is closed.
+	IsOpen(start time.Time, end time.Time) (bool, string, error)
+}
+
+// BusinessCalendar holds when the business as a whole is open. It's shared by
+// the CalendarRepository implementations.
+type BusinessCalendar struct {
+	// OpeningHours nil means open around the clock
+	OpeningHours WeeklySchedule
+	Holidays     []*Holiday
+	Closures     []*Closure
+}
+
+// ClosuresBetween returns the closures overlapping [from, to), with the
+// holidays expanded into full day closures in from's location.
+func (c *BusinessCalendar) ClosuresBetween(from time.Time, to time.Time) []*Closure {
+	var closures []*Closure
+
+	y, m, d := from.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, from.Location()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, holiday := range c.Holidays {
+			if day.Month() == holiday.Month && day.Day() == holiday.Day {
+				closures = append(closures, &Closure{
+					Start:  day,
+					End:    day.AddDate(0, 0, 1),
+					Reason: holiday.Name,
+				})
+			}
+		}
+	}
+
+	for _, closure := range c.Closures {
+		if closure.Start.Before(to) && from.Before(closure.End) {
+			closures = append(closures, closure)
+		}
+	}
+
+	slices.SortFunc(closures, func(a, b *Closure) int {
+		return a.Start.Compare(b.Start)
+	})
+	return closures
+}
+
+// ClosedReason returns why the business is closed during [start, end), or an
+// empty string if it is open.
+func (c *BusinessCalendar) ClosedReason(start time.Time, end time.Time) string {
+	if !c.OpeningHours.Covers(start, end) {
+		return "outside opening hours"
+	}
+
+	closures := c.ClosuresBetween(start, end)
+	if len(closures) > 0 {
+		return closures[0].Reason
+	}
+
+	return ""
+}
+
+// CheckBusinessOpen returns an error wrapping ErrBusinessClosed if the business
+// is closed at any point during [start, end).
+func CheckBusinessOpen(calendar CalendarRepository, start time.Time, end time.Time) error {
+	open, reason, err := calendar.IsOpen(start, end)
+	if err != nil {
+		return err
+	}
+	if !open {
+		return fmt.Errorf("%w: %s", ErrBusinessClosed, reason)
+	}
+	return nil
+}
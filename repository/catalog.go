@@ -0,0 +1,22 @@
+package repository
+
+// Catalog is what a business offers, by id: its branches, resources, services
+// and employees.
+//
+// Items removed from the catalog are retired rather than deleted, since
+// existing bookings refer to them: they are still returned by id, but they are
+// left out of the lists, the lookups by name and the availability, and can't
+// be booked. Their names can be given to other items.
+type Catalog struct {
+	Locations map[uint]*Location
+	Resources map[uint]*Resource
+	Services  map[uint]*Service
+	Employees map[uint]*Employee
+}
+
+type CatalogRepository interface {
+	// SetCatalog replaces the catalog at once, e.g. when it is reloaded: the
+	// items in catalog are added or updated and the others are retired. On
+	// failure nothing is changed.
+	SetCatalog(catalog *Catalog) error
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCustomerNotFound = errors.New("customer not found")
+	ErrEmptyPhone       = errors.New("phone number is empty")
+)
+
+// Customer is a client of the business, identified by their phone number. All
+// the bookings made with the same number belong to the same customer.
+type Customer struct {
+	ID   uint
+	Name string
+	// Phone is normalized with NormalizePhone. The numbers given to the agent
+	// are validated and stored in E.164 format, see ParsePhone.
+	Phone     string
+	CreatedAt time.Time
+}
+
+// NormalizePhone returns the phone number with only its digits and a leading
+// +, so the same number written with spaces, dashes or brackets matches. A
+// leading 00 is the international prefix and is replaced by +. It doesn't
+// validate the number nor add the calling code, see ParsePhone.
+func NormalizePhone(phone string) string {
+	var normalized strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if r == '+' && i == 0 || r >= '0' && r <= '9' {
+			normalized.WriteRune(r)
+		}
+	}
+
+	result := normalized.String()
+	if strings.HasPrefix(result, "00") {
+		result = "+" + result[2:]
+	}
+	return result
+}
+
+// LastVisit returns the latest booking that started before now and still
+// occupies its slot, i.e. wasn't cancelled or missed, or nil if there is none.
+// bookings must be in chronological order.
+func LastVisit(bookings []*Booking, now time.Time) *Booking {
+	for i := len(bookings) - 1; i >= 0; i-- {
+		if bookings[i].BookingDateTime.Before(now) && bookings[i].Status.Occupies() {
+			return bookings[i]
+		}
+	}
+	return nil
+}
+
+type CustomerRepository interface {
+	GetCustomerById(id uint) (*Customer, error)
+	// GetCustomerByPhone returns the customer with the phone number, written in
+	// any format.
+	GetCustomerByPhone(phone string) (*Customer, error)
+	// UpsertCustomer returns the customer with the phone number, creating it
+	// when the number is new. Repeat customers are never duplicated: the name
+	// of an existing customer is replaced by a different non-empty one, so the
+	// latest spelling given wins. ErrEmptyPhone is returned if the number has
+	// no digits.
+	UpsertCustomer(name string, phone string) (*Customer, error)
+}
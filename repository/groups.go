@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaxGroupAttendees limits the number of attendees booked together.
+const MaxGroupAttendees = 5
+
+// ErrBookedInGroup is returned when moving a single booking of a group, its
+// attendees must stay together at the same branch.
+var ErrBookedInGroup = errors.New("booking is part of a group, it can't be moved on its own")
+
+// GroupSlot is a way to book a group at the same branch: each attendee has
+// their own slot, in parallel with the others or after one of them ends.
+type GroupSlot struct {
+	// Slots are the slots of the attendees, in the order they were given
+	Slots []*Slot
+}
+
+// FindGroupSlots implements EmployeeRepository.FindGroupSlots, with one step
+// per attendee. Employees are tried in the given order; each group starts at a
+// multiple of granularity, and at most one group is returned per start time.
+func FindGroupSlots(steps []*BundleStep, locationId uint, from time.Time, to time.Time, granularity time.Duration, limit int, isAvailable AvailabilityFunc) ([]*GroupSlot, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("group has no attendees")
+	}
+	if len(steps) > MaxGroupAttendees {
+		return nil, fmt.Errorf("a group can have at most %d attendees", MaxGroupAttendees)
+	}
+
+	groups := []*GroupSlot{}
+	for start := FirstSlot(from, granularity); start.Before(to); start = start.Add(granularity) {
+		placed, err := placeGroupSteps(steps, locationId, start, nil, isAvailable)
+		if err != nil {
+			return nil, err
+		}
+		if placed == nil {
+			continue
+		}
+
+		groups = append(groups, &GroupSlot{Slots: placed})
+		if limit > 0 && len(groups) == limit {
+			break
+		}
+	}
+
+	return groups, nil
+}
+
+// placeGroupSteps finds a slot for the attendees following the placed ones.
+// Each one starts with the group at start or after a placed attendee, earliest
+// first, so attendees are in parallel whenever possible. It returns all the
+// placed slots, or nil if the remaining attendees can't be placed.
+func placeGroupSteps(steps []*BundleStep, locationId uint, start time.Time, placed []*Slot, isAvailable AvailabilityFunc) ([]*Slot, error) {
+	if len(placed) == len(steps) {
+		return placed, nil
+	}
+
+	// After another attendee, either right when they end or, to share their
+	// employee or a resource, when their buffers are over too
+	step := steps[len(placed)]
+	starts := []time.Time{start}
+	for i, slot := range placed {
+		_, blockedEnd := steps[i].Service.BlockedInterval(slot.Start)
+		for _, candidate := range []time.Time{
+			slot.Start.Add(time.Duration(steps[i].Service.Duration) * time.Minute),
+			blockedEnd.Add(time.Duration(step.Service.BufferBefore) * time.Minute),
+		} {
+			if !slices.ContainsFunc(starts, candidate.Equal) {
+				starts = append(starts, candidate)
+			}
+		}
+	}
+	slices.SortFunc(starts, time.Time.Compare)
+
+	for _, slotStart := range starts {
+		for _, employee := range step.Employees {
+			// The first attendee chooses the branch, the next ones must be
+			// there too
+			slotLocationId := locationId
+			if len(placed) == 0 {
+				slotLocationId = employee.ResolveLocation(locationId, slotStart.Weekday())
+			}
+
+			if overlapsPlacedSteps(steps, placed, employee, step.Service, slotStart) {
+				continue
+			}
+			available, err := isAvailable(employee, step.Service, slotLocationId, slotStart)
+			if err != nil {
+				return nil, err
+			}
+			if !available {
+				continue
+			}
+
+			slot := &Slot{EmployeeID: employee.ID, LocationID: slotLocationId, Start: slotStart}
+			result, err := placeGroupSteps(steps, slotLocationId, start, append(slices.Clone(placed), slot), isAvailable)
+			if err != nil || result != nil {
+				return result, err
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// ValidateGroup checks that the bookings of a group are at the same branch,
+// under the same contact phone number, and that they don't need the same
+// employee or resource at the same time, buffers included.
+func ValidateGroup(bookings []*Booking) error {
+	if len(bookings) == 0 {
+		return errors.New("group has no attendees")
+	}
+	if len(bookings) > MaxGroupAttendees {
+		return fmt.Errorf("a group can have at most %d attendees", MaxGroupAttendees)
+	}
+
+	for _, booking := range bookings[1:] {
+		if booking.LocationID != bookings[0].LocationID {
+			return errors.New("the attendees of a group must be at the same branch")
+		}
+		if NormalizePhone(booking.CustomerPhone) != NormalizePhone(bookings[0].CustomerPhone) {
+			return errors.New("the attendees of a group must have the same contact phone number")
+		}
+	}
+
+	return checkSharing(bookings, "attendees", "group")
+}
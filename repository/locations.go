@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Location is a branch of the business, with its own address, time zone and
+// opening hours. Business wide holidays and closures apply to every branch, on
+// its local dates.
+type Location struct {
+	ID      uint
+	Name    string
+	Address string
+	// Timezone is the IANA name of the branch time zone, e.g.
+	// "Europe/Bucharest". Its opening hours, the working hours of the
+	// employees on the days they work there and the times of its bookings are
+	// in it. Empty means the business time zone.
+	Timezone string
+	// OpeningHours nil means the branch is open during the business opening
+	// hours
+	OpeningHours WeeklySchedule
+	// Retired branches were removed from the catalog, see Catalog.
+	Retired bool
+}
+
+// Validate checks the time zone and the opening hours of the branch.
+func (l *Location) Validate() error {
+	if _, err := LoadTimezone(l.Timezone); err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", l.Timezone, err)
+	}
+	return l.OpeningHours.Validate()
+}
+
+// TimezoneOr returns the time zone of the branch, or timezone, the business
+// one, if the branch has none.
+func (l *Location) TimezoneOr(timezone *time.Location) (*time.Location, error) {
+	if l.Timezone == "" {
+		return timezone, nil
+	}
+	return LoadTimezone(l.Timezone)
+}
+
+// LocationTimezone returns the time zone of the branch, or timezone, the
+// business one, when the branch has none or locationId is 0.
+func LocationTimezone(locations LocationRepository, locationId uint, timezone *time.Location) (*time.Location, error) {
+	if locationId == 0 {
+		return timezone, nil
+	}
+
+	location, err := locations.GetLocationById(locationId)
+	if err != nil {
+		return nil, err
+	}
+	return location.TimezoneOr(timezone)
+}
+
+type LocationRepository interface {
+	GetLocations() ([]*Location, error)
+	GetLocationById(id uint) (*Location, error)
+	GetLocationByName(name string) (*Location, error)
+}
+
+// WeeklyLocations holds the branch an employee works at on each weekday, by
+// location id. The weekdays are in the business time zone, the working hours
+// on them in the time zone of the branch.
+type WeeklyLocations map[time.Weekday]uint
+
+// MarshalJSON encodes the locations keyed by weekday names instead of numbers.
+func (l WeeklyLocations) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("null"), nil
+	}
+
+	named := make(map[string]uint, len(l))
+	for weekday, locationId := range l {
+		named[weekday.String()] = locationId
+	}
+	return json.Marshal(named)
+}
+
+func (l *WeeklyLocations) UnmarshalJSON(data []byte) error {
+	var named map[string]uint
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	if named == nil {
+		*l = nil
+		return nil
+	}
+
+	locations := make(WeeklyLocations, len(named))
+	for name, locationId := range named {
+		weekday, err := ParseWeekday(name)
+		if err != nil {
+			return err
+		}
+		locations[weekday] = locationId
+	}
+	*l = locations
+	return nil
+}
+
+// CheckLocationOpen returns an error wrapping ErrBusinessClosed if the branch
+// is retired or outside its opening hours, in its time zone, at any point
+// during [start, end). A locationId of 0 means no particular branch and is
+// always open.
+func CheckLocationOpen(locations LocationRepository, locationId uint, start time.Time, end time.Time) error {
+	if locationId == 0 {
+		return nil
+	}
+
+	location, err := locations.GetLocationById(locationId)
+	if err != nil {
+		return err
+	}
+	if location.Retired {
+		return fmt.Errorf("%w: %s no longer takes appointments", ErrBusinessClosed, location.Name)
+	}
+	timezone, err := location.TimezoneOr(start.Location())
+	if err != nil {
+		return err
+	}
+	if !location.OpeningHours.Covers(start.In(timezone), end) {
+		return fmt.Errorf("%w: %s is closed at that time", ErrBusinessClosed, location.Name)
+	}
+	return nil
+}
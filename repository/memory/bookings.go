@@ -2,6 +2,9 @@ package memory_repository
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,12 +16,41 @@ type bookingsMemoryRepository struct {
 	// map that stores the bookings indexed by date
 	bookings map[string][]*repository.Booking
 	nextID   uint
+	// map that stores the holds indexed by id, expired holds are removed on
+	// every write
+	holds      map[uint]*repository.Hold
+	nextHoldID uint
+	// map that stores the recurring series indexed by id, their bookings are
+	// found by SeriesID
+	series             map[uint]*repository.Series
+	nextSeriesID       uint
+	serviceRepository  repository.ServiceRepository
+	calendarRepository repository.CalendarRepository
+	resourceRepository repository.ResourceRepository
+	locationRepository repository.LocationRepository
+	customerRepository repository.CustomerRepository
+	// employeeRepository is set by NewEmployeeMemoryRepository, the employees
+	// and the bookings refer to each other
+	employeeRepository repository.EmployeeRepository
+	// timezone is the business time zone, the bookings are indexed by their
+	// date in it
+	timezone *time.Location
 }
 
-func NewBookingsMemoryRepository() repository.BookingRepository {
+func NewBookingsMemoryRepository(serviceRepository repository.ServiceRepository, calendarRepository repository.CalendarRepository, resourceRepository repository.ResourceRepository, locationRepository repository.LocationRepository, customerRepository repository.CustomerRepository, timezone *time.Location) repository.BookingRepository {
 	return &bookingsMemoryRepository{
-		bookings: make(map[string][]*repository.Booking),
-		nextID:   1,
+		bookings:           make(map[string][]*repository.Booking),
+		nextID:             1,
+		holds:              make(map[uint]*repository.Hold),
+		nextHoldID:         1,
+		series:             make(map[uint]*repository.Series),
+		nextSeriesID:       1,
+		serviceRepository:  serviceRepository,
+		calendarRepository: calendarRepository,
+		resourceRepository: resourceRepository,
+		locationRepository: locationRepository,
+		customerRepository: customerRepository,
+		timezone:           timezone,
 	}
 }
 
@@ -26,7 +58,7 @@ func (r *bookingsMemoryRepository) GetBookingsByDateAndEmployee(date string, emp
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	parsedDate, err := time.Parse("2006-01-02", date)
+	parsedDate, err := time.ParseInLocation("2006-01-02", date, r.timezone)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +70,7 @@ func (r *bookingsMemoryRepository) GetBookingsByDateAndEmployee(date string, emp
 
 	var bookings []*repository.Booking
 	for _, booking := range dateBookings {
-		if booking.EmployeeID == employeeId && booking.BookingDateTime.Format("2006-01-02") == parsedDate.Format("2006-01-02") {
+		if booking.EmployeeID == employeeId && booking.BookingDateTime.In(r.timezone).Format("2006-01-02") == parsedDate.Format("2006-01-02") {
 			bookings = append(bookings, booking)
 		}
 	}
@@ -46,26 +78,434 @@ func (r *bookingsMemoryRepository) GetBookingsByDateAndEmployee(date string, emp
 	return bookings, nil
 }
 
-func (r *bookingsMemoryRepository) SaveBooking(booking *repository.Booking) error {
+func (r *bookingsMemoryRepository) GetBookingById(id uint) (*repository.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	booking, _ := r.findBooking(id)
+	if booking == nil {
+		return nil, repository.ErrBookingNotFound
+	}
+
+	return booking, nil
+}
+
+func (r *bookingsMemoryRepository) GetOverlappingBookings(employeeId uint, start time.Time, end time.Time) ([]*repository.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.overlappingBookings(employeeId, start, end), nil
+}
+
+func (r *bookingsMemoryRepository) GetResourceUsage(resourceId uint, start time.Time, end time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return repository.PeakUsage(r.resourceUsers(resourceId, start, end, 0, ""), start, end), nil
+}
+
+func (r *bookingsMemoryRepository) GetUpcomingBookingsByCustomer(phone string, name string) ([]*repository.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	phone = repository.NormalizePhone(phone)
+
+	var bookings []*repository.Booking
+	for _, dateBookings := range r.bookings {
+		for _, booking := range dateBookings {
+			if booking.CustomerPhone != phone || booking.BookingDateTime.Before(now) || !booking.Status.Occupies() {
+				continue
+			}
+			if name != "" && !strings.EqualFold(booking.CustomerName, name) {
+				continue
+			}
+			bookings = append(bookings, booking)
+		}
+	}
+
+	slices.SortFunc(bookings, func(a, b *repository.Booking) int {
+		return a.BookingDateTime.Compare(b.BookingDateTime)
+	})
+
+	return bookings, nil
+}
+
+func (r *bookingsMemoryRepository) GetBookingsByCustomerId(customerId uint) ([]*repository.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var bookings []*repository.Booking
+	for _, dateBookings := range r.bookings {
+		for _, booking := range dateBookings {
+			if booking.CustomerID == customerId {
+				bookings = append(bookings, booking)
+			}
+		}
+	}
+
+	slices.SortFunc(bookings, func(a, b *repository.Booking) int {
+		return a.BookingDateTime.Compare(b.BookingDateTime)
+	})
+
+	return bookings, nil
+}
+
+func (r *bookingsMemoryRepository) ReserveBooking(booking *repository.Booking) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.removeExpiredHolds()
+
+	if err := r.prepareBooking(booking); err != nil {
+		return err
+	}
+	if err := r.checkConflicts(booking, ""); err != nil {
+		return err
+	}
+	if err := r.linkCustomer(booking); err != nil {
+		return err
+	}
+
 	if booking.ID == 0 {
 		booking.ID = r.nextID
 		r.nextID++
 	}
+	r.addBooking(booking)
+
+	return nil
+}
+
+// reserveTogether atomically stores bookings that are made together, checked
+// as a whole with validate, which must ensure that they share their contact and
+// don't need the same employee or resource at the same time. itemFormat tells
+// the position of a booking in the errors. setId links each booking to the id
+// of the first one.
+func (r *bookingsMemoryRepository) reserveTogether(bookings []*repository.Booking, validate func([]*repository.Booking) error, itemFormat string, setId func(*repository.Booking, uint)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeExpiredHolds()
+
+	for i, booking := range bookings {
+		if err := r.prepareBooking(booking); err != nil {
+			return fmt.Errorf(itemFormat+": %w", i+1, err)
+		}
+	}
+	if err := validate(bookings); err != nil {
+		return err
+	}
+
+	// validate checks that the bookings don't need the same employee or
+	// resource at the same time, so each one is only checked against the
+	// stored bookings and holds
+	for i, booking := range bookings {
+		if err := r.checkConflicts(booking, ""); err != nil {
+			return fmt.Errorf(itemFormat+": %w", i+1, err)
+		}
+	}
+
+	// The bookings share their contact, see validate, who is linked before
+	// anything is stored: if it fails nothing has changed
+	if err := r.linkCustomer(bookings[0]); err != nil {
+		return err
+	}
+	for _, booking := range bookings {
+		booking.CustomerID = bookings[0].CustomerID
+		booking.CustomerPhone = bookings[0].CustomerPhone
+		if booking.ID == 0 {
+			booking.ID = r.nextID
+			r.nextID++
+		}
+		r.addBooking(booking)
+	}
+	for _, booking := range bookings {
+		setId(booking, bookings[0].ID)
+	}
+
+	return nil
+}
+
+// prepareBooking validates a new booking, sets its initial status and fills in
+// the service snapshot.
+func (r *bookingsMemoryRepository) prepareBooking(booking *repository.Booking) error {
+	now := time.Now()
+	if booking.BookingDateTime.Before(now) {
+		return errors.New("booking time is in the past")
+	}
+	booking.BookingDateTime = booking.BookingDateTime.In(r.timezone)
+
+	if booking.Duration == 0 {
+		service, err := r.serviceRepository.GetServiceById(booking.ServiceID)
+		if err != nil {
+			return err
+		}
+		booking.SetServiceSnapshot(service)
+	}
+	if err := booking.InitStatus(now); err != nil {
+		return err
+	}
+
+	return r.checkOpen(booking)
+}
+
+// linkCustomer links a new booking to the customer with its phone number,
+// creating or updating the customer. Bookings without a phone number aren't
+// linked.
+func (r *bookingsMemoryRepository) linkCustomer(booking *repository.Booking) error {
+	if repository.NormalizePhone(booking.CustomerPhone) == "" {
+		return nil
+	}
+
+	customer, err := r.customerRepository.UpsertCustomer(booking.CustomerName, booking.CustomerPhone)
+	if err != nil {
+		return err
+	}
+	booking.CustomerID = customer.ID
+	booking.CustomerPhone = customer.Phone
+	return nil
+}
+
+// checkOpen returns an error wrapping ErrBusinessClosed if the business or the
+// branch of the booking is closed during the appointment.
+func (r *bookingsMemoryRepository) checkOpen(booking *repository.Booking) error {
+	timezone, err := repository.LocationTimezone(r.locationRepository, booking.LocationID, r.timezone)
+	if err != nil {
+		return err
+	}
+	start := booking.BookingDateTime.In(timezone)
+
+	if err := repository.CheckBusinessOpen(r.calendarRepository, start, booking.EndDateTime()); err != nil {
+		return err
+	}
+	return repository.CheckLocationOpen(r.locationRepository, booking.LocationID, start, booking.EndDateTime())
+}
+
+func (r *bookingsMemoryRepository) CancelBooking(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	booking, _ := r.findBooking(id)
+	if booking == nil {
+		return repository.ErrBookingNotFound
+	}
 
 	if booking.BookingDateTime.Before(time.Now()) {
 		return errors.New("booking time is in the past")
 	}
 
-	date := booking.BookingDateTime.Format("2006-01-02")
-	bookings, ok := r.bookings[date]
-	if !ok {
-		r.bookings[date] = []*repository.Booking{booking}
-	} else {
-		r.bookings[date] = append(bookings, booking)
+	return booking.SetStatus(repository.BookingCancelled, time.Now())
+}
+
+func (r *bookingsMemoryRepository) SetBookingStatus(id uint, status repository.BookingStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	booking, _ := r.findBooking(id)
+	if booking == nil {
+		return repository.ErrBookingNotFound
+	}
+
+	return booking.SetStatus(status, time.Now())
+}
+
+func (r *bookingsMemoryRepository) RescheduleBooking(id uint, newDateTime time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	booking, date := r.findBooking(id)
+	if booking == nil {
+		return repository.ErrBookingNotFound
+	}
+
+	if booking.BookingDateTime.Before(time.Now()) {
+		return errors.New("booking time is in the past")
+	}
+	if booking.Status != repository.BookingPending && booking.Status != repository.BookingConfirmed {
+		return fmt.Errorf("booking is %s", booking.Status)
+	}
+	if booking.BundleID != 0 {
+		return repository.ErrBookedInBundle
+	}
+	if booking.GroupID != 0 {
+		return repository.ErrBookedInGroup
+	}
+	if newDateTime.Before(time.Now()) {
+		return errors.New("new booking time is in the past")
+	}
+
+	moved := *booking
+	moved.BookingDateTime = newDateTime.In(r.timezone)
+	if err := r.checkOpen(&moved); err != nil {
+		return err
+	}
+
+	r.removeExpiredHolds()
+	if err := r.checkConflicts(&moved, ""); err != nil {
+		return err
 	}
 
+	r.removeBooking(date, id)
+	booking.BookingDateTime = moved.BookingDateTime
+	r.addBooking(booking)
+
 	return nil
 }
+
+// findBooking returns the booking with the given id and the date it is indexed
+// by, or nil if there is none. The caller must hold the lock.
+func (r *bookingsMemoryRepository) findBooking(id uint) (*repository.Booking, string) {
+	for date, dateBookings := range r.bookings {
+		for _, booking := range dateBookings {
+			if booking.ID == id {
+				return booking, date
+			}
+		}
+	}
+
+	return nil, ""
+}
+
+func (r *bookingsMemoryRepository) addBooking(booking *repository.Booking) {
+	date := booking.BookingDateTime.In(r.timezone).Format("2006-01-02")
+	r.bookings[date] = append(r.bookings[date], booking)
+}
+
+func (r *bookingsMemoryRepository) removeBooking(date string, id uint) {
+	r.bookings[date] = slices.DeleteFunc(r.bookings[date], func(b *repository.Booking) bool {
+		return b.ID == id
+	})
+	if len(r.bookings[date]) == 0 {
+		delete(r.bookings, date)
+	}
+}
+
+// checkConflicts returns an error wrapping ErrEmployeeNotWorking if the
+// employee isn't working during the booking, or a *SlotConflictError if the
+// booking overlaps another booking of the same employee, or a hold placed by a
+// different session than sessionId, or if one of its resources is used up to
+// its capacity. The caller must hold the lock.
+func (r *bookingsMemoryRepository) checkConflicts(booking *repository.Booking, sessionId string) error {
+	if err := r.checkEmployee(booking); err != nil {
+		return err
+	}
+
+	blockedStart, blockedEnd := booking.BlockedInterval()
+	for _, other := range r.overlappingBookings(booking.EmployeeID, blockedStart, blockedEnd) {
+		if other.ID != booking.ID {
+			return &repository.SlotConflictError{
+				EmployeeID:           booking.EmployeeID,
+				Start:                booking.BookingDateTime,
+				ConflictingBookingID: other.ID,
+			}
+		}
+	}
+
+	for _, hold := range r.overlappingHolds(booking.EmployeeID, blockedStart, blockedEnd) {
+		if sessionId == "" || hold.SessionID != sessionId {
+			return &repository.SlotConflictError{
+				EmployeeID:        booking.EmployeeID,
+				Start:             booking.BookingDateTime,
+				ConflictingHoldID: hold.ID,
+			}
+		}
+	}
+
+	for _, resourceId := range booking.ResourcesIds {
+		resource, err := r.resourceRepository.GetResourceById(resourceId)
+		if err != nil {
+			return err
+		}
+
+		users := r.resourceUsers(resourceId, blockedStart, blockedEnd, booking.ID, sessionId)
+		if repository.PeakUsage(users, blockedStart, blockedEnd) >= int(resource.Capacity) {
+			return &repository.SlotConflictError{
+				EmployeeID:            booking.EmployeeID,
+				Start:                 booking.BookingDateTime,
+				ConflictingResourceID: resourceId,
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkEmployee returns an error wrapping ErrEmployeeNotWorking if the
+// employee of the booking can't take it, see Employee.CheckWorking. The caller
+// must hold the lock: time off added in the meantime is either seen here or
+// waits for the lock, and then returns the booking among the ones to
+// reschedule.
+func (r *bookingsMemoryRepository) checkEmployee(booking *repository.Booking) error {
+	if r.employeeRepository == nil {
+		return errors.New("no employee repository, see NewEmployeeMemoryRepository")
+	}
+
+	employee, err := r.employeeRepository.GetEmployeeById(booking.EmployeeID)
+	if err != nil {
+		return err
+	}
+	timeOff, err := r.employeeRepository.GetTimeOff(employee.ID, booking.BookingDateTime, booking.EndDateTime())
+	if err != nil {
+		return err
+	}
+	timezone, err := repository.LocationTimezone(r.locationRepository, booking.LocationID, r.timezone)
+	if err != nil {
+		return err
+	}
+
+	return employee.CheckWorking(booking, timeOff, timezone)
+}
+
+// resourceUsers returns the bookings, other than excludeId, and the active
+// holds, other than the ones of sessionId, using the resource during [start,
+// end). Holds are returned as their booking. The caller must hold the lock.
+func (r *bookingsMemoryRepository) resourceUsers(resourceId uint, start time.Time, end time.Time, excludeId uint, sessionId string) []*repository.Booking {
+	users := r.overlappingBookingsFunc(start, end, func(booking *repository.Booking) bool {
+		return booking.ID != excludeId && slices.Contains(booking.ResourcesIds, resourceId)
+	})
+
+	for _, hold := range r.overlappingHoldsFunc(start, end, func(hold *repository.Hold) bool {
+		return (sessionId == "" || hold.SessionID != sessionId) && slices.Contains(hold.Booking.ResourcesIds, resourceId)
+	}) {
+		users = append(users, &hold.Booking)
+	}
+
+	return users
+}
+
+// overlappingBookings returns the bookings of the employee whose blocked
+// interval overlaps [start, end). The caller must hold the lock.
+func (r *bookingsMemoryRepository) overlappingBookings(employeeId uint, start time.Time, end time.Time) []*repository.Booking {
+	return r.overlappingBookingsFunc(start, end, func(booking *repository.Booking) bool {
+		return booking.EmployeeID == employeeId
+	})
+}
+
+// overlappingBookingsFunc returns the bookings matching match whose blocked
+// interval overlaps [start, end), in chronological order. Only the bookings
+// whose status occupies their slot are considered. The caller must hold the
+// lock.
+func (r *bookingsMemoryRepository) overlappingBookingsFunc(start time.Time, end time.Time, match func(*repository.Booking) bool) []*repository.Booking {
+	var bookings []*repository.Booking
+
+	// Bookings starting the day before can run past midnight
+	firstDay := repository.StartOfDay(start, r.timezone).AddDate(0, 0, -1)
+	for day := firstDay; day.Before(end.AddDate(0, 0, 1)); day = day.AddDate(0, 0, 1) {
+		for _, booking := range r.bookings[day.Format("2006-01-02")] {
+			if !booking.Status.Occupies() || !match(booking) {
+				continue
+			}
+
+			blockedStart, blockedEnd := booking.BlockedInterval()
+			if blockedStart.Before(end) && start.Before(blockedEnd) {
+				bookings = append(bookings, booking)
+			}
+		}
+	}
+
+	slices.SortFunc(bookings, func(a, b *repository.Booking) int {
+		return a.BookingDateTime.Compare(b.BookingDateTime)
+	})
+
+	return bookings
+}
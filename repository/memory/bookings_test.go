@@ -0,0 +1,37 @@
+package memory_repository
+
+import (
+	"testing"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+	"valighita/bookings-ai-agent/repository/repositorytest"
+)
+
+// newTestRepositories creates the memory repositories of a business, for the
+// tests shared with the other backends.
+func newTestRepositories(t *testing.T, catalog *repository.Catalog, timezone *time.Location) *repository.Repositories {
+	services := NewServicesMemoryRepository(catalog.Services)
+	calendar := NewCalendarMemoryRepository(repository.BusinessCalendar{})
+	resources := NewResourcesMemoryRepository(catalog.Resources)
+	locations := NewLocationsMemoryRepository(catalog.Locations)
+	customers := NewCustomersMemoryRepository()
+	bookings := NewBookingsMemoryRepository(services, calendar, resources, locations, customers, timezone)
+	employees := NewEmployeeMemoryRepository(bookings, services, calendar, resources, locations, 15*time.Minute, timezone, catalog.Employees)
+
+	return &repository.Repositories{
+		Bookings:  bookings,
+		Services:  services,
+		Employees: employees,
+		Calendar:  calendar,
+		Resources: resources,
+		Locations: locations,
+		Customers: customers,
+		Catalog:   NewCatalogMemoryRepository(locations, resources, services, employees),
+		Timezone:  timezone,
+	}
+}
+
+func TestBookingRepository(t *testing.T) {
+	repositorytest.TestBookingRepository(t, newTestRepositories)
+}
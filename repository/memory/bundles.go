@@ -0,0 +1,11 @@
+package memory_repository
+
+import (
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsMemoryRepository) ReserveBundle(bookings []*repository.Booking) error {
+	return r.reserveTogether(bookings, repository.ValidateBundle, "service %d of the visit", func(booking *repository.Booking, id uint) {
+		booking.BundleID = id
+	})
+}
@@ -0,0 +1,65 @@
+package memory_repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type calendarMemoryRepository struct {
+	mu       sync.RWMutex
+	calendar repository.BusinessCalendar
+	nextID   uint
+}
+
+func NewCalendarMemoryRepository(data repository.BusinessCalendar) repository.CalendarRepository {
+	r := &calendarMemoryRepository{
+		calendar: data,
+		nextID:   1,
+	}
+	for _, closure := range data.Closures {
+		r.nextID = max(r.nextID, closure.ID+1)
+	}
+	return r
+}
+
+func (r *calendarMemoryRepository) GetOpeningHours() (repository.WeeklySchedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.calendar.OpeningHours, nil
+}
+
+func (r *calendarMemoryRepository) GetClosures(from time.Time, to time.Time) ([]*repository.Closure, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.calendar.ClosuresBetween(from, to), nil
+}
+
+func (r *calendarMemoryRepository) AddClosure(closure *repository.Closure) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !closure.Start.Before(closure.End) {
+		return errors.New("closure must end after it starts")
+	}
+
+	if closure.ID == 0 {
+		closure.ID = r.nextID
+		r.nextID++
+	}
+	r.calendar.Closures = append(r.calendar.Closures, closure)
+
+	return nil
+}
+
+func (r *calendarMemoryRepository) IsOpen(start time.Time, end time.Time) (bool, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reason := r.calendar.ClosedReason(start, end)
+	return reason == "", reason, nil
+}
@@ -0,0 +1,74 @@
+package memory_repository
+
+import (
+	"maps"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type catalogMemoryRepository struct {
+	locations *locationsMemoryRepository
+	resources *resourcesMemoryRepository
+	services  *servicesMemoryRepository
+	employees *employeeMemoryRepository
+}
+
+// NewCatalogMemoryRepository returns a CatalogRepository updating the given
+// repositories, which must have been created by this package.
+func NewCatalogMemoryRepository(locationRepository repository.LocationRepository, resourceRepository repository.ResourceRepository, serviceRepository repository.ServiceRepository, employeeRepository repository.EmployeeRepository) repository.CatalogRepository {
+	return &catalogMemoryRepository{
+		locations: locationRepository.(*locationsMemoryRepository),
+		resources: resourceRepository.(*resourcesMemoryRepository),
+		services:  serviceRepository.(*servicesMemoryRepository),
+		employees: employeeRepository.(*employeeMemoryRepository),
+	}
+}
+
+func (r *catalogMemoryRepository) SetCatalog(catalog *repository.Catalog) error {
+	// The repositories are all locked while the new items are swapped in, so
+	// none of them is read half updated
+	r.locations.mu.Lock()
+	defer r.locations.mu.Unlock()
+	r.resources.mu.Lock()
+	defer r.resources.mu.Unlock()
+	r.services.mu.Lock()
+	defer r.services.mu.Unlock()
+	r.employees.mu.Lock()
+	defer r.employees.mu.Unlock()
+
+	r.locations.locations = mergeCatalog(r.locations.locations, catalog.Locations, func(l *repository.Location) *repository.Location {
+		retired := *l
+		retired.Retired = true
+		return &retired
+	})
+	r.resources.resources = mergeCatalog(r.resources.resources, catalog.Resources, func(res *repository.Resource) *repository.Resource {
+		retired := *res
+		retired.Retired = true
+		return &retired
+	})
+	r.services.services = mergeCatalog(r.services.services, catalog.Services, func(s *repository.Service) *repository.Service {
+		retired := *s
+		retired.Retired = true
+		return &retired
+	})
+	r.employees.employees = mergeCatalog(r.employees.employees, catalog.Employees, func(e *repository.Employee) *repository.Employee {
+		retired := *e
+		retired.Retired = true
+		return &retired
+	})
+	return nil
+}
+
+// mergeCatalog returns the items of next along with the current ones missing
+// from it, retired by retire. The maps belong to the callers and are not
+// changed, neither are the items: retire returns a copy.
+func mergeCatalog[T any](current map[uint]*T, next map[uint]*T, retire func(*T) *T) map[uint]*T {
+	merged := make(map[uint]*T, len(current)+len(next))
+	for id, item := range current {
+		if _, ok := next[id]; !ok {
+			merged[id] = retire(item)
+		}
+	}
+	maps.Copy(merged, next)
+	return merged
+}
@@ -0,0 +1,80 @@
+package memory_repository
+
+import (
+	"sync"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type customersMemoryRepository struct {
+	mu sync.RWMutex
+	// map that stores copies of the customers indexed by id, so callers can't
+	// change them without UpsertCustomer
+	customers map[uint]*repository.Customer
+	// map from the normalized phone numbers to the customers ids
+	phones map[string]uint
+	nextID uint
+}
+
+func NewCustomersMemoryRepository() repository.CustomerRepository {
+	return &customersMemoryRepository{
+		customers: make(map[uint]*repository.Customer),
+		phones:    make(map[string]uint),
+		nextID:    1,
+	}
+}
+
+func (r *customersMemoryRepository) GetCustomerById(id uint) (*repository.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	customer, ok := r.customers[id]
+	if !ok {
+		return nil, repository.ErrCustomerNotFound
+	}
+
+	result := *customer
+	return &result, nil
+}
+
+func (r *customersMemoryRepository) GetCustomerByPhone(phone string) (*repository.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.phones[repository.NormalizePhone(phone)]
+	if !ok {
+		return nil, repository.ErrCustomerNotFound
+	}
+
+	result := *r.customers[id]
+	return &result, nil
+}
+
+func (r *customersMemoryRepository) UpsertCustomer(name string, phone string) (*repository.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	phone = repository.NormalizePhone(phone)
+	if phone == "" {
+		return nil, repository.ErrEmptyPhone
+	}
+
+	customer, ok := r.customers[r.phones[phone]]
+	if !ok {
+		customer = &repository.Customer{
+			ID:        r.nextID,
+			Name:      name,
+			Phone:     phone,
+			CreatedAt: time.Now(),
+		}
+		r.nextID++
+		r.customers[customer.ID] = customer
+		r.phones[phone] = customer.ID
+	} else if name != "" {
+		customer.Name = name
+	}
+
+	result := *customer
+	return &result, nil
+}
@@ -10,18 +10,44 @@ import (
 )
 
 type employeeMemoryRepository struct {
-	mu                 sync.RWMutex
-	employees          map[uint]*repository.Employee
+	// mu is never held while calling bookingsRepository, which calls back
+	// while holding its own lock
+	mu        sync.RWMutex
+	employees map[uint]*repository.Employee
+	// map that stores the time off indexed by employee id
+	timeOff            map[uint][]*repository.TimeOff
+	nextTimeOffID      uint
 	bookingsRepository repository.BookingRepository
 	serviceRepository  repository.ServiceRepository
+	calendarRepository repository.CalendarRepository
+	resourceRepository repository.ResourceRepository
+	locationRepository repository.LocationRepository
+	slotGranularity    time.Duration
+	timezone           *time.Location
 }
 
-func NewEmployeeMemoryRepository(bookingRepository repository.BookingRepository, serviceRepository repository.ServiceRepository, data map[uint]*repository.Employee) repository.EmployeeRepository {
-	return &employeeMemoryRepository{
+// NewEmployeeMemoryRepository returns an EmployeeRepository holding the
+// employees in data. From then on, bookingRepository, when created with
+// NewBookingsMemoryRepository, checks the working hours and the time off of
+// these employees when storing bookings.
+func NewEmployeeMemoryRepository(bookingRepository repository.BookingRepository, serviceRepository repository.ServiceRepository, calendarRepository repository.CalendarRepository, resourceRepository repository.ResourceRepository, locationRepository repository.LocationRepository, slotGranularity time.Duration, timezone *time.Location, data map[uint]*repository.Employee) repository.EmployeeRepository {
+	employees := &employeeMemoryRepository{
+		slotGranularity:    slotGranularity,
+		timezone:           timezone,
 		employees:          data,
+		timeOff:            make(map[uint][]*repository.TimeOff),
+		nextTimeOffID:      1,
 		bookingsRepository: bookingRepository,
 		serviceRepository:  serviceRepository,
+		calendarRepository: calendarRepository,
+		resourceRepository: resourceRepository,
+		locationRepository: locationRepository,
 	}
+	if bookings, ok := bookingRepository.(*bookingsMemoryRepository); ok {
+		bookings.employeeRepository = employees
+	}
+
+	return employees
 }
 
 func (r *employeeMemoryRepository) GetEmployees() ([]*repository.Employee, error) {
@@ -30,7 +56,9 @@ func (r *employeeMemoryRepository) GetEmployees() ([]*repository.Employee, error
 
 	employees := make([]*repository.Employee, 0, len(r.employees))
 	for _, employee := range r.employees {
-		employees = append(employees, employee)
+		if !employee.Retired {
+			employees = append(employees, employee)
+		}
 	}
 
 	return employees, nil
@@ -53,7 +81,7 @@ func (r *employeeMemoryRepository) GetEmployeeByName(name string) (*repository.E
 	defer r.mu.RUnlock()
 
 	for _, employee := range r.employees {
-		if strings.ToLower(employee.Name) == strings.ToLower(name) {
+		if !employee.Retired && strings.ToLower(employee.Name) == strings.ToLower(name) {
 			return employee, nil
 		}
 	}
@@ -61,45 +89,283 @@ func (r *employeeMemoryRepository) GetEmployeeByName(name string) (*repository.E
 	return nil, errors.New("employee not found")
 }
 
-func (r *employeeMemoryRepository) CheckAvailability(employeeId uint, serviceId uint, bookingDate string, bookingTime string) (bool, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *employeeMemoryRepository) CheckAvailability(employeeId uint, serviceId uint, locationId uint, bookingDate string, bookingTime string) (bool, error) {
+	employee, err := r.GetEmployeeById(employeeId)
+	if err != nil {
+		return false, err
+	}
 
 	service, err := r.serviceRepository.GetServiceById(serviceId)
 	if err != nil {
 		return false, err
 	}
 
-	// Use the info in booking repository to check availability
-	dayBookings, err := r.bookingsRepository.GetBookingsByDateAndEmployee(bookingDate, employeeId)
+	// The branch of the day gives the time zone of the time
+	date, err := time.ParseInLocation("2006-01-02", bookingDate, r.timezone)
 	if err != nil {
 		return false, err
 	}
-
-	checkTime, err := time.Parse("2006-01-02 15:04", bookingDate+" "+bookingTime)
+	locationId = employee.ResolveLocation(locationId, date.Weekday())
+	timezone, err := repository.LocationTimezone(r.locationRepository, locationId, r.timezone)
+	if err != nil {
+		return false, err
+	}
+	checkTime, err := repository.ParseLocalTime("2006-01-02 15:04", bookingDate+" "+bookingTime, timezone)
 	if err != nil {
 		return false, err
 	}
+
+	return r.isAvailable(employee, service, locationId, checkTime)
+}
+
+// isAvailable reports whether the employee can perform the service at the
+// branch starting at checkTime. The caller must not hold the lock.
+func (r *employeeMemoryRepository) isAvailable(employee *repository.Employee, service *repository.Service, locationId uint, checkTime time.Time) (bool, error) {
 	if checkTime.Before(time.Now()) {
 		return false, nil // The time is in the past
 	}
 
+	if employee.Retired || service.Retired {
+		return false, nil // Removed from the catalog
+	}
+
+	if !employee.WorksAt(locationId, checkTime.In(r.timezone).Weekday()) || !service.OfferedAt(locationId) {
+		return false, nil // Not at this branch on that day
+	}
+
+	// The working hours, the calendar and the opening hours are read in the
+	// time zone of the branch
+	timezone, err := repository.LocationTimezone(r.locationRepository, locationId, r.timezone)
+	if err != nil {
+		return false, err
+	}
+	checkTime = checkTime.In(timezone)
+
 	checkEndTime := checkTime.Add(time.Duration(service.Duration) * time.Minute)
+	if !r.isWorking(employee, checkTime, checkEndTime) {
+		return false, nil // Outside working hours or on time off
+	}
+
+	open, _, err := r.calendarRepository.IsOpen(checkTime, checkEndTime)
+	if err != nil || !open {
+		return false, err // The business is closed
+	}
+
+	err = repository.CheckLocationOpen(r.locationRepository, locationId, checkTime, checkEndTime)
+	if errors.Is(err, repository.ErrBusinessClosed) {
+		return false, nil // The branch is closed
+	}
+	if err != nil {
+		return false, err
+	}
 
-	for _, booking := range dayBookings {
-		bookingEndTime := booking.BookingDateTime.Add(time.Duration(service.Duration) * time.Minute)
-		if checkTime.Before(bookingEndTime) && booking.BookingDateTime.Before(checkEndTime) {
-			return false, nil // There is an overlap
+	// Use the info in booking repository to check availability
+	blockedStart, blockedEnd := service.BlockedInterval(checkTime)
+	overlapping, err := r.bookingsRepository.GetOverlappingBookings(employee.ID, blockedStart, blockedEnd)
+	if err != nil {
+		return false, err
+	}
+
+	if len(overlapping) > 0 {
+		return false, nil // Overlaps another booking
+	}
+
+	holds, err := r.bookingsRepository.GetOverlappingHolds(employee.ID, blockedStart, blockedEnd)
+	if err != nil || len(holds) > 0 {
+		return false, err // Overlaps a hold of another client
+	}
+
+	for _, resourceId := range service.ResourcesIds {
+		resource, err := r.resourceRepository.GetResourceById(resourceId)
+		if err != nil {
+			return false, err
+		}
+		usage, err := r.bookingsRepository.GetResourceUsage(resourceId, blockedStart, blockedEnd)
+		if err != nil || usage >= int(resource.Capacity) {
+			return false, err // The resource is fully used
 		}
 	}
 
 	return true, nil
 }
 
-func (r *employeeMemoryRepository) GetServicesByEmployeeId(employeeId uint) ([]*repository.Service, error) {
+func (r *employeeMemoryRepository) FindAvailableSlots(serviceId uint, locationId uint, from time.Time, to time.Time, employeeId uint, limit int) ([]*repository.Slot, error) {
+	service, err := r.serviceRepository.GetServiceById(serviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	var employees []*repository.Employee
+	r.mu.RLock()
+	for _, employee := range r.employees {
+		if !employee.Retired && (employeeId == 0 || employee.ID == employeeId) && slices.Contains(employee.ServicesIds, serviceId) {
+			employees = append(employees, employee)
+		}
+	}
+	r.mu.RUnlock()
+	if employeeId != 0 && len(employees) == 0 {
+		return nil, errors.New("employee does not offer the service")
+	}
+	slices.SortFunc(employees, func(a, b *repository.Employee) int {
+		return int(a.ID) - int(b.ID)
+	})
+
+	slots := []*repository.Slot{}
+	for start := repository.FirstSlot(from.In(r.timezone), r.slotGranularity); start.Before(to); start = start.Add(r.slotGranularity) {
+		for _, employee := range employees {
+			slotLocationId := employee.ResolveLocation(locationId, start.Weekday())
+			available, err := r.isAvailable(employee, service, slotLocationId, start)
+			if err != nil {
+				return nil, err
+			}
+			if !available {
+				continue
+			}
+
+			slots = append(slots, &repository.Slot{EmployeeID: employee.ID, LocationID: slotLocationId, Start: start})
+			if limit > 0 && len(slots) == limit {
+				return slots, nil
+			}
+		}
+	}
+
+	return slots, nil
+}
+
+func (r *employeeMemoryRepository) FindBundleSlots(servicesIds []uint, locationId uint, from time.Time, to time.Time, limit int) ([]*repository.BundleSlot, error) {
+	steps, err := r.serviceSteps(servicesIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindBundleSlots(steps, locationId, from.In(r.timezone), to, r.slotGranularity, limit, r.isAvailable)
+}
+
+func (r *employeeMemoryRepository) FindGroupSlots(servicesIds []uint, locationId uint, from time.Time, to time.Time, limit int) ([]*repository.GroupSlot, error) {
+	steps, err := r.serviceSteps(servicesIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindGroupSlots(steps, locationId, from.In(r.timezone), to, r.slotGranularity, limit, r.isAvailable)
+}
+
+// serviceSteps returns the services with the employees offering them, sorted
+// by id.
+func (r *employeeMemoryRepository) serviceSteps(servicesIds []uint) ([]*repository.BundleStep, error) {
+	steps := make([]*repository.BundleStep, 0, len(servicesIds))
+	for _, serviceId := range servicesIds {
+		service, err := r.serviceRepository.GetServiceById(serviceId)
+		if err != nil {
+			return nil, err
+		}
+
+		employees, err := r.GetEmployeesForServiceId(serviceId)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(employees, func(a, b *repository.Employee) int {
+			return int(a.ID) - int(b.ID)
+		})
+		steps = append(steps, &repository.BundleStep{Service: service, Employees: employees})
+	}
+
+	return steps, nil
+}
+
+// isWorking reports whether the employee is at work during [start, end). The
+// caller must not hold the lock.
+func (r *employeeMemoryRepository) isWorking(employee *repository.Employee, start time.Time, end time.Time) bool {
+	if !employee.Schedule.Covers(start, end) {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, timeOff := range r.timeOff[employee.ID] {
+		if timeOff.Start.Before(end) && start.Before(timeOff.End) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *employeeMemoryRepository) IsWorking(employeeId uint, start time.Time, end time.Time) (bool, error) {
+	employee, err := r.GetEmployeeById(employeeId)
+	if err != nil {
+		return false, err
+	}
+
+	return r.isWorking(employee, start, end), nil
+}
+
+func (r *employeeMemoryRepository) AddTimeOff(timeOff *repository.TimeOff) ([]*repository.Booking, error) {
+	if err := r.addTimeOff(timeOff); err != nil {
+		return nil, err
+	}
+
+	// Read once the time off is stored, so the bookings made in the meantime
+	// are returned too
+	return r.bookingsRepository.GetOverlappingBookings(timeOff.EmployeeID, timeOff.Start, timeOff.End)
+}
+
+func (r *employeeMemoryRepository) addTimeOff(timeOff *repository.TimeOff) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.employees[timeOff.EmployeeID]; !ok {
+		return errors.New("employee not found")
+	}
+	if !timeOff.Start.Before(timeOff.End) {
+		return errors.New("time off must end after it starts")
+	}
+
+	if timeOff.ID == 0 {
+		timeOff.ID = r.nextTimeOffID
+		r.nextTimeOffID++
+	}
+	r.timeOff[timeOff.EmployeeID] = append(r.timeOff[timeOff.EmployeeID], timeOff)
+
+	return nil
+}
+
+func (r *employeeMemoryRepository) GetTimeOff(employeeId uint, from time.Time, to time.Time) ([]*repository.TimeOff, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	var timeOffs []*repository.TimeOff
+	for _, timeOff := range r.timeOff[employeeId] {
+		if timeOff.Start.Before(to) && from.Before(timeOff.End) {
+			timeOffs = append(timeOffs, timeOff)
+		}
+	}
+
+	slices.SortFunc(timeOffs, func(a, b *repository.TimeOff) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	return timeOffs, nil
+}
+
+func (r *employeeMemoryRepository) RemoveTimeOff(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for employeeId, timeOffs := range r.timeOff {
+		for i, timeOff := range timeOffs {
+			if timeOff.ID == id {
+				r.timeOff[employeeId] = slices.Delete(timeOffs, i, i+1)
+				return nil
+			}
+		}
+	}
+
+	return errors.New("time off not found")
+}
+
+func (r *employeeMemoryRepository) GetServicesByEmployeeId(employeeId uint) ([]*repository.Service, error) {
 	employee, err := r.GetEmployeeById(employeeId)
 	if err != nil {
 		return nil, err
@@ -115,15 +381,15 @@ func (r *employeeMemoryRepository) GetServicesByEmployeeId(employeeId uint) ([]*
 		if err != nil {
 			return nil, err
 		}
-		employeeServices = append(employeeServices, service)
+		if !service.Retired {
+			employeeServices = append(employeeServices, service)
+		}
 	}
 
 	return employeeServices, nil
 }
 
 func (r *employeeMemoryRepository) GetEmployeesForServiceId(serviceId uint) ([]*repository.Employee, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
 	employees, err := r.GetEmployees()
 	if err != nil {
 		return nil, err
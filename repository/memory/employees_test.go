@@ -0,0 +1,11 @@
+package memory_repository
+
+import (
+	"testing"
+
+	"valighita/bookings-ai-agent/repository/repositorytest"
+)
+
+func TestEmployeeRepository(t *testing.T) {
+	repositorytest.TestEmployeeRepository(t, newTestRepositories)
+}
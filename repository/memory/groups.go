@@ -0,0 +1,11 @@
+package memory_repository
+
+import (
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsMemoryRepository) ReserveGroup(bookings []*repository.Booking) error {
+	return r.reserveTogether(bookings, repository.ValidateGroup, "attendee %d of the group", func(booking *repository.Booking, id uint) {
+		booking.GroupID = id
+	})
+}
@@ -0,0 +1,141 @@
+package memory_repository
+
+import (
+	"slices"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsMemoryRepository) HoldSlot(hold *repository.Hold) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeExpiredHolds()
+
+	if err := r.prepareBooking(&hold.Booking); err != nil {
+		return err
+	}
+	if err := r.checkConflicts(&hold.Booking, hold.SessionID); err != nil {
+		return err
+	}
+
+	if hold.ID == 0 {
+		hold.ID = r.nextHoldID
+		r.nextHoldID++
+	}
+	r.holds[hold.ID] = hold
+
+	return nil
+}
+
+func (r *bookingsMemoryRepository) GetOverlappingHolds(employeeId uint, start time.Time, end time.Time) ([]*repository.Hold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.overlappingHolds(employeeId, start, end), nil
+}
+
+func (r *bookingsMemoryRepository) GetSessionHolds(sessionId string) ([]*repository.Hold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+
+	var holds []*repository.Hold
+	for _, hold := range r.holds {
+		if hold.SessionID == sessionId && hold.ExpiresAt.After(now) {
+			holds = append(holds, hold)
+		}
+	}
+
+	slices.SortFunc(holds, func(a, b *repository.Hold) int {
+		return int(a.ID) - int(b.ID)
+	})
+
+	return holds, nil
+}
+
+func (r *bookingsMemoryRepository) ReleaseSessionHolds(sessionId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, hold := range r.holds {
+		if hold.SessionID == sessionId {
+			delete(r.holds, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *bookingsMemoryRepository) ConfirmHold(holdId uint, customerName string, customerPhone string) (*repository.Booking, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeExpiredHolds()
+
+	hold, ok := r.holds[holdId]
+	if !ok {
+		return nil, repository.ErrHoldNotFound
+	}
+
+	booking := hold.Booking
+	booking.CustomerName = customerName
+	booking.CustomerPhone = customerPhone
+	if err := booking.InitStatus(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := r.checkConflicts(&booking, hold.SessionID); err != nil {
+		return nil, err
+	}
+	if err := r.linkCustomer(&booking); err != nil {
+		return nil, err
+	}
+
+	delete(r.holds, holdId)
+	booking.ID = r.nextID
+	r.nextID++
+	r.addBooking(&booking)
+
+	return &booking, nil
+}
+
+// overlappingHolds returns the active holds of the employee whose blocked
+// interval overlaps [start, end). The caller must hold the lock.
+func (r *bookingsMemoryRepository) overlappingHolds(employeeId uint, start time.Time, end time.Time) []*repository.Hold {
+	return r.overlappingHoldsFunc(start, end, func(hold *repository.Hold) bool {
+		return hold.Booking.EmployeeID == employeeId
+	})
+}
+
+// overlappingHoldsFunc returns the active holds matching match whose blocked
+// interval overlaps [start, end). The caller must hold the lock.
+func (r *bookingsMemoryRepository) overlappingHoldsFunc(start time.Time, end time.Time, match func(*repository.Hold) bool) []*repository.Hold {
+	now := time.Now()
+
+	var holds []*repository.Hold
+	for _, hold := range r.holds {
+		if !match(hold) || !hold.ExpiresAt.After(now) {
+			continue
+		}
+
+		blockedStart, blockedEnd := hold.Booking.BlockedInterval()
+		if blockedStart.Before(end) && start.Before(blockedEnd) {
+			holds = append(holds, hold)
+		}
+	}
+
+	return holds
+}
+
+// removeExpiredHolds deletes the holds past their expiration. The caller must
+// hold the write lock.
+func (r *bookingsMemoryRepository) removeExpiredHolds() {
+	now := time.Now()
+	for id, hold := range r.holds {
+		if !hold.ExpiresAt.After(now) {
+			delete(r.holds, id)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package memory_repository
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type locationsMemoryRepository struct {
+	mu        sync.RWMutex
+	locations map[uint]*repository.Location
+}
+
+func NewLocationsMemoryRepository(data map[uint]*repository.Location) repository.LocationRepository {
+	return &locationsMemoryRepository{
+		locations: data,
+	}
+}
+
+func (r *locationsMemoryRepository) GetLocations() ([]*repository.Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	locations := make([]*repository.Location, 0, len(r.locations))
+	for _, location := range r.locations {
+		if !location.Retired {
+			locations = append(locations, location)
+		}
+	}
+
+	slices.SortFunc(locations, func(a, b *repository.Location) int {
+		return int(a.ID) - int(b.ID)
+	})
+
+	return locations, nil
+}
+
+func (r *locationsMemoryRepository) GetLocationById(id uint) (*repository.Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	location, ok := r.locations[id]
+	if !ok {
+		return nil, errors.New("location not found")
+	}
+
+	return location, nil
+}
+
+func (r *locationsMemoryRepository) GetLocationByName(name string) (*repository.Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, location := range r.locations {
+		if !location.Retired && strings.EqualFold(location.Name, name) {
+			return location, nil
+		}
+	}
+
+	return nil, errors.New("location not found")
+}
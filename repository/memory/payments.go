@@ -0,0 +1,83 @@
+package memory_repository
+
+import (
+	"slices"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsMemoryRepository) SetBookingPayment(id uint, paymentId string, link string, dueAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	booking, _ := r.findBooking(id)
+	if booking == nil {
+		return repository.ErrBookingNotFound
+	}
+
+	booking.PaymentID = paymentId
+	booking.PaymentLink = link
+	booking.PaymentDueAt = dueAt
+	return nil
+}
+
+func (r *bookingsMemoryRepository) GetBookingsByPaymentId(paymentId string) ([]*repository.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bookings := r.filterBookings(func(booking *repository.Booking) bool {
+		return paymentId != "" && booking.PaymentID == paymentId
+	})
+	if len(bookings) == 0 {
+		return nil, repository.ErrPaymentNotFound
+	}
+
+	return bookings, nil
+}
+
+func (r *bookingsMemoryRepository) MarkBookingPaid(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	booking, _ := r.findBooking(id)
+	if booking == nil {
+		return repository.ErrBookingNotFound
+	}
+
+	if err := booking.SetStatus(repository.BookingConfirmed, at); err != nil {
+		return err
+	}
+	booking.PaidAt = at
+	return nil
+}
+
+func (r *bookingsMemoryRepository) GetUnpaidBookings(dueBefore time.Time) ([]*repository.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filterBookings(func(booking *repository.Booking) bool {
+		return booking.AwaitingPayment() && !booking.PaymentDueAt.IsZero() && booking.PaymentDueAt.Before(dueBefore)
+	}), nil
+}
+
+// filterBookings returns the bookings matching match in chronological order.
+// The caller must hold the lock.
+func (r *bookingsMemoryRepository) filterBookings(match func(*repository.Booking) bool) []*repository.Booking {
+	var bookings []*repository.Booking
+	for _, dateBookings := range r.bookings {
+		for _, booking := range dateBookings {
+			if match(booking) {
+				bookings = append(bookings, booking)
+			}
+		}
+	}
+
+	slices.SortFunc(bookings, func(a, b *repository.Booking) int {
+		if c := a.BookingDateTime.Compare(b.BookingDateTime); c != 0 {
+			return c
+		}
+		return int(a.ID) - int(b.ID)
+	})
+	return bookings
+}
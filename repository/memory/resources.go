@@ -0,0 +1,50 @@
+package memory_repository
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type resourcesMemoryRepository struct {
+	mu        sync.RWMutex
+	resources map[uint]*repository.Resource
+}
+
+func NewResourcesMemoryRepository(data map[uint]*repository.Resource) repository.ResourceRepository {
+	return &resourcesMemoryRepository{
+		resources: data,
+	}
+}
+
+func (r *resourcesMemoryRepository) GetResources() ([]*repository.Resource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resources := make([]*repository.Resource, 0, len(r.resources))
+	for _, resource := range r.resources {
+		if !resource.Retired {
+			resources = append(resources, resource)
+		}
+	}
+
+	slices.SortFunc(resources, func(a, b *repository.Resource) int {
+		return int(a.ID) - int(b.ID)
+	})
+
+	return resources, nil
+}
+
+func (r *resourcesMemoryRepository) GetResourceById(id uint) (*repository.Resource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resource, ok := r.resources[id]
+	if !ok {
+		return nil, errors.New("resource not found")
+	}
+
+	return resource, nil
+}
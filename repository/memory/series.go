@@ -0,0 +1,121 @@
+package memory_repository
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsMemoryRepository) ReserveSeries(series *repository.Series, bookings []*repository.Booking) error {
+	if len(bookings) == 0 {
+		return errors.New("series has no bookings")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeExpiredHolds()
+
+	var conflicts []*repository.OccurrenceConflict
+	for _, booking := range bookings {
+		err := r.prepareBooking(booking)
+		if err == nil {
+			err = r.checkConflicts(booking, "")
+		}
+		if repository.IsOccurrenceConflict(err) {
+			conflicts = append(conflicts, &repository.OccurrenceConflict{Start: booking.BookingDateTime, Err: err})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		return &repository.SeriesConflictError{Conflicts: conflicts}
+	}
+	for _, booking := range bookings {
+		if err := r.linkCustomer(booking); err != nil {
+			return err
+		}
+	}
+
+	if series.ID == 0 {
+		series.ID = r.nextSeriesID
+		r.nextSeriesID++
+	}
+	series.BookingsIds = make([]uint, 0, len(bookings))
+	for _, booking := range bookings {
+		if booking.ID == 0 {
+			booking.ID = r.nextID
+			r.nextID++
+		}
+		booking.SeriesID = series.ID
+		r.addBooking(booking)
+		series.BookingsIds = append(series.BookingsIds, booking.ID)
+	}
+	r.series[series.ID] = &repository.Series{ID: series.ID, Recurrence: series.Recurrence}
+
+	return nil
+}
+
+func (r *bookingsMemoryRepository) GetSeries(id uint) (*repository.Series, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	series, ok := r.series[id]
+	if !ok {
+		return nil, repository.ErrSeriesNotFound
+	}
+
+	result := *series
+	for _, booking := range r.seriesBookings(id) {
+		result.BookingsIds = append(result.BookingsIds, booking.ID)
+	}
+
+	return &result, nil
+}
+
+func (r *bookingsMemoryRepository) CancelSeries(id uint) ([]*repository.Booking, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.series[id]; !ok {
+		return nil, repository.ErrSeriesNotFound
+	}
+
+	now := time.Now()
+
+	var cancelled []*repository.Booking
+	for _, booking := range r.seriesBookings(id) {
+		if booking.BookingDateTime.Before(now) || !booking.Status.CanTransitionTo(repository.BookingCancelled) {
+			continue
+		}
+		if err := booking.SetStatus(repository.BookingCancelled, now); err != nil {
+			return nil, err
+		}
+		cancelled = append(cancelled, booking)
+	}
+
+	return cancelled, nil
+}
+
+// seriesBookings returns the bookings of the series in chronological order.
+// The caller must hold the lock.
+func (r *bookingsMemoryRepository) seriesBookings(seriesId uint) []*repository.Booking {
+	var bookings []*repository.Booking
+	for _, dateBookings := range r.bookings {
+		for _, booking := range dateBookings {
+			if booking.SeriesID == seriesId {
+				bookings = append(bookings, booking)
+			}
+		}
+	}
+
+	slices.SortFunc(bookings, func(a, b *repository.Booking) int {
+		return a.BookingDateTime.Compare(b.BookingDateTime)
+	})
+
+	return bookings
+}
@@ -25,7 +25,9 @@ func (r *servicesMemoryRepository) GetServices() ([]*repository.Service, error)
 
 	services := make([]*repository.Service, 0, len(r.services))
 	for _, service := range r.services {
-		services = append(services, service)
+		if !service.Retired {
+			services = append(services, service)
+		}
 	}
 
 	return services, nil
@@ -41,7 +43,7 @@ func (r *servicesMemoryRepository) GetServiceById(id uint) (*repository.Service,
 		}
 	}
 
-	return nil, nil
+	return nil, errors.New("service not found")
 }
 
 func (r *servicesMemoryRepository) GetServiceByName(name string) (*repository.Service, error) {
@@ -49,7 +51,7 @@ func (r *servicesMemoryRepository) GetServiceByName(name string) (*repository.Se
 	defer r.mu.RUnlock()
 
 	for _, service := range r.services {
-		if strings.ToLower(service.Name) == strings.ToLower(name) {
+		if !service.Retired && strings.ToLower(service.Name) == strings.ToLower(name) {
 			return service, nil
 		}
 	}
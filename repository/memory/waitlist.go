@@ -0,0 +1,104 @@
+package memory_repository
+
+import (
+	"slices"
+	"sync"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type waitlistMemoryRepository struct {
+	mu sync.RWMutex
+	// map that stores copies of the entries indexed by id, so changes are only
+	// saved by UpdateEntry
+	entries map[uint]*repository.WaitlistEntry
+	nextID  uint
+}
+
+func NewWaitlistMemoryRepository() repository.WaitlistRepository {
+	return &waitlistMemoryRepository{
+		entries: make(map[uint]*repository.WaitlistEntry),
+		nextID:  1,
+	}
+}
+
+func (r *waitlistMemoryRepository) AddEntry(entry *repository.WaitlistEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry.ID == 0 {
+		entry.ID = r.nextID
+		r.nextID++
+	}
+	stored := *entry
+	r.entries[entry.ID] = &stored
+
+	return nil
+}
+
+func (r *waitlistMemoryRepository) GetEntryById(id uint) (*repository.WaitlistEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.entries[id]
+	if !ok {
+		return nil, repository.ErrWaitlistEntryNotFound
+	}
+
+	result := *entry
+	return &result, nil
+}
+
+func (r *waitlistMemoryRepository) GetEntriesByStatus(status repository.WaitlistStatus) ([]*repository.WaitlistEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filterEntries(func(entry *repository.WaitlistEntry) bool {
+		return entry.Status == status
+	}), nil
+}
+
+func (r *waitlistMemoryRepository) GetEntriesByCustomer(phone string) ([]*repository.WaitlistEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	phone = repository.NormalizePhone(phone)
+	return r.filterEntries(func(entry *repository.WaitlistEntry) bool {
+		return entry.CustomerPhone == phone &&
+			(entry.Status == repository.WaitlistWaiting || entry.Status == repository.WaitlistOffered)
+	}), nil
+}
+
+func (r *waitlistMemoryRepository) UpdateEntry(entry *repository.WaitlistEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[entry.ID]; !ok {
+		return repository.ErrWaitlistEntryNotFound
+	}
+	stored := *entry
+	r.entries[entry.ID] = &stored
+
+	return nil
+}
+
+// filterEntries returns copies of the entries matching match, in the order
+// they were added. The caller must hold the lock.
+func (r *waitlistMemoryRepository) filterEntries(match func(*repository.WaitlistEntry) bool) []*repository.WaitlistEntry {
+	var entries []*repository.WaitlistEntry
+	for _, entry := range r.entries {
+		if match(entry) {
+			result := *entry
+			entries = append(entries, &result)
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b *repository.WaitlistEntry) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return int(a.ID) - int(b.ID)
+	})
+
+	return entries
+}
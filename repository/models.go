@@ -1,19 +1,184 @@
 package repository
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Repositories groups the repositories holding the data of a business.
+type Repositories struct {
+	Bookings  BookingRepository
+	Services  ServiceRepository
+	Employees EmployeeRepository
+	Calendar  CalendarRepository
+	Resources ResourceRepository
+	Locations LocationRepository
+	Waitlist  WaitlistRepository
+	Customers CustomerRepository
+	Catalog   CatalogRepository
+	// Timezone is the business time zone, dates and times of day are read and
+	// shown in it
+	Timezone *time.Location
+	// PhoneCountry is the ISO 3166 code of the country of the national phone
+	// numbers, empty if numbers must be international
+	PhoneCountry string
+}
+
+var (
+	ErrBookingNotFound  = errors.New("booking not found")
+	ErrSlotNotAvailable = errors.New("slot is not available")
+	ErrHoldNotFound     = errors.New("hold not found or expired")
+	// ErrEmployeeNotWorking is returned when a booking falls outside the
+	// working hours of its employee, during their time off or at a branch
+	// where they don't work that day. It matches ErrSlotNotAvailable.
+	ErrEmployeeNotWorking = fmt.Errorf("%w: employee is not working", ErrSlotNotAvailable)
+)
+
+// SlotConflictError is returned when a booking can't be stored because the
+// employee already has an overlapping booking or hold, or a resource it needs
+// is fully used. It matches ErrSlotNotAvailable.
+type SlotConflictError struct {
+	EmployeeID uint
+	Start      time.Time
+	// Only one of the conflicting ids is set
+	ConflictingBookingID  uint
+	ConflictingHoldID     uint
+	ConflictingResourceID uint
+}
+
+func (e *SlotConflictError) Error() string {
+	if e.ConflictingResourceID != 0 {
+		return fmt.Sprintf("slot at %s for employee %d needs resource %d which is fully booked",
+			e.Start.Format("2006-01-02 15:04"), e.EmployeeID, e.ConflictingResourceID)
+	}
+	if e.ConflictingHoldID != 0 {
+		return fmt.Sprintf("slot at %s for employee %d conflicts with hold %d",
+			e.Start.Format("2006-01-02 15:04"), e.EmployeeID, e.ConflictingHoldID)
+	}
+	return fmt.Sprintf("slot at %s for employee %d conflicts with booking %d",
+		e.Start.Format("2006-01-02 15:04"), e.EmployeeID, e.ConflictingBookingID)
+}
+
+func (e *SlotConflictError) Is(target error) bool {
+	return target == ErrSlotNotAvailable
+}
 
 type Employee struct {
 	ID          uint
 	Name        string
 	Description string
 	ServicesIds []uint
+	// Schedule holds the weekly working hours, nil means no restriction.
+	Schedule WeeklySchedule
+	// Locations holds the branch the employee works at on each weekday, nil
+	// means the employee isn't tied to a branch.
+	Locations WeeklyLocations
+	// Retired employees were removed from the catalog, see Catalog.
+	Retired bool
+}
+
+// ResolveLocation returns locationId, or when it is 0 the branch where the
+// employee works on the weekday, if any.
+func (e *Employee) ResolveLocation(locationId uint, weekday time.Weekday) uint {
+	if locationId != 0 {
+		return locationId
+	}
+	return e.Locations[weekday]
+}
+
+// WorksAt reports whether the employee works at the branch on the weekday. A
+// locationId of 0 matches any branch.
+func (e *Employee) WorksAt(locationId uint, weekday time.Weekday) bool {
+	return locationId == 0 || e.Locations == nil || e.Locations[weekday] == locationId
+}
+
+// CheckWorking returns an error wrapping ErrEmployeeNotWorking if the employee
+// can't take the booking: they are retired or no longer offer the service,
+// they work at a different branch that day, or they aren't at work during the
+// appointment according to their schedule and timeOff, their absences around
+// it. The schedule is read in timezone, the one of the branch of the booking.
+func (e *Employee) CheckWorking(booking *Booking, timeOff []*TimeOff, timezone *time.Location) error {
+	start, end := booking.BookingDateTime.In(timezone), booking.EndDateTime()
+	if e.Retired {
+		return fmt.Errorf("%w: %s no longer works here", ErrEmployeeNotWorking, e.Name)
+	}
+	if !slices.Contains(e.ServicesIds, booking.ServiceID) {
+		return fmt.Errorf("%w: %s doesn't offer service %d", ErrEmployeeNotWorking, e.Name, booking.ServiceID)
+	}
+	// The branch of the weekday is looked up in the business time zone, see
+	// WeeklyLocations
+	if weekday := booking.BookingDateTime.Weekday(); !e.WorksAt(booking.LocationID, weekday) {
+		return fmt.Errorf("%w: %s doesn't work at location %d on %s", ErrEmployeeNotWorking, e.Name, booking.LocationID, weekday)
+	}
+	if !e.Schedule.Covers(start, end) {
+		return fmt.Errorf("%w: %s is outside the working hours of %s", ErrEmployeeNotWorking, start.Format("2006-01-02 15:04"), e.Name)
+	}
+	for _, absence := range timeOff {
+		if absence.Start.Before(end) && start.Before(absence.End) {
+			return fmt.Errorf("%w: %s is off at %s", ErrEmployeeNotWorking, e.Name, start.Format("2006-01-02 15:04"))
+		}
+	}
+	return nil
+}
+
+// TimeOff is an absence of an employee, e.g. a vacation or a sick day. Full
+// days off start and end at midnight.
+type TimeOff struct {
+	ID         uint
+	EmployeeID uint
+	Start      time.Time
+	End        time.Time
+	Reason     string
+}
+
+// Slot is a free start time for a booking with an employee, at a branch if
+// LocationID is not 0.
+type Slot struct {
+	EmployeeID uint
+	LocationID uint
+	Start      time.Time
 }
 
 type EmployeeRepository interface {
 	GetEmployees() ([]*Employee, error)
 	GetEmployeeById(id uint) (*Employee, error)
 	GetEmployeeByName(name string) (*Employee, error)
-	CheckAvailability(employeeId uint, serviceId uint, bookingDate string, bookingTime string) (bool, error)
+	// CheckAvailability reports whether the employee can perform the service
+	// at the branch on the given date and time. A locationId of 0 means the
+	// branch where the employee works that day.
+	CheckAvailability(employeeId uint, serviceId uint, locationId uint, bookingDate string, bookingTime string) (bool, error)
+	// FindAvailableSlots returns up to limit free start times for the service
+	// in [from, to), aligned to the slot granularity, in chronological order.
+	// An employeeId of 0 searches all the employees offering the service, a
+	// locationId of 0 searches all the branches and a limit of 0 returns all
+	// the slots.
+	FindAvailableSlots(serviceId uint, locationId uint, from time.Time, to time.Time, employeeId uint, limit int) ([]*Slot, error)
+	// FindBundleSlots returns up to limit visits starting in [from, to) where
+	// the services are performed back-to-back in the given order, at the same
+	// branch, by any employees offering them. Visits start at multiples of the
+	// slot granularity, in chronological order, with at most one visit per
+	// start time. A locationId of 0 searches all the branches and a limit of 0
+	// returns all the visits.
+	FindBundleSlots(servicesIds []uint, locationId uint, from time.Time, to time.Time, limit int) ([]*BundleSlot, error)
+	// FindGroupSlots returns up to limit ways to book a group whose attendees
+	// need the given services, one per attendee, at the same branch, starting
+	// in [from, to). Attendees are placed in parallel with different employees
+	// when possible, otherwise after the end of another attendee. Groups start
+	// at multiples of the slot granularity, in chronological order, with at
+	// most one per start time. A locationId of 0 searches all the branches and
+	// a limit of 0 returns all the groups.
+	FindGroupSlots(servicesIds []uint, locationId uint, from time.Time, to time.Time, limit int) ([]*GroupSlot, error)
+	// IsWorking reports whether the employee is at work for the whole [start,
+	// end) interval, according to their schedule and time off.
+	IsWorking(employeeId uint, start time.Time, end time.Time) (bool, error)
+	// AddTimeOff records an absence and returns the existing bookings of the
+	// employee that overlap it, so they can be rescheduled.
+	AddTimeOff(timeOff *TimeOff) ([]*Booking, error)
+	// GetTimeOff returns the absences of the employee overlapping [from, to).
+	GetTimeOff(employeeId uint, from time.Time, to time.Time) ([]*TimeOff, error)
+	RemoveTimeOff(id uint) error
 	GetServicesByEmployeeId(employeeId uint) ([]*Service, error)
 	GetEmployeesForServiceId(serviceId uint) ([]*Employee, error)
 }
@@ -23,6 +188,63 @@ type Service struct {
 	Name     string
 	Price    float64
 	Duration uint // In minutes
+	// Preparation and cleanup time around each appointment, in minutes
+	BufferBefore uint
+	BufferAfter  uint
+	// Resources needed to perform the service, e.g. a surgery room
+	ResourcesIds []uint
+	// Branches offering the service, nil means all of them
+	LocationsIds []uint
+	// RecallMonths is how often clients should come back for the service,
+	// e.g. 6 for a cleaning, 0 if they don't need to
+	RecallMonths uint
+	// Category groups related services, e.g. preventive care
+	Category string
+	// Description explains to the clients what the service involves
+	Description string
+	// Preparation is what clients must do before the appointment and
+	// Aftercare what they must do after it, empty if nothing
+	Preparation string
+	Aftercare   string
+	// MinAge and MaxAge restrict the clients the service is for, in years. 0
+	// means no limit.
+	MinAge uint
+	MaxAge uint
+	// Deposit is the amount to pay in advance when booking the service, 0 if
+	// none. Bookings with a deposit stay pending until it is paid.
+	Deposit float64
+	// Retired services were removed from the catalog, see Catalog.
+	Retired bool
+}
+
+// Validate checks the age restriction and the deposit of the service.
+func (s *Service) Validate() error {
+	if s.MinAge != 0 && s.MaxAge != 0 && s.MinAge > s.MaxAge {
+		return fmt.Errorf("minimum age %d is above the maximum age %d", s.MinAge, s.MaxAge)
+	}
+	if s.Deposit < 0 || s.Deposit > s.Price {
+		return fmt.Errorf("deposit %.2f must be between 0 and the price %.2f", s.Deposit, s.Price)
+	}
+	return nil
+}
+
+// AllowsAge reports whether a client of the given age, in years, can have the
+// service.
+func (s *Service) AllowsAge(age uint) bool {
+	return age >= s.MinAge && (s.MaxAge == 0 || age <= s.MaxAge)
+}
+
+// OfferedAt reports whether the service is offered at the branch. A
+// locationId of 0 matches any branch.
+func (s *Service) OfferedAt(locationId uint) bool {
+	return locationId == 0 || s.LocationsIds == nil || slices.Contains(s.LocationsIds, locationId)
+}
+
+// BlockedInterval returns when an employee is busy performing the service
+// starting at start, buffers included.
+func (s *Service) BlockedInterval(start time.Time) (time.Time, time.Time) {
+	end := start.Add(time.Duration(s.Duration) * time.Minute)
+	return start.Add(-time.Duration(s.BufferBefore) * time.Minute), end.Add(time.Duration(s.BufferAfter) * time.Minute)
 }
 
 type ServiceRepository interface {
@@ -32,15 +254,178 @@ type ServiceRepository interface {
 }
 
 type Booking struct {
-	ID              uint
-	EmployeeID      uint
-	ServiceID       uint
+	ID         uint
+	EmployeeID uint
+	ServiceID  uint
+	// LocationID is the branch of the appointment, 0 if the business has no
+	// branches
+	LocationID      uint
 	BookingDateTime time.Time
-	CustomerName    string
-	CustomerPhone   string
+	// CustomerName is the name of the customer who made the booking
+	CustomerName string
+	// AttendeeName is the person the appointment is for when it isn't the
+	// customer, e.g. a child booked by a parent, empty otherwise
+	AttendeeName string
+	// CustomerPhone is normalized with NormalizePhone when the booking is
+	// stored
+	CustomerPhone string
+	// CustomerID is the customer the booking is linked to by its phone number,
+	// 0 for bookings without one
+	CustomerID uint
+	// Duration and buffers are a snapshot of the service at booking time, so
+	// catalog changes don't affect existing bookings. All in minutes.
+	Duration     uint
+	BufferBefore uint
+	BufferAfter  uint
+	// Resources used by the booking, for its whole blocked interval
+	ResourcesIds []uint
+	// Deposit is a snapshot of the deposit of the service, 0 if none is due
+	Deposit float64
+	// PaymentID and PaymentLink identify the payment of the deposit at the
+	// payment provider, shared by the bookings paid together. Unpaid bookings
+	// are cancelled after PaymentDueAt. PaidAt is zero until the deposit is
+	// paid.
+	PaymentID    string
+	PaymentLink  string
+	PaymentDueAt time.Time
+	PaidAt       time.Time
+	// SeriesID is the recurring series the booking is part of, 0 for a
+	// one-off booking
+	SeriesID uint
+	// BundleID is the id of the first booking of the visit the booking is part
+	// of, when several services are booked back-to-back, 0 otherwise
+	BundleID uint
+	// GroupID is the id of the first booking of the group the booking is part
+	// of, when several attendees are booked together, 0 otherwise
+	GroupID uint
+	Status  BookingStatus
+	// When the booking was made and moved to each status, zero if it didn't
+	CreatedAt   time.Time
+	ConfirmedAt time.Time
+	CheckedInAt time.Time
+	CompletedAt time.Time
+	CancelledAt time.Time
+	NoShowAt    time.Time
+}
+
+// SetServiceSnapshot copies the duration, buffers, resources and deposit of the
+// service.
+func (b *Booking) SetServiceSnapshot(service *Service) {
+	b.Duration = service.Duration
+	b.BufferBefore = service.BufferBefore
+	b.BufferAfter = service.BufferAfter
+	b.ResourcesIds = slices.Clone(service.ResourcesIds)
+	b.Deposit = service.Deposit
+}
+
+func (b *Booking) EndDateTime() time.Time {
+	return b.BookingDateTime.Add(time.Duration(b.Duration) * time.Minute)
+}
+
+// BlockedInterval returns when the employee is busy with the booking, buffers
+// included.
+func (b *Booking) BlockedInterval() (time.Time, time.Time) {
+	return b.BookingDateTime.Add(-time.Duration(b.BufferBefore) * time.Minute), b.EndDateTime().Add(time.Duration(b.BufferAfter) * time.Minute)
+}
+
+// Hold is a temporary reservation of a slot while a client confirms the
+// booking. Until it expires it blocks the slot for every other session.
+type Hold struct {
+	ID        uint
+	SessionID string
+	ExpiresAt time.Time
+	// Booking is the held slot: employee, service, start time and service
+	// snapshot. The customer details are filled in when confirming.
+	Booking Booking
 }
 
 type BookingRepository interface {
+	// GetBookingsByDateAndEmployee returns the bookings of the employee on the
+	// date, whatever their status.
 	GetBookingsByDateAndEmployee(date string, employeeId uint) ([]*Booking, error)
-	SaveBooking(booking *Booking) error
+	GetBookingById(id uint) (*Booking, error)
+	// GetOverlappingBookings returns the bookings of the employee whose
+	// blocked interval, buffers included, overlaps [start, end). Bookings whose
+	// status doesn't occupy their slot, e.g. cancelled ones, are left out here
+	// and in every availability check.
+	GetOverlappingBookings(employeeId uint, start time.Time, end time.Time) ([]*Booking, error)
+	// GetUpcomingBookingsByCustomer returns the customer's future bookings that
+	// occupy their slot, in chronological order. The phone can be written in
+	// any format, an empty name matches any name booked with phone.
+	GetUpcomingBookingsByCustomer(phone string, name string) ([]*Booking, error)
+	// GetBookingsByCustomerId returns the booking history of the customer,
+	// past and upcoming and whatever their status, in chronological order.
+	GetBookingsByCustomerId(customerId uint) ([]*Booking, error)
+	// ReserveBooking stores a new booking if the employee is working and free,
+	// and the resources it uses are free, for its whole blocked interval. The
+	// checks and the insert are atomic: when the slot is taken or held a
+	// *SlotConflictError is returned, and when the employee isn't working an
+	// error wrapping ErrEmployeeNotWorking, see Employee.CheckWorking. When
+	// the booking has no duration, it is copied from the service together
+	// with the buffers and the resources. New bookings are confirmed unless
+	// their status is set to pending. Every new booking with a phone
+	// number, including the ones made by ConfirmHold and ReserveSeries, is
+	// linked to its customer with CustomerRepository.UpsertCustomer.
+	ReserveBooking(booking *Booking) error
+	// HoldSlot places hold on its slot until hold.ExpiresAt, with the same
+	// checks as ReserveBooking. Holds of the same session don't conflict with
+	// each other. Expired holds are removed automatically.
+	HoldSlot(hold *Hold) error
+	// GetOverlappingHolds returns the active holds of the employee whose
+	// blocked interval overlaps [start, end).
+	GetOverlappingHolds(employeeId uint, start time.Time, end time.Time) ([]*Hold, error)
+	// GetResourceUsage returns the highest number of bookings and active holds
+	// using the resource at the same time during [start, end).
+	GetResourceUsage(resourceId uint, start time.Time, end time.Time) (int, error)
+	// GetSessionHolds returns the active holds placed by a session.
+	GetSessionHolds(sessionId string) ([]*Hold, error)
+	ReleaseSessionHolds(sessionId string) error
+	// ConfirmHold atomically turns an active hold into a booking for the
+	// customer. ErrHoldNotFound is returned if the hold has expired.
+	ConfirmHold(holdId uint, customerName string, customerPhone string) (*Booking, error)
+	// ReserveSeries atomically stores the bookings as the occurrences of a new
+	// series, with the same checks as ReserveBooking. Either all of them are
+	// booked or none: the occurrences that can't be booked are listed in a
+	// *SeriesConflictError. It sets the ids of the series and the bookings.
+	ReserveSeries(series *Series, bookings []*Booking) error
+	// ReserveBundle atomically stores the bookings of a visit where several
+	// services are performed back-to-back, see ValidateBundle, with the same
+	// checks as ReserveBooking. Either all of them are booked or none, the
+	// error tells which service can't be booked. BundleID is set to the id of
+	// the first booking on all of them.
+	ReserveBundle(bookings []*Booking) error
+	// ReserveGroup atomically stores the bookings of the attendees of a group,
+	// see ValidateGroup, with the same checks as ReserveBooking. Either all of
+	// them are booked or none, the error tells which attendee can't be booked.
+	// GroupID is set to the id of the first booking on all of them.
+	ReserveGroup(bookings []*Booking) error
+	GetSeries(id uint) (*Series, error)
+	// CancelSeries cancels the upcoming bookings of a series and returns them.
+	// Past ones are kept.
+	CancelSeries(id uint) ([]*Booking, error)
+	// CancelBooking cancels an upcoming booking, freeing its slot.
+	CancelBooking(id uint) error
+	// SetBookingStatus moves a booking to status, recording when it happened.
+	// A *StatusTransitionError is returned if the booking can't move there.
+	SetBookingStatus(id uint, status BookingStatus) error
+	// RescheduleBooking moves a pending or confirmed booking to newDateTime,
+	// with the same employee and service. The new slot is checked and taken
+	// atomically, with the same checks as ReserveBooking; when it can't be
+	// taken the original booking is left in place. The bookings of a visit or a group can't be moved on their
+	// own, ErrBookedInBundle or ErrBookedInGroup is returned.
+	RescheduleBooking(id uint, newDateTime time.Time) error
+	// SetBookingPayment records the payment requested for the deposit of a
+	// booking. The booking is cancelled by the payments processing if it isn't
+	// paid by dueAt.
+	SetBookingPayment(id uint, paymentId string, link string, dueAt time.Time) error
+	// GetBookingsByPaymentId returns the bookings paid with the payment, in
+	// chronological order, or ErrPaymentNotFound if there are none.
+	GetBookingsByPaymentId(paymentId string) ([]*Booking, error)
+	// MarkBookingPaid records that the deposit of a booking was paid at the
+	// given time and confirms it. A *StatusTransitionError is returned if the
+	// booking isn't pending anymore, e.g. because it expired.
+	MarkBookingPaid(id uint, at time.Time) error
+	// GetUnpaidBookings returns the bookings still awaiting the payment of
+	// their deposit whose payment was due before dueBefore.
+	GetUnpaidBookings(dueBefore time.Time) ([]*Booking, error)
 }
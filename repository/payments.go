@@ -0,0 +1,11 @@
+package repository
+
+import "errors"
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// AwaitingPayment reports whether the booking is pending until its deposit is
+// paid.
+func (b *Booking) AwaitingPayment() bool {
+	return b.Deposit > 0 && b.PaidAt.IsZero() && b.Status == BookingPending
+}
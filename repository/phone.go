@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidPhone is wrapped by the errors returned by ParsePhone.
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// PhoneError describes why a phone number was rejected, in words that can be
+// repeated to the customer.
+type PhoneError struct {
+	Phone  string
+	Reason string
+}
+
+func (e *PhoneError) Error() string {
+	return fmt.Sprintf("phone number %q %s", e.Phone, e.Reason)
+}
+
+func (e *PhoneError) Unwrap() error {
+	return ErrInvalidPhone
+}
+
+// phoneCountry is the numbering plan of a country: its calling code, the
+// prefix dialed before national numbers and the length of the national
+// numbers without it.
+type phoneCountry struct {
+	callingCode string
+	trunkPrefix string
+	minLength   int
+	maxLength   int
+}
+
+// phoneCountries are the countries that can be used as the default country of
+// a business, by ISO 3166 code. International numbers of other countries are
+// accepted too, with a generic length check.
+var phoneCountries = map[string]phoneCountry{
+	"AT": {callingCode: "43", trunkPrefix: "0", minLength: 4, maxLength: 13},
+	"AU": {callingCode: "61", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"BE": {callingCode: "32", trunkPrefix: "0", minLength: 8, maxLength: 9},
+	"BG": {callingCode: "359", trunkPrefix: "0", minLength: 8, maxLength: 9},
+	"CA": {callingCode: "1", trunkPrefix: "1", minLength: 10, maxLength: 10},
+	"CH": {callingCode: "41", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"CZ": {callingCode: "420", minLength: 9, maxLength: 9},
+	"DE": {callingCode: "49", trunkPrefix: "0", minLength: 6, maxLength: 13},
+	"DK": {callingCode: "45", minLength: 8, maxLength: 8},
+	"ES": {callingCode: "34", minLength: 9, maxLength: 9},
+	"FI": {callingCode: "358", trunkPrefix: "0", minLength: 5, maxLength: 12},
+	"FR": {callingCode: "33", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"GB": {callingCode: "44", trunkPrefix: "0", minLength: 9, maxLength: 10},
+	"GR": {callingCode: "30", minLength: 10, maxLength: 10},
+	"HU": {callingCode: "36", trunkPrefix: "06", minLength: 8, maxLength: 9},
+	"IE": {callingCode: "353", trunkPrefix: "0", minLength: 7, maxLength: 9},
+	"IN": {callingCode: "91", trunkPrefix: "0", minLength: 10, maxLength: 10},
+	"IT": {callingCode: "39", minLength: 6, maxLength: 11},
+	"MD": {callingCode: "373", trunkPrefix: "0", minLength: 8, maxLength: 8},
+	"NL": {callingCode: "31", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"NO": {callingCode: "47", minLength: 8, maxLength: 8},
+	"NZ": {callingCode: "64", trunkPrefix: "0", minLength: 8, maxLength: 10},
+	"PL": {callingCode: "48", minLength: 9, maxLength: 9},
+	"PT": {callingCode: "351", minLength: 9, maxLength: 9},
+	"RO": {callingCode: "40", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"SE": {callingCode: "46", trunkPrefix: "0", minLength: 7, maxLength: 9},
+	"SK": {callingCode: "421", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"UA": {callingCode: "380", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"US": {callingCode: "1", trunkPrefix: "1", minLength: 10, maxLength: 10},
+}
+
+// E.164 numbers have at most 15 digits, calling code included. Numbers with an
+// unknown calling code must have at least minInternationalLength digits.
+const (
+	maxInternationalLength = 15
+	minInternationalLength = 7
+)
+
+// ValidatePhoneCountry returns an error if phone numbers can't be parsed with
+// country as the default country. An empty country is valid: every number
+// must then start with its calling code.
+func ValidatePhoneCountry(country string) error {
+	if _, ok := phoneCountries[country]; country != "" && !ok {
+		return fmt.Errorf("unsupported phone country %q", country)
+	}
+	return nil
+}
+
+// ParsePhone validates a phone number and returns it in E.164 format, e.g.
+// +40722123456. Numbers starting with + or 00 are international, the other
+// ones are national numbers of defaultCountry, an ISO 3166 code. Spaces,
+// dashes, dots, slashes and brackets are ignored. The returned error is a
+// *PhoneError.
+func ParsePhone(phone string, defaultCountry string) (string, error) {
+	trimmed := strings.TrimSpace(phone)
+	if trimmed == "" {
+		return "", &PhoneError{Phone: phone, Reason: "is empty"}
+	}
+	for i, r := range trimmed {
+		switch {
+		case unicode.IsDigit(r), strings.ContainsRune(" -./()", r), r == '+' && i == 0:
+		case unicode.IsLetter(r):
+			return "", &PhoneError{Phone: phone, Reason: "contains letters, it isn't a phone number"}
+		default:
+			return "", &PhoneError{Phone: phone, Reason: fmt.Sprintf("contains the invalid character %q", r)}
+		}
+	}
+
+	digits := NormalizePhone(trimmed)
+	if international, ok := strings.CutPrefix(digits, "+"); ok {
+		return parseInternational(phone, international)
+	}
+
+	country, ok := phoneCountries[defaultCountry]
+	if !ok {
+		return "", &PhoneError{Phone: phone, Reason: "has no country calling code, it must start with + and the calling code"}
+	}
+	// National significant numbers never start with the trunk prefix
+	if country.trunkPrefix != "" {
+		digits = strings.TrimPrefix(digits, country.trunkPrefix)
+	}
+	if err := checkLength(phone, len(digits), country.minLength, country.maxLength); err != nil {
+		return "", err
+	}
+
+	return "+" + country.callingCode + digits, nil
+}
+
+// parseInternational validates an international number given without its +.
+func parseInternational(phone string, digits string) (string, error) {
+	// Calling codes are prefix free, so at most one of them matches
+	for length := 1; length <= 3 && length < len(digits); length++ {
+		for _, country := range phoneCountries {
+			if country.callingCode != digits[:length] {
+				continue
+			}
+
+			national := digits[length:]
+			if err := checkLength(phone, len(national), country.minLength, country.maxLength); err != nil {
+				return "", err
+			}
+			return "+" + digits, nil
+		}
+	}
+
+	if err := checkLength(phone, len(digits), minInternationalLength, maxInternationalLength); err != nil {
+		return "", err
+	}
+	return "+" + digits, nil
+}
+
+func checkLength(phone string, length int, minLength int, maxLength int) error {
+	if length < minLength {
+		return &PhoneError{Phone: phone, Reason: "looks incomplete, it has too few digits"}
+	}
+	if length > maxLength {
+		return &PhoneError{Phone: phone, Reason: "has too many digits"}
+	}
+	return nil
+}
+
+// SamePhone reports whether a and b are the same phone number, national
+// numbers being of defaultCountry. Numbers that can't be parsed are compared
+// with NormalizePhone.
+func SamePhone(a string, b string, defaultCountry string) bool {
+	return comparablePhone(a, defaultCountry) == comparablePhone(b, defaultCountry)
+}
+
+func comparablePhone(phone string, defaultCountry string) string {
+	if parsed, err := ParsePhone(phone, defaultCountry); err == nil {
+		return parsed
+	}
+	return NormalizePhone(phone)
+}
@@ -0,0 +1,216 @@
+package repositorytest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+// racers is how many callers try to take the same slot at once.
+const racers = 50
+
+// TestBookingRepository runs the tests of the BookingRepository on the
+// repositories created by newRepositories.
+func TestBookingRepository(t *testing.T, newRepositories NewRepositories) {
+	t.Run("ConcurrentBookingsOfTheSameSlot", func(t *testing.T) {
+		testConcurrentBookingsOfTheSameSlot(t, newRepositories)
+	})
+	t.Run("ConcurrentConfirmHold", func(t *testing.T) {
+		testConcurrentConfirmHold(t, newRepositories)
+	})
+	t.Run("ReserveBookingInTheBranchTimezone", func(t *testing.T) {
+		testReserveBookingInTheBranchTimezone(t, newRepositories)
+	})
+}
+
+// newRaceBookings returns the bookings of a business where Alice does a 30
+// minute Cleaning, followed by a 15 minute buffer.
+func newRaceBookings(t *testing.T, newRepositories NewRepositories) repository.BookingRepository {
+	return newRepositories(t, newCatalog(nil, map[uint]*repository.Service{
+		1: {ID: 1, Name: "Cleaning", Duration: 30, BufferAfter: 15},
+	}, map[uint]*repository.Employee{
+		1: {ID: 1, Name: "Alice", ServicesIds: []uint{1}, Schedule: allWeek("09:00", "17:00")},
+	}), time.UTC).Bookings
+}
+
+// raceSlot is the start time of the i-th racer: 10:00, 10:15 or 10:30
+// tomorrow, all overlapping with each other once the buffer is added.
+func raceSlot(i int) time.Time {
+	tomorrow := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	return tomorrow.Add(10*time.Hour + time.Duration(i%3)*15*time.Minute)
+}
+
+// race calls try for every racer at the same time and checks that exactly one
+// succeeds while the others are told the slot is taken.
+func race(t *testing.T, try func(i int) error) {
+	t.Helper()
+
+	start := make(chan struct{})
+	errs := make([]error, racers)
+	var wg sync.WaitGroup
+	for i := range racers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = try(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, repository.ErrSlotNotAvailable):
+			t.Errorf("racer %d: got %v, want ErrSlotNotAvailable", i, err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d racers took the slot, want exactly 1", succeeded)
+	}
+}
+
+// checkNoOverlaps checks that the bookings and holds of the employee on the
+// race day don't overlap, and that there is one of them in total.
+func checkNoOverlaps(t *testing.T, bookings repository.BookingRepository) {
+	t.Helper()
+
+	day := raceSlot(0).Truncate(24 * time.Hour)
+	stored, err := bookings.GetBookingsByDateAndEmployee(day.Format("2006-01-02"), 1)
+	if err != nil {
+		t.Fatalf("GetBookingsByDateAndEmployee: %v", err)
+	}
+	holds, err := bookings.GetOverlappingHolds(1, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetOverlappingHolds: %v", err)
+	}
+
+	var blocked [][2]time.Time
+	for _, booking := range stored {
+		if booking.Status.Occupies() {
+			start, end := booking.BlockedInterval()
+			blocked = append(blocked, [2]time.Time{start, end})
+		}
+	}
+	for _, hold := range holds {
+		start, end := hold.Booking.BlockedInterval()
+		blocked = append(blocked, [2]time.Time{start, end})
+	}
+
+	if len(blocked) != 1 {
+		t.Errorf("%d bookings and holds stored, want 1", len(blocked))
+	}
+	for i := range blocked {
+		for j := i + 1; j < len(blocked); j++ {
+			if blocked[i][0].Before(blocked[j][1]) && blocked[j][0].Before(blocked[i][1]) {
+				t.Errorf("%v-%v overlaps %v-%v", blocked[i][0], blocked[i][1], blocked[j][0], blocked[j][1])
+			}
+		}
+	}
+}
+
+func reserve(bookings repository.BookingRepository, i int) error {
+	return bookings.ReserveBooking(&repository.Booking{
+		EmployeeID:      1,
+		ServiceID:       1,
+		BookingDateTime: raceSlot(i),
+		CustomerName:    fmt.Sprintf("Customer %d", i),
+	})
+}
+
+func hold(bookings repository.BookingRepository, i int) error {
+	return bookings.HoldSlot(&repository.Hold{
+		SessionID: fmt.Sprintf("session-%d", i),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Booking:   repository.Booking{EmployeeID: 1, ServiceID: 1, BookingDateTime: raceSlot(i)},
+	})
+}
+
+func testConcurrentBookingsOfTheSameSlot(t *testing.T, newRepositories NewRepositories) {
+	tests := map[string]func(bookings repository.BookingRepository, i int) error{
+		"ReserveBooking": reserve,
+		"HoldSlot":       hold,
+		"ReserveBooking and HoldSlot": func(bookings repository.BookingRepository, i int) error {
+			if i%2 == 0 {
+				return reserve(bookings, i)
+			}
+			return hold(bookings, i)
+		},
+	}
+
+	for name, try := range tests {
+		t.Run(name, func(t *testing.T) {
+			bookings := newRaceBookings(t, newRepositories)
+			race(t, func(i int) error { return try(bookings, i) })
+			checkNoOverlaps(t, bookings)
+		})
+	}
+}
+
+func testConcurrentConfirmHold(t *testing.T, newRepositories NewRepositories) {
+	bookings := newRaceBookings(t, newRepositories)
+
+	// The holds of a session don't conflict with each other, only one of them
+	// can be confirmed
+	holds := make([]*repository.Hold, racers)
+	for i := range holds {
+		holds[i] = &repository.Hold{
+			SessionID: "session",
+			ExpiresAt: time.Now().Add(time.Hour),
+			Booking:   repository.Booking{EmployeeID: 1, ServiceID: 1, BookingDateTime: raceSlot(i)},
+		}
+		if err := bookings.HoldSlot(holds[i]); err != nil {
+			t.Fatalf("HoldSlot(%d): %v", i, err)
+		}
+	}
+
+	race(t, func(i int) error {
+		_, err := bookings.ConfirmHold(holds[i].ID, fmt.Sprintf("Customer %d", i), "")
+		return err
+	})
+	if err := bookings.ReleaseSessionHolds("session"); err != nil {
+		t.Fatalf("ReleaseSessionHolds: %v", err)
+	}
+	checkNoOverlaps(t, bookings)
+}
+
+func testReserveBookingInTheBranchTimezone(t *testing.T, newRepositories NewRepositories) {
+	bookings := newRepositories(t, newCatalog(map[uint]*repository.Location{
+		1: {ID: 1, Name: "Old Town", Timezone: "Europe/Bucharest", OpeningHours: allWeek("09:00", "17:00")},
+	}, map[uint]*repository.Service{
+		1: {ID: 1, Name: "Cleaning", Duration: 30},
+	}, map[uint]*repository.Employee{
+		1: {ID: 1, Name: "Alice", ServicesIds: []uint{1}, Schedule: allWeek("09:00", "17:00")},
+	}), time.UTC).Bookings
+
+	// Bucharest is 2 or 3 hours ahead of UTC: 07:30 UTC is within the hours
+	// of the branch, 15:30 UTC after them
+	tomorrow := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	tests := []struct {
+		start time.Time
+		open  bool
+	}{
+		{tomorrow.Add(7*time.Hour + 30*time.Minute), true},
+		{tomorrow.Add(15*time.Hour + 30*time.Minute), false},
+	}
+
+	for _, test := range tests {
+		err := bookings.ReserveBooking(&repository.Booking{
+			EmployeeID:      1,
+			ServiceID:       1,
+			LocationID:      1,
+			BookingDateTime: test.start,
+			CustomerName:    "Jane",
+		})
+		if open := err == nil; open != test.open {
+			t.Errorf("ReserveBooking(%v) = %v, want open %v", test.start, err, test.open)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package repositorytest
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+// TestEmployeeRepository runs the tests of the EmployeeRepository on the
+// repositories created by newRepositories.
+func TestEmployeeRepository(t *testing.T, newRepositories NewRepositories) {
+	t.Run("FindAvailableSlotsAroundDST", func(t *testing.T) {
+		testFindAvailableSlotsAroundDST(t, newRepositories)
+	})
+}
+
+// dstYear is when the daylight saving time changes are tested. It is fixed so
+// the tests don't depend on the current date, and in the future since past
+// times can't be booked.
+const dstYear = 2036
+
+// dstDays returns the days the clocks move forward and back in the European
+// Union in dstYear, the last Sundays of March and October. Both changes
+// happen at 01:00 UTC.
+func dstDays() (time.Time, time.Time) {
+	lastSunday := func(month time.Month) time.Time {
+		day := time.Date(dstYear, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	}
+	return lastSunday(time.March), lastSunday(time.October)
+}
+
+func testFindAvailableSlotsAroundDST(t *testing.T, newRepositories NewRepositories) {
+	timezone, err := time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		t.Fatalf("loading the time zone: %v", err)
+	}
+	springForward, fallBack := dstDays()
+
+	repositories := newRepositories(t, newCatalog(nil, map[uint]*repository.Service{
+		1: {ID: 1, Name: "Cleaning", Duration: 60},
+	}, map[uint]*repository.Employee{
+		1: {ID: 1, Name: "Alice", ServicesIds: []uint{1}, Schedule: repository.WeeklySchedule{
+			time.Sunday: {{Start: "02:00", End: "06:00"}},
+		}},
+	}), timezone)
+
+	// On the second day the appointment in the first 03:30 doesn't take the
+	// second one
+	booking := &repository.Booking{EmployeeID: 1, ServiceID: 1, BookingDateTime: fallBack.Add(30 * time.Minute), CustomerName: "Jane"}
+	if err := repositories.Bookings.ReserveBooking(booking); err != nil {
+		t.Fatalf("booking 03:30 EEST: %v", err)
+	}
+
+	// The slots are offsets from midnight UTC of the day, every 15 minutes of
+	// the working hours from 02:00 to 06:00
+	every := func(first time.Duration, last time.Duration) []time.Duration {
+		var offsets []time.Duration
+		for offset := first; offset <= last; offset += 15 * time.Minute {
+			offsets = append(offsets, offset)
+		}
+		return offsets
+	}
+	tests := []struct {
+		day  time.Time
+		want []time.Duration
+	}{
+		// 02:00 to 02:45 EET, 04:00 to 05:00 EEST
+		{springForward, every(0, 2*time.Hour)},
+		// 02:00 to 02:30 EEST, 03:30 to 05:00 EET
+		{fallBack, append(every(-time.Hour, -30*time.Minute), every(90*time.Minute, 3*time.Hour)...)},
+	}
+
+	for _, test := range tests {
+		from := repository.StartOfDay(test.day, timezone)
+		slots, err := repositories.Employees.FindAvailableSlots(1, 0, from, from.AddDate(0, 0, 1), 0, 0)
+		if err != nil {
+			t.Fatalf("FindAvailableSlots(%s): %v", from.Format("2006-01-02"), err)
+		}
+
+		var got []time.Time
+		for _, slot := range slots {
+			got = append(got, slot.Start)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("FindAvailableSlots(%s) = %v, want %d slots", from.Format("2006-01-02"), got, len(test.want))
+			continue
+		}
+		for i, offset := range test.want {
+			if want := test.day.Add(offset); !got[i].Equal(want) {
+				t.Errorf("FindAvailableSlots(%s) slot %d = %v, want %v", from.Format("2006-01-02"), i, got[i], want.In(timezone))
+			}
+		}
+	}
+}
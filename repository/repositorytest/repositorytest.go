@@ -0,0 +1,37 @@
+// Package repositorytest checks that the storage backends behave the same:
+// each backend runs the tests of this package from its own tests, with a
+// function creating its repositories.
+package repositorytest
+
+import (
+	"testing"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+// NewRepositories creates the repositories of a business from the catalog, in
+// the time zone, with a calendar without closures and 15 minute slots.
+type NewRepositories func(t *testing.T, catalog *repository.Catalog, timezone *time.Location) *repository.Repositories
+
+func allWeek(start string, end string) repository.WeeklySchedule {
+	schedule := repository.WeeklySchedule{}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		schedule[weekday] = []repository.WorkingHours{{Start: start, End: end}}
+	}
+	return schedule
+}
+
+// newCatalog returns a catalog with the services and employees, and the
+// branches if any.
+func newCatalog(locations map[uint]*repository.Location, services map[uint]*repository.Service, employees map[uint]*repository.Employee) *repository.Catalog {
+	if locations == nil {
+		locations = map[uint]*repository.Location{}
+	}
+	return &repository.Catalog{
+		Locations: locations,
+		Resources: map[uint]*repository.Resource{},
+		Services:  services,
+		Employees: employees,
+	}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"slices"
+	"time"
+)
+
+// Resource is equipment or a room needed by some services, e.g. a surgery
+// room or an X-Ray machine. Capacity is how many bookings can use it at the
+// same time.
+type Resource struct {
+	ID       uint
+	Name     string
+	Capacity uint
+	// Retired resources were removed from the catalog, see Catalog.
+	Retired bool
+}
+
+type ResourceRepository interface {
+	GetResources() ([]*Resource, error)
+	GetResourceById(id uint) (*Resource, error)
+}
+
+// PeakUsage returns the highest number of bookings using a resource at the
+// same time during [start, end), according to their blocked intervals.
+func PeakUsage(bookings []*Booking, start time.Time, end time.Time) int {
+	type event struct {
+		at    time.Time
+		delta int
+	}
+
+	var events []event
+	for _, booking := range bookings {
+		blockedStart, blockedEnd := booking.BlockedInterval()
+		if !blockedStart.Before(end) || !start.Before(blockedEnd) {
+			continue
+		}
+		events = append(events, event{at: maxTime(blockedStart, start), delta: 1}, event{at: minTime(blockedEnd, end), delta: -1})
+	}
+
+	// At the same instant a booking ending frees the resource for one starting
+	slices.SortFunc(events, func(a, b event) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return a.delta - b.delta
+	})
+
+	usage, peak := 0, 0
+	for _, e := range events {
+		usage += e.delta
+		peak = max(peak, usage)
+	}
+
+	return peak
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WorkingHours is an interval of a working day, with Start and End given as
+// HH:MM. End must be after Start, shifts can't span midnight.
+type WorkingHours struct {
+	Start string
+	End   string
+}
+
+// WeeklySchedule holds the working intervals of each weekday. Split shifts and
+// breaks are expressed as several intervals on the same day, e.g. 09:00-13:00
+// and 14:00-18:00 for a lunch break. A weekday without intervals is a day off.
+type WeeklySchedule map[time.Weekday][]WorkingHours
+
+// parseTimeOfDay returns the number of minutes since midnight for an HH:MM
+// string.
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Minutes returns the interval as minutes since midnight.
+func (h WorkingHours) Minutes() (int, int, error) {
+	start, err := parseTimeOfDay(h.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseTimeOfDay(h.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("working hours %s-%s end before they start", h.Start, h.End)
+	}
+	return start, end, nil
+}
+
+// Validate checks that all the intervals are well formed and that the
+// intervals of a day don't overlap.
+func (s WeeklySchedule) Validate() error {
+	for weekday, intervals := range s {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			return fmt.Errorf("invalid weekday %d", weekday)
+		}
+
+		type span struct{ start, end int }
+		spans := make([]span, 0, len(intervals))
+		for _, interval := range intervals {
+			start, end, err := interval.Minutes()
+			if err != nil {
+				return fmt.Errorf("%s: %w", weekday, err)
+			}
+			spans = append(spans, span{start, end})
+		}
+
+		slices.SortFunc(spans, func(a, b span) int { return a.start - b.start })
+		for i := 1; i < len(spans); i++ {
+			if spans[i].start < spans[i-1].end {
+				return fmt.Errorf("%s: overlapping working hours", weekday)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Covers reports whether the interval [start, end) falls entirely inside one
+// of the working intervals. A nil schedule doesn't restrict working hours.
+func (s WeeklySchedule) Covers(start time.Time, end time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := startMinute + wallMinutes(start, end.In(start.Location()))
+
+	for _, interval := range s[start.Weekday()] {
+		intervalStart, intervalEnd, err := interval.Minutes()
+		if err != nil {
+			continue
+		}
+		if intervalStart <= startMinute && endMinute <= intervalEnd {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wallMinutes returns the minutes between start and end as shown on a wall
+// clock, which differ from the elapsed time across a daylight saving time
+// change.
+func wallMinutes(start time.Time, end time.Time) int {
+	sy, sm, sd := start.Date()
+	ey, em, ed := end.Date()
+	days := int(time.Date(ey, em, ed, 0, 0, 0, 0, time.UTC).Sub(time.Date(sy, sm, sd, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+	return days*24*60 + end.Hour()*60 + end.Minute() - start.Hour()*60 - start.Minute()
+}
+
+// MarshalJSON encodes the schedule keyed by weekday names instead of numbers.
+func (s WeeklySchedule) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+
+	named := make(map[string][]WorkingHours, len(s))
+	for weekday, intervals := range s {
+		named[weekday.String()] = intervals
+	}
+	return json.Marshal(named)
+}
+
+func (s *WeeklySchedule) UnmarshalJSON(data []byte) error {
+	var named map[string][]WorkingHours
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	if named == nil {
+		*s = nil
+		return nil
+	}
+
+	schedule := make(WeeklySchedule, len(named))
+	for name, intervals := range named {
+		weekday, err := ParseWeekday(name)
+		if err != nil {
+			return err
+		}
+		schedule[weekday] = intervals
+	}
+	*s = schedule
+	return nil
+}
+
+// ParseWeekday parses an English weekday name, e.g. "Monday".
+func ParseWeekday(name string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), name) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
+
+// FirstSlot returns the first start time at or after from, and not in the
+// past, whose wall clock time in from's location is a multiple of granularity
+// since midnight. Wall clock times keep the slots aligned on days with a
+// daylight saving time change.
+func FirstSlot(from time.Time, granularity time.Duration) time.Time {
+	if now := time.Now(); from.Before(now) {
+		from = now.In(from.Location())
+	}
+
+	sinceMidnight := timeOfDay(from)
+	aligned := (sinceMidnight + granularity - 1) / granularity * granularity
+
+	// Waiting until the next aligned wall clock time keeps the times repeated
+	// when the clocks move back, unless a change leaves the wall clock
+	// unaligned
+	if slot := from.Add(aligned - sinceMidnight); timeOfDay(slot)%granularity == 0 {
+		return slot
+	}
+
+	y, m, d := from.Date()
+	slot := firstOccurrence(time.Date(y, m, d, 0, 0, int(aligned/time.Second), 0, from.Location()))
+	for slot.Before(from) {
+		slot = slot.Add(granularity)
+	}
+	return slot
+}
+
+// timeOfDay returns the wall clock time of t since midnight.
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFirstSlotAroundDST(t *testing.T) {
+	timezone := bucharest(t)
+	springForward, fallBack := dstDays()
+
+	// from and want are offsets from midnight UTC of the day
+	tests := []struct {
+		day  time.Time
+		from time.Duration
+		want time.Duration
+	}{
+		{springForward, 20 * time.Minute, 30 * time.Minute}, // 02:20 EET, 02:30 EET
+		{springForward, 50 * time.Minute, time.Hour},        // 02:50 EET, 04:00 EEST
+		{springForward, time.Hour, time.Hour},               // 04:00 EEST
+		{fallBack, 10 * time.Minute, 30 * time.Minute},      // 03:10 EEST, 03:30 EEST
+		{fallBack, 40 * time.Minute, time.Hour},             // 03:40 EEST, 03:00 EET
+		{fallBack, 70 * time.Minute, 90 * time.Minute},      // 03:10 EET, 03:30 EET
+		{fallBack, 100 * time.Minute, 2 * time.Hour},        // 03:40 EET, 04:00 EET
+	}
+
+	for _, test := range tests {
+		from := test.day.Add(test.from).In(timezone)
+		want := test.day.Add(test.want)
+		if got := FirstSlot(from, 30*time.Minute); !got.Equal(want) {
+			t.Errorf("FirstSlot(%v) = %v, want %v", from, got, want.In(timezone))
+		}
+	}
+}
+
+func TestCoversAroundDST(t *testing.T) {
+	timezone := bucharest(t)
+	springForward, fallBack := dstDays()
+	schedule := WeeklySchedule{time.Sunday: {{Start: "02:00", End: "06:00"}}}
+
+	// start is an offset from midnight UTC of the day
+	tests := []struct {
+		day      time.Time
+		start    time.Duration
+		duration time.Duration
+		want     bool
+	}{
+		{springForward, 0, 3 * time.Hour, true},              // 02:00 EET to 06:00 EEST
+		{springForward, 30 * time.Minute, time.Hour, true},   // 02:30 EET to 04:30 EEST
+		{springForward, time.Hour, 2 * time.Hour, true},      // 04:00 to 06:00 EEST
+		{springForward, time.Hour, 150 * time.Minute, false}, // 04:00 to 06:30 EEST
+		{springForward, -30 * time.Minute, time.Hour, false}, // 01:30 to 02:30 EET
+		{fallBack, -time.Hour, 5 * time.Hour, true},          // 02:00 EEST to 06:00 EET
+		{fallBack, 30 * time.Minute, time.Hour, true},        // 03:30 EEST to 03:30 EET
+		{fallBack, 90 * time.Minute, 2 * time.Hour, true},    // 03:30 to 05:30 EET
+		{fallBack, 3 * time.Hour, time.Hour, true},           // 05:00 to 06:00 EET
+		{fallBack, 3 * time.Hour, 90 * time.Minute, false},   // 05:00 to 06:30 EET
+		{fallBack, -time.Hour, 330 * time.Minute, false},     // 02:00 EEST to 06:30 EET
+	}
+
+	for _, test := range tests {
+		start := test.day.Add(test.start).In(timezone)
+		end := start.Add(test.duration)
+		if got := schedule.Covers(start, end); got != test.want {
+			t.Errorf("Covers(%v, %v) = %v, want %v", start, end.In(timezone), got, test.want)
+		}
+	}
+}
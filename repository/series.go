@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxOccurrences limits the number of bookings of a series.
+const MaxOccurrences = 52
+
+var ErrSeriesNotFound = errors.New("series not found")
+
+type RecurrenceUnit string
+
+const (
+	RecurrenceWeeks  RecurrenceUnit = "weeks"
+	RecurrenceMonths RecurrenceUnit = "months"
+)
+
+// Recurrence repeats an appointment every Interval weeks or months, either
+// Count times or until the Until date.
+type Recurrence struct {
+	Interval uint
+	Unit     RecurrenceUnit
+	// Count is the number of occurrences, the first one included. When 0 the
+	// series ends on the day of Until, inclusive.
+	Count uint
+	Until time.Time
+}
+
+// Validate checks that the recurrence is well formed. The number of
+// occurrences is checked by Occurrences.
+func (r *Recurrence) Validate() error {
+	if r.Interval == 0 {
+		return errors.New("recurrence interval must be positive")
+	}
+	if r.Unit != RecurrenceWeeks && r.Unit != RecurrenceMonths {
+		return fmt.Errorf("invalid recurrence unit %q, expected %s or %s", r.Unit, RecurrenceWeeks, RecurrenceMonths)
+	}
+	if r.Count == 0 && r.Until.IsZero() {
+		return errors.New("recurrence needs a count or an end date")
+	}
+	return nil
+}
+
+// Occurrences returns the start times of a series whose first appointment is
+// at start, in chronological order. Monthly appointments on a day missing
+// from a month, e.g. the 31st, move to the last day of that month.
+func (r *Recurrence) Occurrences(start time.Time) ([]time.Time, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	var occurrences []time.Time
+	for i := 0; r.Count == 0 || uint(i) < r.Count; i++ {
+		occurrence := r.nth(start, i)
+		if r.Count == 0 && !occurrence.Before(r.Until.AddDate(0, 0, 1)) {
+			break
+		}
+		if len(occurrences) == MaxOccurrences {
+			return nil, fmt.Errorf("a series can have at most %d appointments", MaxOccurrences)
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+
+	return occurrences, nil
+}
+
+// nth returns the start of the i-th occurrence, counting from 0.
+func (r *Recurrence) nth(start time.Time, i int) time.Time {
+	steps := i * int(r.Interval)
+	if r.Unit == RecurrenceWeeks {
+		return start.AddDate(0, 0, 7*steps)
+	}
+
+	y, m, d := start.Date()
+	firstOfMonth := time.Date(y, m+time.Month(steps), 1, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return firstOfMonth.AddDate(0, 0, min(d, lastDay)-1)
+}
+
+// Series is a group of recurring bookings, created and cancelled as a unit.
+type Series struct {
+	ID         uint
+	Recurrence Recurrence
+	// Bookings of the series in chronological order, past ones included
+	BookingsIds []uint
+}
+
+// OccurrenceConflict is an occurrence of a series that can't be booked.
+type OccurrenceConflict struct {
+	Start time.Time
+	Err   error
+}
+
+// SeriesConflictError is returned when some occurrences of a series can't be
+// booked, in which case none of them is. It matches ErrSlotNotAvailable.
+type SeriesConflictError struct {
+	Conflicts []*OccurrenceConflict
+}
+
+func (e *SeriesConflictError) Error() string {
+	reasons := make([]string, 0, len(e.Conflicts))
+	for _, conflict := range e.Conflicts {
+		reasons = append(reasons, conflict.Start.Format("2006-01-02 15:04")+": "+conflict.Err.Error())
+	}
+	return fmt.Sprintf("%d occurrences of the series can't be booked: %s", len(e.Conflicts), strings.Join(reasons, "; "))
+}
+
+func (e *SeriesConflictError) Is(target error) bool {
+	return target == ErrSlotNotAvailable
+}
+
+// IsOccurrenceConflict reports whether err only concerns one occurrence of a
+// series, as opposed to the whole series.
+func IsOccurrenceConflict(err error) bool {
+	return errors.Is(err, ErrSlotNotAvailable) || errors.Is(err, ErrBusinessClosed)
+}
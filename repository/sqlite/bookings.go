@@ -0,0 +1,534 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type bookingsSqliteRepository struct {
+	db                 *sql.DB
+	calendarRepository repository.CalendarRepository
+	locationRepository repository.LocationRepository
+	// timezone is the business time zone, the times read from the database
+	// are converted to it
+	timezone *time.Location
+}
+
+func NewBookingsSqliteRepository(db *sql.DB, calendarRepository repository.CalendarRepository, locationRepository repository.LocationRepository, timezone *time.Location) repository.BookingRepository {
+	return &bookingsSqliteRepository{
+		db:                 db,
+		calendarRepository: calendarRepository,
+		locationRepository: locationRepository,
+		timezone:           timezone,
+	}
+}
+
+// serviceSnapshot copies the duration, buffers, resources and deposit of the
+// booked service.
+func (r *bookingsSqliteRepository) serviceSnapshot(booking *repository.Booking) error {
+	err := r.db.QueryRow(`SELECT duration, buffer_before, buffer_after, deposit FROM services WHERE id = ?`, booking.ServiceID).
+		Scan(&booking.Duration, &booking.BufferBefore, &booking.BufferAfter, &booking.Deposit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("service not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	booking.ResourcesIds, err = queryIds(r.db, `SELECT resource_id FROM service_resources WHERE service_id = ? ORDER BY resource_id`,
+		booking.ServiceID)
+	return err
+}
+
+// intervalCondition matches the bookings whose blocked interval, buffers
+// included, overlaps another interval. It takes the end and the start of the
+// interval as parameters.
+const intervalCondition = `starts_at - buffer_before * 60 < ? AND starts_at + (duration + buffer_after) * 60 > ?`
+
+// overlapsCondition is intervalCondition restricted to the bookings of an
+// employee. It takes the employee id, the end and the start of the interval as
+// parameters.
+const overlapsCondition = `employee_id = ? AND ` + intervalCondition
+
+// occupiesCondition matches the bookings whose status occupies their slot, it
+// must list the same statuses as repository.OccupyingStatuses. Holds have no
+// status, they always occupy their slot.
+const occupiesCondition = `status IN ('pending', 'confirmed', 'checked-in', 'completed')`
+
+// changeableCondition matches the bookings that can still be rescheduled or
+// cancelled.
+const changeableCondition = `status IN ('pending', 'confirmed')`
+
+const bookingColumns = `id, employee_id, service_id, location_id, starts_at, customer_name, attendee_name, customer_phone, customer_id, duration, buffer_before, buffer_after, series_id,
+	bundle_id, group_id, deposit, payment_id, payment_link, payment_due_at, paid_at,
+	status, created_at, confirmed_at, checked_in_at, completed_at, cancelled_at, no_show_at`
+
+// scanBooking reads a booking selected with bookingColumns, with its times in
+// timezone.
+func scanBooking(row interface{ Scan(...any) error }, timezone *time.Location) (*repository.Booking, error) {
+	var booking repository.Booking
+	var startsAt, paymentDueAt, paidAt, createdAt, confirmedAt, checkedInAt, completedAt, cancelledAt, noShowAt int64
+	err := row.Scan(&booking.ID, &booking.EmployeeID, &booking.ServiceID, &booking.LocationID, &startsAt, &booking.CustomerName, &booking.AttendeeName, &booking.CustomerPhone,
+		&booking.CustomerID, &booking.Duration, &booking.BufferBefore, &booking.BufferAfter, &booking.SeriesID,
+		&booking.BundleID, &booking.GroupID, &booking.Deposit, &booking.PaymentID, &booking.PaymentLink, &paymentDueAt, &paidAt,
+		&booking.Status, &createdAt, &confirmedAt, &checkedInAt, &completedAt, &cancelledAt, &noShowAt)
+	if err != nil {
+		return nil, err
+	}
+	booking.BookingDateTime = time.Unix(startsAt, 0).In(timezone)
+	booking.PaymentDueAt = timeOrZero(paymentDueAt, timezone)
+	booking.PaidAt = timeOrZero(paidAt, timezone)
+	booking.CreatedAt = timeOrZero(createdAt, timezone)
+	booking.ConfirmedAt = timeOrZero(confirmedAt, timezone)
+	booking.CheckedInAt = timeOrZero(checkedInAt, timezone)
+	booking.CompletedAt = timeOrZero(completedAt, timezone)
+	booking.CancelledAt = timeOrZero(cancelledAt, timezone)
+	booking.NoShowAt = timeOrZero(noShowAt, timezone)
+	return &booking, nil
+}
+
+func (r *bookingsSqliteRepository) GetBookingsByDateAndEmployee(date string, employeeId uint) ([]*repository.Booking, error) {
+	dayStart, err := time.ParseInLocation("2006-01-02", date, r.timezone)
+	if err != nil {
+		return nil, err
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	return queryBookings(r.db, r.timezone, `SELECT `+bookingColumns+` FROM bookings
+		WHERE employee_id = ? AND starts_at >= ? AND starts_at < ? ORDER BY starts_at`,
+		employeeId, dayStart.Unix(), dayEnd.Unix())
+}
+
+func queryBookings(db *sql.DB, timezone *time.Location, query string, args ...any) ([]*repository.Booking, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var bookings []*repository.Booking
+	for rows.Next() {
+		booking, err := scanBooking(rows, timezone)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The resources are loaded after the booking rows are closed, the database
+	// only has a single connection.
+	for _, booking := range bookings {
+		if err := loadBookingResources(db, booking); err != nil {
+			return nil, err
+		}
+	}
+
+	return bookings, nil
+}
+
+func loadBookingResources(q queryer, booking *repository.Booking) error {
+	var err error
+	booking.ResourcesIds, err = queryIds(q, `SELECT resource_id FROM booking_resources WHERE booking_id = ? ORDER BY resource_id`, booking.ID)
+	return err
+}
+
+func (r *bookingsSqliteRepository) GetBookingById(id uint) (*repository.Booking, error) {
+	booking, err := scanBooking(r.db.QueryRow(`SELECT `+bookingColumns+` FROM bookings WHERE id = ?`, id), r.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return booking, loadBookingResources(r.db, booking)
+}
+
+func (r *bookingsSqliteRepository) GetOverlappingBookings(employeeId uint, start time.Time, end time.Time) ([]*repository.Booking, error) {
+	return queryBookings(r.db, r.timezone, `SELECT `+bookingColumns+` FROM bookings WHERE `+occupiesCondition+` AND `+overlapsCondition+` ORDER BY starts_at`,
+		employeeId, end.Unix(), start.Unix())
+}
+
+func (r *bookingsSqliteRepository) GetResourceUsage(resourceId uint, start time.Time, end time.Time) (int, error) {
+	users, err := resourceUsers(r.db, resourceId, start, end, 0, "")
+	if err != nil {
+		return 0, err
+	}
+
+	return repository.PeakUsage(users, start, end), nil
+}
+
+// resourceUsers returns the bookings, other than excludeId, and the active
+// holds, other than the ones of sessionId, using the resource during [start,
+// end). Only the bookings whose status occupies their slot are counted, and
+// only the fields needed to compute the blocked intervals are loaded.
+func resourceUsers(q queryer, resourceId uint, start time.Time, end time.Time, excludeId uint, sessionId string) ([]*repository.Booking, error) {
+	rows, err := q.Query(`SELECT starts_at, duration, buffer_before, buffer_after FROM bookings
+			JOIN booking_resources ON booking_resources.booking_id = bookings.id
+			WHERE resource_id = ? AND id != ? AND `+occupiesCondition+` AND `+intervalCondition+`
+		UNION ALL
+		SELECT starts_at, duration, buffer_before, buffer_after FROM holds
+			JOIN hold_resources ON hold_resources.hold_id = holds.id
+			WHERE resource_id = ? AND (? = '' OR session_id != ?) AND expires_at > ? AND `+intervalCondition,
+		resourceId, excludeId, end.Unix(), start.Unix(),
+		resourceId, sessionId, sessionId, time.Now().Unix(), end.Unix(), start.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*repository.Booking
+	for rows.Next() {
+		var user repository.Booking
+		var startsAt int64
+		if err := rows.Scan(&startsAt, &user.Duration, &user.BufferBefore, &user.BufferAfter); err != nil {
+			return nil, err
+		}
+		user.BookingDateTime = time.Unix(startsAt, 0).UTC()
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// checkResources returns a *SlotConflictError if one of the resources of the
+// booking is used up to its capacity during its blocked interval, not counting
+// the booking itself and the holds of sessionId.
+func checkResources(q queryer, booking *repository.Booking, sessionId string) error {
+	blockedStart, blockedEnd := booking.BlockedInterval()
+
+	for _, resourceId := range booking.ResourcesIds {
+		var capacity int
+		err := q.QueryRow(`SELECT capacity FROM resources WHERE id = ?`, resourceId).Scan(&capacity)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("resource not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		users, err := resourceUsers(q, resourceId, blockedStart, blockedEnd, booking.ID, sessionId)
+		if err != nil {
+			return err
+		}
+		if repository.PeakUsage(users, blockedStart, blockedEnd) >= capacity {
+			return &repository.SlotConflictError{
+				EmployeeID:            booking.EmployeeID,
+				Start:                 booking.BookingDateTime,
+				ConflictingResourceID: resourceId,
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *bookingsSqliteRepository) GetUpcomingBookingsByCustomer(phone string, name string) ([]*repository.Booking, error) {
+	return queryBookings(r.db, r.timezone, `SELECT `+bookingColumns+` FROM bookings
+		WHERE customer_phone = ? AND (? = '' OR customer_name = ? COLLATE NOCASE) AND starts_at >= ? AND `+occupiesCondition+`
+		ORDER BY starts_at`,
+		repository.NormalizePhone(phone), name, name, time.Now().Unix())
+}
+
+func (r *bookingsSqliteRepository) GetBookingsByCustomerId(customerId uint) ([]*repository.Booking, error) {
+	return queryBookings(r.db, r.timezone, `SELECT `+bookingColumns+` FROM bookings WHERE customer_id = ? ORDER BY starts_at, id`, customerId)
+}
+
+func (r *bookingsSqliteRepository) ReserveBooking(booking *repository.Booking) error {
+	if err := r.prepareBooking(booking); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkConflicts(tx, booking, "", r.timezone); err != nil {
+		return err
+	}
+
+	if err := insertBooking(tx, booking); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// prepareBooking validates a new booking, sets its initial status and fills in
+// the service snapshot. It must be called before the transaction is opened:
+// the calendar and the locations use their own connection to the database,
+// which is the single one held by the transaction.
+func (r *bookingsSqliteRepository) prepareBooking(booking *repository.Booking) error {
+	now := time.Now()
+	if booking.BookingDateTime.Before(now) {
+		return errors.New("booking time is in the past")
+	}
+	booking.BookingDateTime = booking.BookingDateTime.In(r.timezone)
+
+	if booking.Duration == 0 {
+		if err := r.serviceSnapshot(booking); err != nil {
+			return err
+		}
+	}
+	if err := booking.InitStatus(now); err != nil {
+		return err
+	}
+
+	return r.checkOpen(booking)
+}
+
+// checkOpen returns an error wrapping ErrBusinessClosed if the business or the
+// branch of the booking is closed during the appointment. Like prepareBooking,
+// it must be called before the transaction is opened.
+func (r *bookingsSqliteRepository) checkOpen(booking *repository.Booking) error {
+	timezone, err := repository.LocationTimezone(r.locationRepository, booking.LocationID, r.timezone)
+	if err != nil {
+		return err
+	}
+	start := booking.BookingDateTime.In(timezone)
+
+	if err := repository.CheckBusinessOpen(r.calendarRepository, start, booking.EndDateTime()); err != nil {
+		return err
+	}
+	return repository.CheckLocationOpen(r.locationRepository, booking.LocationID, start, booking.EndDateTime())
+}
+
+// reserveTogether atomically stores bookings that are made together, checked
+// as a whole with validate. itemFormat tells the position of a booking in the
+// errors. setId links each booking to the id of the first one, stored in
+// idColumn.
+func (r *bookingsSqliteRepository) reserveTogether(bookings []*repository.Booking, validate func([]*repository.Booking) error, itemFormat string, idColumn string, setId func(*repository.Booking, uint)) error {
+	for i, booking := range bookings {
+		if err := r.prepareBooking(booking); err != nil {
+			return fmt.Errorf(itemFormat+": %w", i+1, err)
+		}
+	}
+	if err := validate(bookings); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Each booking is inserted before the next one is checked, so they can't
+	// share an employee or a resource at the same time
+	for i, booking := range bookings {
+		if err := checkConflicts(tx, booking, "", r.timezone); err != nil {
+			return fmt.Errorf(itemFormat+": %w", i+1, err)
+		}
+		if i > 0 {
+			setId(booking, bookings[0].ID)
+		}
+		if err := insertBooking(tx, booking); err != nil {
+			return err
+		}
+	}
+
+	firstId := bookings[0].ID
+	if _, err := tx.Exec(`UPDATE bookings SET `+idColumn+` = ? WHERE id = ?`, firstId, firstId); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	setId(bookings[0], firstId)
+	return nil
+}
+
+// insertBooking links the booking to its customer, inserts it and sets its ID.
+func insertBooking(tx *sql.Tx, booking *repository.Booking) error {
+	if err := linkCustomer(tx, booking); err != nil {
+		return err
+	}
+
+	var id any
+	if booking.ID != 0 {
+		id = booking.ID
+	}
+
+	result, err := tx.Exec(`INSERT INTO bookings (`+bookingColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, booking.EmployeeID, booking.ServiceID, booking.LocationID, booking.BookingDateTime.Unix(), booking.CustomerName, booking.AttendeeName, booking.CustomerPhone,
+		booking.CustomerID, booking.Duration, booking.BufferBefore, booking.BufferAfter, booking.SeriesID,
+		booking.BundleID, booking.GroupID, booking.Deposit, booking.PaymentID, booking.PaymentLink, unixOrZero(booking.PaymentDueAt), unixOrZero(booking.PaidAt),
+		booking.Status, unixOrZero(booking.CreatedAt), unixOrZero(booking.ConfirmedAt), unixOrZero(booking.CheckedInAt),
+		unixOrZero(booking.CompletedAt), unixOrZero(booking.CancelledAt), unixOrZero(booking.NoShowAt))
+	if err != nil {
+		return err
+	}
+
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	booking.ID = uint(insertedId)
+
+	for _, resourceId := range booking.ResourcesIds {
+		_, err := tx.Exec(`INSERT INTO booking_resources (booking_id, resource_id) VALUES (?, ?)`, booking.ID, resourceId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkConflicts returns an error wrapping ErrEmployeeNotWorking if the
+// employee isn't working during the booking, or a *SlotConflictError if the
+// booking overlaps another booking of the same employee, or an active hold
+// placed by a different session than sessionId, or if one of its resources is
+// used up to its capacity. timezone is the business time zone.
+func checkConflicts(tx *sql.Tx, booking *repository.Booking, sessionId string, timezone *time.Location) error {
+	// Checked in the transaction, so no time off can be added before the
+	// booking is stored
+	if err := checkEmployee(tx, booking, timezone); err != nil {
+		return err
+	}
+
+	blockedStart, blockedEnd := booking.BlockedInterval()
+
+	var conflictingId uint
+	err := tx.QueryRow(`SELECT id FROM bookings WHERE id != ? AND `+occupiesCondition+` AND `+overlapsCondition+` LIMIT 1`,
+		booking.ID, booking.EmployeeID, blockedEnd.Unix(), blockedStart.Unix()).Scan(&conflictingId)
+	if err == nil {
+		return &repository.SlotConflictError{
+			EmployeeID:           booking.EmployeeID,
+			Start:                booking.BookingDateTime,
+			ConflictingBookingID: conflictingId,
+		}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = tx.QueryRow(`SELECT id FROM holds WHERE (? = '' OR session_id != ?) AND expires_at > ? AND `+overlapsCondition+` LIMIT 1`,
+		sessionId, sessionId, time.Now().Unix(), booking.EmployeeID, blockedEnd.Unix(), blockedStart.Unix()).Scan(&conflictingId)
+	if err == nil {
+		return &repository.SlotConflictError{
+			EmployeeID:        booking.EmployeeID,
+			Start:             booking.BookingDateTime,
+			ConflictingHoldID: conflictingId,
+		}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return checkResources(tx, booking, sessionId)
+}
+
+func (r *bookingsSqliteRepository) CancelBooking(id uint) error {
+	booking, err := r.GetBookingById(id)
+	if err != nil {
+		return err
+	}
+
+	if booking.BookingDateTime.Before(time.Now()) {
+		return errors.New("booking time is in the past")
+	}
+
+	return r.SetBookingStatus(id, repository.BookingCancelled)
+}
+
+func (r *bookingsSqliteRepository) SetBookingStatus(id uint, status repository.BookingStatus) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	booking, err := scanBooking(tx.QueryRow(`SELECT `+bookingColumns+` FROM bookings WHERE id = ?`, id), r.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrBookingNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := updateStatus(tx, booking, status, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateStatus moves the booking to status at the given time and stores the
+// change.
+func updateStatus(tx *sql.Tx, booking *repository.Booking, status repository.BookingStatus, at time.Time) error {
+	if err := booking.SetStatus(status, at); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`UPDATE bookings SET status = ?, confirmed_at = ?, checked_in_at = ?, completed_at = ?, cancelled_at = ?, no_show_at = ?
+		WHERE id = ?`,
+		booking.Status, unixOrZero(booking.ConfirmedAt), unixOrZero(booking.CheckedInAt), unixOrZero(booking.CompletedAt),
+		unixOrZero(booking.CancelledAt), unixOrZero(booking.NoShowAt), booking.ID)
+	return err
+}
+
+func (r *bookingsSqliteRepository) RescheduleBooking(id uint, newDateTime time.Time) error {
+	if newDateTime.Before(time.Now()) {
+		return errors.New("new booking time is in the past")
+	}
+
+	booking, err := r.GetBookingById(id)
+	if err != nil {
+		return err
+	}
+	if booking.BookingDateTime.Before(time.Now()) {
+		return errors.New("booking time is in the past")
+	}
+	if booking.Status != repository.BookingPending && booking.Status != repository.BookingConfirmed {
+		return fmt.Errorf("booking is %s", booking.Status)
+	}
+	if booking.BundleID != 0 {
+		return repository.ErrBookedInBundle
+	}
+	if booking.GroupID != 0 {
+		return repository.ErrBookedInGroup
+	}
+
+	moved := *booking
+	moved.BookingDateTime = newDateTime.In(r.timezone)
+
+	if err := r.checkOpen(&moved); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkConflicts(tx, &moved, "", r.timezone); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE bookings SET starts_at = ? WHERE id = ? AND `+changeableCondition, newDateTime.Unix(), id)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return repository.ErrBookingNotFound // Cancelled in the meantime
+	}
+
+	return tx.Commit()
+}
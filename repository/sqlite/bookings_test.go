@@ -0,0 +1,29 @@
+package sqlite_repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+	"valighita/bookings-ai-agent/repository/repositorytest"
+)
+
+// newTestRepositories creates the SQLite repositories of a business in a new
+// database, for the tests shared with the other backends.
+func newTestRepositories(t *testing.T, catalog *repository.Catalog, timezone *time.Location) *repository.Repositories {
+	repositories := openWithCatalog(t, filepath.Join(t.TempDir(), "bookings.db"), catalog, timezone)
+	return &repository.Repositories{
+		Bookings:  repositories.bookings,
+		Services:  repositories.services,
+		Employees: repositories.employees,
+		Resources: repositories.resources,
+		Locations: repositories.locations,
+		Catalog:   repositories.catalog,
+		Timezone:  timezone,
+	}
+}
+
+func TestBookingRepository(t *testing.T) {
+	repositorytest.TestBookingRepository(t, newTestRepositories)
+}
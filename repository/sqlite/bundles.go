@@ -0,0 +1,11 @@
+package sqlite_repository
+
+import (
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsSqliteRepository) ReserveBundle(bookings []*repository.Booking) error {
+	return r.reserveTogether(bookings, repository.ValidateBundle, "service %d of the visit", "bundle_id", func(booking *repository.Booking, id uint) {
+		booking.BundleID = id
+	})
+}
@@ -0,0 +1,175 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type calendarSqliteRepository struct {
+	db *sql.DB
+}
+
+// NewCalendarSqliteRepository returns a CalendarRepository backed by db. The
+// opening hours and holidays are replaced by the ones in data, while closures
+// added at runtime are kept and the ones in data are added if missing.
+func NewCalendarSqliteRepository(db *sql.DB, data repository.BusinessCalendar) (repository.CalendarRepository, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM opening_hours`); err != nil {
+		return nil, err
+	}
+	for weekday, intervals := range data.OpeningHours {
+		for _, interval := range intervals {
+			_, err := tx.Exec(`INSERT INTO opening_hours (weekday, start_time, end_time) VALUES (?, ?, ?)`,
+				weekday, interval.Start, interval.End)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM holidays`); err != nil {
+		return nil, err
+	}
+	for _, holiday := range data.Holidays {
+		_, err := tx.Exec(`INSERT INTO holidays (month, day, name) VALUES (?, ?, ?)`, holiday.Month, holiday.Day, holiday.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, closure := range data.Closures {
+		_, err := tx.Exec(`INSERT INTO closures (starts_at, ends_at, reason) SELECT ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM closures WHERE starts_at = ? AND ends_at = ?)`,
+			closure.Start.Unix(), closure.End.Unix(), closure.Reason, closure.Start.Unix(), closure.End.Unix())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &calendarSqliteRepository{db: db}, nil
+}
+
+func (r *calendarSqliteRepository) GetOpeningHours() (repository.WeeklySchedule, error) {
+	rows, err := r.db.Query(`SELECT weekday, start_time, end_time FROM opening_hours ORDER BY weekday, start_time`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedule repository.WeeklySchedule
+	for rows.Next() {
+		var weekday time.Weekday
+		var interval repository.WorkingHours
+		if err := rows.Scan(&weekday, &interval.Start, &interval.End); err != nil {
+			return nil, err
+		}
+		if schedule == nil {
+			schedule = repository.WeeklySchedule{}
+		}
+		schedule[weekday] = append(schedule[weekday], interval)
+	}
+
+	return schedule, rows.Err()
+}
+
+// loadCalendar loads the opening hours, the holidays and the closures
+// overlapping [from, to).
+func (r *calendarSqliteRepository) loadCalendar(from time.Time, to time.Time) (*repository.BusinessCalendar, error) {
+	openingHours, err := r.GetOpeningHours()
+	if err != nil {
+		return nil, err
+	}
+	calendar := &repository.BusinessCalendar{OpeningHours: openingHours}
+
+	rows, err := r.db.Query(`SELECT month, day, name FROM holidays`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var holiday repository.Holiday
+		if err := rows.Scan(&holiday.Month, &holiday.Day, &holiday.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		calendar.Holidays = append(calendar.Holidays, &holiday)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(`SELECT id, starts_at, ends_at, reason FROM closures WHERE starts_at < ? AND ends_at > ?`,
+		to.Unix(), from.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var closure repository.Closure
+		var startsAt, endsAt int64
+		if err := rows.Scan(&closure.ID, &startsAt, &endsAt, &closure.Reason); err != nil {
+			return nil, err
+		}
+		closure.Start = time.Unix(startsAt, 0).In(from.Location())
+		closure.End = time.Unix(endsAt, 0).In(from.Location())
+		calendar.Closures = append(calendar.Closures, &closure)
+	}
+
+	return calendar, rows.Err()
+}
+
+func (r *calendarSqliteRepository) GetClosures(from time.Time, to time.Time) ([]*repository.Closure, error) {
+	calendar, err := r.loadCalendar(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return calendar.ClosuresBetween(from, to), nil
+}
+
+func (r *calendarSqliteRepository) AddClosure(closure *repository.Closure) error {
+	if !closure.Start.Before(closure.End) {
+		return errors.New("closure must end after it starts")
+	}
+
+	var id any
+	if closure.ID != 0 {
+		id = closure.ID
+	}
+
+	result, err := r.db.Exec(`INSERT INTO closures (id, starts_at, ends_at, reason) VALUES (?, ?, ?, ?)`,
+		id, closure.Start.Unix(), closure.End.Unix(), closure.Reason)
+	if err != nil {
+		return err
+	}
+
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	closure.ID = uint(insertedId)
+
+	return nil
+}
+
+func (r *calendarSqliteRepository) IsOpen(start time.Time, end time.Time) (bool, string, error) {
+	calendar, err := r.loadCalendar(start, end)
+	if err != nil {
+		return false, "", err
+	}
+
+	reason := calendar.ClosedReason(start, end)
+	return reason == "", reason, nil
+}
@@ -0,0 +1,56 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type catalogSqliteRepository struct {
+	db *sql.DB
+}
+
+// NewCatalogSqliteRepository returns a CatalogRepository backed by db, after
+// setting the catalog to data: the items stored from a previous catalog and
+// missing from data are retired.
+func NewCatalogSqliteRepository(db *sql.DB, data *repository.Catalog) (repository.CatalogRepository, error) {
+	r := &catalogSqliteRepository{db: db}
+	if err := r.SetCatalog(data); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *catalogSqliteRepository) SetCatalog(catalog *repository.Catalog) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Everything is retired first and the items of the catalog are brought
+	// back: names are only unique among the active items, so a name can move
+	// to another id or from an item to another.
+	for _, table := range []string{"locations", "resources", "services", "employees"} {
+		if _, err := tx.Exec(`UPDATE ` + table + ` SET retired = 1`); err != nil {
+			return err
+		}
+	}
+
+	// The ones referred to by the others first
+	if err := upsertLocations(tx, catalog.Locations); err != nil {
+		return err
+	}
+	if err := upsertResources(tx, catalog.Resources); err != nil {
+		return err
+	}
+	if err := upsertServices(tx, catalog.Services); err != nil {
+		return err
+	}
+	if err := upsertEmployees(tx, catalog.Employees); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
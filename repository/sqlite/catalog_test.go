@@ -0,0 +1,247 @@
+package sqlite_repository
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+func allWeek(start string, end string) repository.WeeklySchedule {
+	schedule := repository.WeeklySchedule{}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		schedule[weekday] = []repository.WorkingHours{{Start: start, End: end}}
+	}
+	return schedule
+}
+
+// firstCatalog and secondCatalog are two different catalogs of the same
+// business: the second one drops Old Town and the Scaler, renames Cleaning and
+// Bob, and gives the names Whitening and Alice to new ids.
+func firstCatalog() *repository.Catalog {
+	return &repository.Catalog{
+		Locations: map[uint]*repository.Location{
+			1: {ID: 1, Name: "Old Town"},
+		},
+		Resources: map[uint]*repository.Resource{
+			1: {ID: 1, Name: "Scaler", Capacity: 1},
+		},
+		Services: map[uint]*repository.Service{
+			1: {ID: 1, Name: "Cleaning", Duration: 30, ResourcesIds: []uint{1}},
+			2: {ID: 2, Name: "Whitening", Duration: 60},
+		},
+		Employees: map[uint]*repository.Employee{
+			1: {ID: 1, Name: "Alice", ServicesIds: []uint{1, 2}, Schedule: allWeek("09:00", "17:00")},
+			2: {ID: 2, Name: "Bob", ServicesIds: []uint{1}, Schedule: allWeek("09:00", "17:00")},
+		},
+	}
+}
+
+func secondCatalog() *repository.Catalog {
+	return &repository.Catalog{
+		Locations: map[uint]*repository.Location{
+			2: {ID: 2, Name: "Riverside", Timezone: "Europe/London"},
+		},
+		Resources: map[uint]*repository.Resource{},
+		Services: map[uint]*repository.Service{
+			1: {ID: 1, Name: "Scale and polish", Duration: 30},
+			3: {ID: 3, Name: "Whitening", Duration: 45},
+		},
+		Employees: map[uint]*repository.Employee{
+			2: {ID: 2, Name: "Robert", ServicesIds: []uint{1}, Schedule: allWeek("09:00", "17:00")},
+			3: {ID: 3, Name: "Alice", ServicesIds: []uint{1, 3}, Schedule: allWeek("09:00", "17:00")},
+		},
+	}
+}
+
+type testRepositories struct {
+	catalog   repository.CatalogRepository
+	locations repository.LocationRepository
+	resources repository.ResourceRepository
+	services  repository.ServiceRepository
+	employees repository.EmployeeRepository
+	bookings  repository.BookingRepository
+}
+
+// openWithCatalog opens the database at path as on startup, with data as the
+// catalog and timezone as the business time zone.
+func openWithCatalog(t *testing.T, path string, data *repository.Catalog, timezone *time.Location) *testRepositories {
+	t.Helper()
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("opening the database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	catalog, err := NewCatalogSqliteRepository(db, data)
+	if err != nil {
+		t.Fatalf("seeding the catalog: %v", err)
+	}
+	calendar, err := NewCalendarSqliteRepository(db, repository.BusinessCalendar{})
+	if err != nil {
+		t.Fatalf("seeding the calendar: %v", err)
+	}
+	locations := NewLocationsSqliteRepository(db)
+	services := NewServicesSqliteRepository(db)
+	return &testRepositories{
+		catalog:   catalog,
+		locations: locations,
+		resources: NewResourcesSqliteRepository(db),
+		services:  services,
+		employees: NewEmployeeSqliteRepository(db, services, calendar, locations, 15*time.Minute, timezone),
+		bookings:  NewBookingsSqliteRepository(db, calendar, locations, timezone),
+	}
+}
+
+func ids[T any](items []*T, id func(*T) uint) []uint {
+	result := []uint{}
+	for _, item := range items {
+		result = append(result, id(item))
+	}
+	return result
+}
+
+func equalIds(a []uint, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCatalogReplacedOnTheSameDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bookings.db")
+	tomorrow := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+
+	first := openWithCatalog(t, path, firstCatalog(), time.UTC)
+	booking := &repository.Booking{
+		EmployeeID:      1,
+		ServiceID:       2,
+		LocationID:      1,
+		BookingDateTime: tomorrow.Add(10 * time.Hour),
+		CustomerName:    "Jane",
+	}
+	if err := first.bookings.ReserveBooking(booking); err != nil {
+		t.Fatalf("booking with the first catalog: %v", err)
+	}
+
+	second := openWithCatalog(t, path, secondCatalog(), time.UTC)
+
+	employees, err := second.employees.GetEmployees()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(employees, func(e *repository.Employee) uint { return e.ID }); !equalIds(got, []uint{2, 3}) {
+		t.Errorf("GetEmployees() = %v, want [2 3]", got)
+	}
+	services, err := second.services.GetServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(services, func(s *repository.Service) uint { return s.ID }); !equalIds(got, []uint{1, 3}) {
+		t.Errorf("GetServices() = %v, want [1 3]", got)
+	}
+	locations, err := second.locations.GetLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(locations, func(l *repository.Location) uint { return l.ID }); !equalIds(got, []uint{2}) {
+		t.Errorf("GetLocations() = %v, want [2]", got)
+	} else if locations[0].Timezone != "Europe/London" {
+		t.Errorf("Riverside time zone = %q, want Europe/London", locations[0].Timezone)
+	}
+	resources, err := second.resources.GetResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 0 {
+		t.Errorf("GetResources() = %d resources, want none", len(resources))
+	}
+
+	// The names are looked up among the active items only
+	for name, want := range map[string]uint{"Alice": 3, "robert": 2} {
+		employee, err := second.employees.GetEmployeeByName(name)
+		if err != nil || employee.ID != want {
+			t.Errorf("GetEmployeeByName(%q) = %v, %v, want id %d", name, employee, err, want)
+		}
+	}
+	if _, err := second.employees.GetEmployeeByName("Bob"); err == nil {
+		t.Errorf("GetEmployeeByName(Bob) found a renamed employee")
+	}
+	if service, err := second.services.GetServiceByName("Whitening"); err != nil || service.ID != 3 {
+		t.Errorf("GetServiceByName(Whitening) = %v, %v, want id 3", service, err)
+	}
+	if _, err := second.services.GetServiceByName("Cleaning"); err == nil {
+		t.Errorf("GetServiceByName(Cleaning) found a renamed service")
+	}
+	if _, err := second.locations.GetLocationByName("Old Town"); err == nil {
+		t.Errorf("GetLocationByName(Old Town) found a removed location")
+	}
+
+	// The removed items are kept, retired, for the existing bookings
+	stored, err := second.bookings.GetBookingById(booking.ID)
+	if err != nil {
+		t.Fatalf("the booking of the first catalog is gone: %v", err)
+	}
+	employee, err := second.employees.GetEmployeeById(stored.EmployeeID)
+	if err != nil || !employee.Retired || employee.Name != "Alice" {
+		t.Errorf("GetEmployeeById(1) = %+v, %v, want the retired Alice", employee, err)
+	}
+	service, err := second.services.GetServiceById(stored.ServiceID)
+	if err != nil || !service.Retired {
+		t.Errorf("GetServiceById(2) = %+v, %v, want a retired service", service, err)
+	}
+	location, err := second.locations.GetLocationById(stored.LocationID)
+	if err != nil || !location.Retired {
+		t.Errorf("GetLocationById(1) = %+v, %v, want a retired location", location, err)
+	}
+
+	// and can't be booked anymore
+	slots, err := second.employees.FindAvailableSlots(2, 0, tomorrow, tomorrow.AddDate(0, 0, 1), 0, 0)
+	if err != nil || len(slots) != 0 {
+		t.Errorf("FindAvailableSlots(retired service) = %d slots, %v, want none", len(slots), err)
+	}
+	slots, err = second.employees.FindAvailableSlots(1, 1, tomorrow, tomorrow.AddDate(0, 0, 1), 0, 0)
+	if err != nil || len(slots) != 0 {
+		t.Errorf("FindAvailableSlots(retired location) = %d slots, %v, want none", len(slots), err)
+	}
+	slots, err = second.employees.FindAvailableSlots(1, 0, tomorrow, tomorrow.AddDate(0, 0, 1), 1, 0)
+	if err == nil && len(slots) != 0 {
+		t.Errorf("FindAvailableSlots(retired employee) = %d slots, want none", len(slots))
+	}
+	rejected := []*repository.Booking{
+		{EmployeeID: 1, ServiceID: 1, BookingDateTime: tomorrow.Add(14 * time.Hour), CustomerName: "retired employee"},
+		{EmployeeID: 3, ServiceID: 2, BookingDateTime: tomorrow.Add(14 * time.Hour), CustomerName: "retired service"},
+		{EmployeeID: 3, ServiceID: 1, LocationID: 1, BookingDateTime: tomorrow.Add(14 * time.Hour), CustomerName: "retired location"},
+	}
+	for _, booking := range rejected {
+		err := second.bookings.ReserveBooking(booking)
+		if !errors.Is(err, repository.ErrSlotNotAvailable) && !errors.Is(err, repository.ErrBusinessClosed) {
+			t.Errorf("booking with a %s: got %v, want it rejected", booking.CustomerName, err)
+		}
+	}
+
+	// The new items can be booked
+	booking = &repository.Booking{EmployeeID: 3, ServiceID: 3, LocationID: 2, BookingDateTime: tomorrow.Add(14 * time.Hour), CustomerName: "Jane"}
+	if err := second.bookings.ReserveBooking(booking); err != nil {
+		t.Errorf("booking with the second catalog: %v", err)
+	}
+
+	// Going back to the first catalog brings its items back
+	if err := second.catalog.SetCatalog(firstCatalog()); err != nil {
+		t.Fatalf("setting the first catalog again: %v", err)
+	}
+	if employee, err := second.employees.GetEmployeeByName("Alice"); err != nil || employee.ID != 1 {
+		t.Errorf("GetEmployeeByName(Alice) = %v, %v, want id 1", employee, err)
+	}
+	if service, err := second.services.GetServiceByName("Cleaning"); err != nil || service.ID != 1 || service.Retired {
+		t.Errorf("GetServiceByName(Cleaning) = %+v, %v, want the active id 1", service, err)
+	}
+}
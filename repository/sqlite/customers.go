@@ -0,0 +1,238 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type customersSqliteRepository struct {
+	db       *sql.DB
+	timezone *time.Location
+}
+
+// NewCustomersSqliteRepository returns the customers stored in db. The phone
+// numbers stored before they were validated are converted to E.164, national
+// numbers being of phoneCountry, and the bookings made before customers were
+// stored are linked to them, so their history isn't lost.
+func NewCustomersSqliteRepository(db *sql.DB, timezone *time.Location, phoneCountry string) (repository.CustomerRepository, error) {
+	if err := normalizeCustomersPhones(db, phoneCountry); err != nil {
+		return nil, err
+	}
+	if err := linkBookingsCustomers(db, phoneCountry); err != nil {
+		return nil, err
+	}
+
+	return &customersSqliteRepository{db: db, timezone: timezone}, nil
+}
+
+// normalizeCustomersPhones converts the phone numbers of the customers that
+// aren't in E.164 format yet. When the converted number already belongs to
+// another customer, the two are merged: the bookings are moved to the existing
+// customer. Numbers that can't be parsed are left as they are.
+func normalizeCustomersPhones(db *sql.DB, phoneCountry string) error {
+	rows, err := db.Query(`SELECT id, phone FROM customers WHERE phone NOT LIKE '+%' ORDER BY id`)
+	if err != nil {
+		return err
+	}
+
+	phones := map[uint]string{}
+	for rows.Next() {
+		var id uint
+		var phone string
+		if err := rows.Scan(&id, &phone); err != nil {
+			rows.Close()
+			return err
+		}
+		if parsed, err := repository.ParsePhone(phone, phoneCountry); err == nil {
+			phones[id] = parsed
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(phones) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, phone := range phones {
+		var existingId uint
+		err := tx.QueryRow(`SELECT id FROM customers WHERE phone = ?`, phone).Scan(&existingId)
+		if errors.Is(err, sql.ErrNoRows) {
+			existingId = id
+			_, err = tx.Exec(`UPDATE customers SET phone = ? WHERE id = ?`, phone, id)
+		} else if err == nil {
+			_, err = tx.Exec(`DELETE FROM customers WHERE id = ?`, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE bookings SET customer_id = ?, customer_phone = ? WHERE customer_id = ?`, existingId, phone, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// linkBookingsCustomers links the bookings that have a phone number but no
+// customer, oldest first so the latest name given wins. The numbers are
+// converted to E.164 when they can be parsed.
+func linkBookingsCustomers(db *sql.DB, phoneCountry string) error {
+	rows, err := db.Query(`SELECT id, customer_name, customer_phone FROM bookings WHERE customer_id = 0 AND customer_phone != ''
+		ORDER BY starts_at, id`)
+	if err != nil {
+		return err
+	}
+
+	var bookings []*repository.Booking
+	for rows.Next() {
+		var booking repository.Booking
+		if err := rows.Scan(&booking.ID, &booking.CustomerName, &booking.CustomerPhone); err != nil {
+			rows.Close()
+			return err
+		}
+		bookings = append(bookings, &booking)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(bookings) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, booking := range bookings {
+		if phone, err := repository.ParsePhone(booking.CustomerPhone, phoneCountry); err == nil {
+			booking.CustomerPhone = phone
+		}
+		if err := linkCustomer(tx, booking); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE bookings SET customer_id = ?, customer_phone = ? WHERE id = ?`,
+			booking.CustomerID, booking.CustomerPhone, booking.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// linkCustomer links a new booking to the customer with its phone number,
+// creating or updating the customer. Bookings without a phone number aren't
+// linked.
+func linkCustomer(tx *sql.Tx, booking *repository.Booking) error {
+	if repository.NormalizePhone(booking.CustomerPhone) == "" {
+		return nil
+	}
+
+	// Only the id and the phone of the customer are used, its time zone
+	// doesn't matter
+	customer, err := upsertCustomer(tx, booking.CustomerName, booking.CustomerPhone, time.UTC)
+	if err != nil {
+		return err
+	}
+	booking.CustomerID = customer.ID
+	booking.CustomerPhone = customer.Phone
+	return nil
+}
+
+const customerColumns = `id, name, phone, created_at`
+
+// scanCustomer reads a customer selected with customerColumns, with its times
+// in timezone.
+func scanCustomer(row interface{ Scan(...any) error }, timezone *time.Location) (*repository.Customer, error) {
+	var customer repository.Customer
+	var createdAt int64
+	if err := row.Scan(&customer.ID, &customer.Name, &customer.Phone, &createdAt); err != nil {
+		return nil, err
+	}
+	customer.CreatedAt = time.Unix(createdAt, 0).In(timezone)
+	return &customer, nil
+}
+
+// upsertCustomer implements CustomerRepository.UpsertCustomer inside tx.
+func upsertCustomer(tx *sql.Tx, name string, phone string, timezone *time.Location) (*repository.Customer, error) {
+	phone = repository.NormalizePhone(phone)
+	if phone == "" {
+		return nil, repository.ErrEmptyPhone
+	}
+
+	customer, err := scanCustomer(tx.QueryRow(`SELECT `+customerColumns+` FROM customers WHERE phone = ?`, phone), timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		customer = &repository.Customer{Name: name, Phone: phone, CreatedAt: time.Now().In(timezone)}
+		result, err := tx.Exec(`INSERT INTO customers (name, phone, created_at) VALUES (?, ?, ?)`,
+			customer.Name, customer.Phone, customer.CreatedAt.Unix())
+		if err != nil {
+			return nil, err
+		}
+		insertedId, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		customer.ID = uint(insertedId)
+		return customer, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" && name != customer.Name {
+		if _, err := tx.Exec(`UPDATE customers SET name = ? WHERE id = ?`, name, customer.ID); err != nil {
+			return nil, err
+		}
+		customer.Name = name
+	}
+
+	return customer, nil
+}
+
+func (r *customersSqliteRepository) GetCustomerById(id uint) (*repository.Customer, error) {
+	customer, err := scanCustomer(r.db.QueryRow(`SELECT `+customerColumns+` FROM customers WHERE id = ?`, id), r.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrCustomerNotFound
+	}
+	return customer, err
+}
+
+func (r *customersSqliteRepository) GetCustomerByPhone(phone string) (*repository.Customer, error) {
+	customer, err := scanCustomer(r.db.QueryRow(`SELECT `+customerColumns+` FROM customers WHERE phone = ?`,
+		repository.NormalizePhone(phone)), r.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrCustomerNotFound
+	}
+	return customer, err
+}
+
+func (r *customersSqliteRepository) UpsertCustomer(name string, phone string) (*repository.Customer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	customer, err := upsertCustomer(tx, name, phone, r.timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return customer, tx.Commit()
+}
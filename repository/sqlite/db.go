@@ -0,0 +1,406 @@
+package sqlite_repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order, each one exactly once. New schema changes
+// must be appended at the end, never edited in place.
+var migrations = []string{
+	`CREATE TABLE services (
+		id       INTEGER PRIMARY KEY,
+		name     TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		price    REAL NOT NULL,
+		duration INTEGER NOT NULL
+	);
+	CREATE TABLE employees (
+		id          INTEGER PRIMARY KEY,
+		name        TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		description TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE employee_services (
+		employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+		service_id  INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+		PRIMARY KEY (employee_id, service_id)
+	);
+	CREATE TABLE bookings (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		employee_id    INTEGER NOT NULL REFERENCES employees(id),
+		service_id     INTEGER NOT NULL REFERENCES services(id),
+		starts_at      INTEGER NOT NULL,
+		customer_name  TEXT NOT NULL,
+		customer_phone TEXT NOT NULL
+	);
+	CREATE INDEX bookings_employee_starts_at ON bookings(employee_id, starts_at);`,
+
+	`CREATE INDEX bookings_customer_phone ON bookings(customer_phone, starts_at);`,
+
+	`CREATE TABLE employee_working_hours (
+		employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+		weekday     INTEGER NOT NULL,
+		start_time  TEXT NOT NULL,
+		end_time    TEXT NOT NULL
+	);
+	CREATE INDEX employee_working_hours_employee ON employee_working_hours(employee_id, weekday);`,
+
+	`CREATE TABLE opening_hours (
+		weekday    INTEGER NOT NULL,
+		start_time TEXT NOT NULL,
+		end_time   TEXT NOT NULL
+	);
+	CREATE TABLE holidays (
+		month INTEGER NOT NULL,
+		day   INTEGER NOT NULL,
+		name  TEXT NOT NULL
+	);
+	CREATE TABLE closures (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		starts_at INTEGER NOT NULL,
+		ends_at   INTEGER NOT NULL,
+		reason    TEXT NOT NULL
+	);
+	CREATE INDEX closures_starts_at ON closures(starts_at, ends_at);`,
+
+	`CREATE TABLE employee_time_off (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+		starts_at   INTEGER NOT NULL,
+		ends_at     INTEGER NOT NULL,
+		reason      TEXT NOT NULL
+	);
+	CREATE INDEX employee_time_off_employee ON employee_time_off(employee_id, starts_at);`,
+
+	`ALTER TABLE services ADD COLUMN buffer_before INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE services ADD COLUMN buffer_after INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN duration INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN buffer_before INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN buffer_after INTEGER NOT NULL DEFAULT 0;
+	UPDATE bookings SET duration = (SELECT duration FROM services WHERE services.id = bookings.service_id);`,
+
+	`CREATE TABLE holds (
+		id            INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id    TEXT NOT NULL,
+		expires_at    INTEGER NOT NULL,
+		employee_id   INTEGER NOT NULL REFERENCES employees(id),
+		service_id    INTEGER NOT NULL REFERENCES services(id),
+		starts_at     INTEGER NOT NULL,
+		duration      INTEGER NOT NULL,
+		buffer_before INTEGER NOT NULL,
+		buffer_after  INTEGER NOT NULL
+	);
+	CREATE INDEX holds_employee_starts_at ON holds(employee_id, starts_at);
+	CREATE INDEX holds_session ON holds(session_id);`,
+
+	`CREATE TABLE resources (
+		id       INTEGER PRIMARY KEY,
+		name     TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		capacity INTEGER NOT NULL
+	);
+	CREATE TABLE service_resources (
+		service_id  INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+		resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+		PRIMARY KEY (service_id, resource_id)
+	);
+	CREATE TABLE booking_resources (
+		booking_id  INTEGER NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+		resource_id INTEGER NOT NULL REFERENCES resources(id),
+		PRIMARY KEY (booking_id, resource_id)
+	);
+	CREATE INDEX booking_resources_resource ON booking_resources(resource_id);
+	CREATE TABLE hold_resources (
+		hold_id     INTEGER NOT NULL REFERENCES holds(id) ON DELETE CASCADE,
+		resource_id INTEGER NOT NULL REFERENCES resources(id),
+		PRIMARY KEY (hold_id, resource_id)
+	);
+	CREATE INDEX hold_resources_resource ON hold_resources(resource_id);`,
+
+	`CREATE TABLE locations (
+		id       INTEGER PRIMARY KEY,
+		name     TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		address  TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE location_opening_hours (
+		location_id INTEGER NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+		weekday     INTEGER NOT NULL,
+		start_time  TEXT NOT NULL,
+		end_time    TEXT NOT NULL
+	);
+	CREATE INDEX location_opening_hours_location ON location_opening_hours(location_id, weekday);
+	CREATE TABLE employee_locations (
+		employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+		weekday     INTEGER NOT NULL,
+		location_id INTEGER NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+		PRIMARY KEY (employee_id, weekday)
+	);
+	CREATE TABLE service_locations (
+		service_id  INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+		location_id INTEGER NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+		PRIMARY KEY (service_id, location_id)
+	);
+	ALTER TABLE bookings ADD COLUMN location_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE holds ADD COLUMN location_id INTEGER NOT NULL DEFAULT 0;`,
+
+	`CREATE TABLE booking_series (
+		id              INTEGER PRIMARY KEY AUTOINCREMENT,
+		repeat_interval INTEGER NOT NULL,
+		repeat_unit     TEXT NOT NULL,
+		repeat_count    INTEGER NOT NULL,
+		repeat_until    INTEGER NOT NULL
+	);
+	ALTER TABLE bookings ADD COLUMN series_id INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX bookings_series ON bookings(series_id);
+	ALTER TABLE services ADD COLUMN recall_months INTEGER NOT NULL DEFAULT 0;`,
+
+	`CREATE TABLE waitlist (
+		id               INTEGER PRIMARY KEY AUTOINCREMENT,
+		service_id       INTEGER NOT NULL REFERENCES services(id),
+		employee_id      INTEGER NOT NULL DEFAULT 0,
+		location_id      INTEGER NOT NULL DEFAULT 0,
+		from_at          INTEGER NOT NULL,
+		to_at            INTEGER NOT NULL,
+		customer_name    TEXT NOT NULL,
+		customer_phone   TEXT NOT NULL,
+		created_at       INTEGER NOT NULL,
+		status           TEXT NOT NULL,
+		offer_hold_id    INTEGER NOT NULL DEFAULT 0,
+		offer_expires_at INTEGER NOT NULL DEFAULT 0,
+		booking_id       INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX waitlist_status ON waitlist(status, created_at);
+	CREATE INDEX waitlist_customer_phone ON waitlist(customer_phone);`,
+
+	`ALTER TABLE bookings ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed';
+	ALTER TABLE bookings ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN confirmed_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN checked_in_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN completed_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN cancelled_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN no_show_at INTEGER NOT NULL DEFAULT 0;`,
+
+	`CREATE TABLE customers (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT NOT NULL,
+		phone      TEXT NOT NULL UNIQUE,
+		created_at INTEGER NOT NULL
+	);
+	ALTER TABLE bookings ADD COLUMN customer_id INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX bookings_customer ON bookings(customer_id, starts_at);`,
+
+	`ALTER TABLE services ADD COLUMN category TEXT NOT NULL DEFAULT '';
+	ALTER TABLE services ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE services ADD COLUMN preparation TEXT NOT NULL DEFAULT '';
+	ALTER TABLE services ADD COLUMN aftercare TEXT NOT NULL DEFAULT '';
+	ALTER TABLE services ADD COLUMN min_age INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE services ADD COLUMN max_age INTEGER NOT NULL DEFAULT 0;`,
+
+	`ALTER TABLE bookings ADD COLUMN bundle_id INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX bookings_bundle ON bookings(bundle_id);`,
+
+	`ALTER TABLE bookings ADD COLUMN attendee_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE bookings ADD COLUMN group_id INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX bookings_group ON bookings(group_id);`,
+
+	`ALTER TABLE services ADD COLUMN deposit REAL NOT NULL DEFAULT 0;
+	ALTER TABLE holds ADD COLUMN deposit REAL NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN deposit REAL NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN payment_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE bookings ADD COLUMN payment_link TEXT NOT NULL DEFAULT '';
+	ALTER TABLE bookings ADD COLUMN payment_due_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE bookings ADD COLUMN paid_at INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX bookings_payment ON bookings(payment_id);
+	CREATE INDEX bookings_payment_due_at ON bookings(payment_due_at) WHERE payment_due_at != 0;`,
+
+	// Items removed from the catalog are retired, and their names can be given
+	// to other items: names are only unique among the active ones.
+	`CREATE TABLE new_services (
+		id            INTEGER PRIMARY KEY,
+		name          TEXT NOT NULL COLLATE NOCASE,
+		price         REAL NOT NULL,
+		duration      INTEGER NOT NULL,
+		buffer_before INTEGER NOT NULL DEFAULT 0,
+		buffer_after  INTEGER NOT NULL DEFAULT 0,
+		recall_months INTEGER NOT NULL DEFAULT 0,
+		category      TEXT NOT NULL DEFAULT '',
+		description   TEXT NOT NULL DEFAULT '',
+		preparation   TEXT NOT NULL DEFAULT '',
+		aftercare     TEXT NOT NULL DEFAULT '',
+		min_age       INTEGER NOT NULL DEFAULT 0,
+		max_age       INTEGER NOT NULL DEFAULT 0,
+		deposit       REAL NOT NULL DEFAULT 0,
+		retired       INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO new_services (id, name, price, duration, buffer_before, buffer_after, recall_months,
+		category, description, preparation, aftercare, min_age, max_age, deposit)
+		SELECT id, name, price, duration, buffer_before, buffer_after, recall_months,
+			category, description, preparation, aftercare, min_age, max_age, deposit FROM services;
+	DROP TABLE services;
+	ALTER TABLE new_services RENAME TO services;
+	CREATE UNIQUE INDEX services_name ON services(name) WHERE retired = 0;
+
+	CREATE TABLE new_employees (
+		id          INTEGER PRIMARY KEY,
+		name        TEXT NOT NULL COLLATE NOCASE,
+		description TEXT NOT NULL DEFAULT '',
+		retired     INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO new_employees (id, name, description) SELECT id, name, description FROM employees;
+	DROP TABLE employees;
+	ALTER TABLE new_employees RENAME TO employees;
+	CREATE UNIQUE INDEX employees_name ON employees(name) WHERE retired = 0;
+
+	CREATE TABLE new_resources (
+		id       INTEGER PRIMARY KEY,
+		name     TEXT NOT NULL COLLATE NOCASE,
+		capacity INTEGER NOT NULL,
+		retired  INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO new_resources (id, name, capacity) SELECT id, name, capacity FROM resources;
+	DROP TABLE resources;
+	ALTER TABLE new_resources RENAME TO resources;
+	CREATE UNIQUE INDEX resources_name ON resources(name) WHERE retired = 0;
+
+	CREATE TABLE new_locations (
+		id       INTEGER PRIMARY KEY,
+		name     TEXT NOT NULL COLLATE NOCASE,
+		address  TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT '',
+		retired  INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO new_locations (id, name, address, timezone) SELECT id, name, address, timezone FROM locations;
+	DROP TABLE locations;
+	ALTER TABLE new_locations RENAME TO locations;
+	CREATE UNIQUE INDEX locations_name ON locations(name) WHERE retired = 0;`,
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx, so the same queries can
+// run inside and outside a transaction.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// queryIds runs a query selecting a single id column.
+func queryIds(q queryer, query string, args ...any) ([]uint, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uint{}
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// unixOrZero returns the unix time of t, or 0 for the zero time.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// timeOrZero is the inverse of unixOrZero, in the given time zone.
+func timeOrZero(unix int64, timezone *time.Location) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0).In(timezone)
+}
+
+// Open opens (or creates) the SQLite database at path and brings its schema up
+// to date.
+func Open(path string) (*sql.DB, error) {
+	// Transactions take the write lock when they begin, so a check followed by
+	// a write can't be interleaved with another process using the same file.
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time; a single connection serializes
+	// access and keeps read-then-write transactions from failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrate applies the migrations not applied yet. The foreign keys are off
+// meanwhile, so tables referred to by others can be rebuilt without cascading,
+// and checked at the end.
+func migrate(db *sql.DB) error {
+	ctx := context.Background()
+	// The foreign keys are a setting of the connection, keep the same one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if current == len(migrations) {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+	}
+
+	rows, err := conn.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		return fmt.Errorf("foreign keys broken by the migrations")
+	}
+	return rows.Err()
+}
@@ -0,0 +1,524 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type employeeSqliteRepository struct {
+	db                 *sql.DB
+	serviceRepository  repository.ServiceRepository
+	calendarRepository repository.CalendarRepository
+	locationRepository repository.LocationRepository
+	slotGranularity    time.Duration
+	timezone           *time.Location
+}
+
+// NewEmployeeSqliteRepository returns an EmployeeRepository backed by db. The
+// employees are stored by the CatalogRepository.
+func NewEmployeeSqliteRepository(db *sql.DB, serviceRepository repository.ServiceRepository, calendarRepository repository.CalendarRepository, locationRepository repository.LocationRepository, slotGranularity time.Duration, timezone *time.Location) repository.EmployeeRepository {
+	return &employeeSqliteRepository{
+		db:                 db,
+		serviceRepository:  serviceRepository,
+		calendarRepository: calendarRepository,
+		locationRepository: locationRepository,
+		slotGranularity:    slotGranularity,
+		timezone:           timezone,
+	}
+}
+
+// upsertEmployees stores the employees in data with the services they offer,
+// their schedules and their branches, as active.
+func upsertEmployees(tx *sql.Tx, data map[uint]*repository.Employee) error {
+	for _, employee := range data {
+		_, err := tx.Exec(`INSERT INTO employees (id, name, description) VALUES (?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET name = excluded.name, description = excluded.description, retired = 0`,
+			employee.ID, employee.Name, employee.Description)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM employee_services WHERE employee_id = ?`, employee.ID); err != nil {
+			return err
+		}
+		for _, serviceId := range employee.ServicesIds {
+			_, err := tx.Exec(`INSERT INTO employee_services (employee_id, service_id) VALUES (?, ?)`, employee.ID, serviceId)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`DELETE FROM employee_working_hours WHERE employee_id = ?`, employee.ID); err != nil {
+			return err
+		}
+		for weekday, intervals := range employee.Schedule {
+			for _, interval := range intervals {
+				_, err := tx.Exec(`INSERT INTO employee_working_hours (employee_id, weekday, start_time, end_time) VALUES (?, ?, ?, ?)`,
+					employee.ID, weekday, interval.Start, interval.End)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := tx.Exec(`DELETE FROM employee_locations WHERE employee_id = ?`, employee.ID); err != nil {
+			return err
+		}
+		for weekday, locationId := range employee.Locations {
+			_, err := tx.Exec(`INSERT INTO employee_locations (employee_id, weekday, location_id) VALUES (?, ?, ?)`,
+				employee.ID, weekday, locationId)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *employeeSqliteRepository) queryEmployees(query string, args ...any) ([]*repository.Employee, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var employees []*repository.Employee
+	for rows.Next() {
+		var employee repository.Employee
+		if err := rows.Scan(&employee.ID, &employee.Name, &employee.Description, &employee.Retired); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		employees = append(employees, &employee)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The services, schedules and branches are loaded after the employee rows
+	// are closed, the database only has a single connection.
+	for _, employee := range employees {
+		if err := loadServicesIds(r.db, employee); err != nil {
+			return nil, err
+		}
+		if err := loadSchedule(r.db, employee); err != nil {
+			return nil, err
+		}
+		if err := loadLocations(r.db, employee); err != nil {
+			return nil, err
+		}
+	}
+
+	return employees, nil
+}
+
+func loadServicesIds(q queryer, employee *repository.Employee) error {
+	rows, err := q.Query(`SELECT service_id FROM employee_services WHERE employee_id = ? ORDER BY service_id`, employee.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	employee.ServicesIds = []uint{}
+	for rows.Next() {
+		var serviceId uint
+		if err := rows.Scan(&serviceId); err != nil {
+			return err
+		}
+		employee.ServicesIds = append(employee.ServicesIds, serviceId)
+	}
+
+	return rows.Err()
+}
+
+func loadSchedule(q queryer, employee *repository.Employee) error {
+	rows, err := q.Query(`SELECT weekday, start_time, end_time FROM employee_working_hours
+		WHERE employee_id = ? ORDER BY weekday, start_time`, employee.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	employee.Schedule = nil
+	for rows.Next() {
+		var weekday time.Weekday
+		var interval repository.WorkingHours
+		if err := rows.Scan(&weekday, &interval.Start, &interval.End); err != nil {
+			return err
+		}
+		if employee.Schedule == nil {
+			employee.Schedule = repository.WeeklySchedule{}
+		}
+		employee.Schedule[weekday] = append(employee.Schedule[weekday], interval)
+	}
+
+	return rows.Err()
+}
+
+func loadLocations(q queryer, employee *repository.Employee) error {
+	rows, err := q.Query(`SELECT weekday, location_id FROM employee_locations WHERE employee_id = ?`, employee.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	employee.Locations = nil
+	for rows.Next() {
+		var weekday time.Weekday
+		var locationId uint
+		if err := rows.Scan(&weekday, &locationId); err != nil {
+			return err
+		}
+		if employee.Locations == nil {
+			employee.Locations = repository.WeeklyLocations{}
+		}
+		employee.Locations[weekday] = locationId
+	}
+
+	return rows.Err()
+}
+
+func (r *employeeSqliteRepository) getEmployee(query string, args ...any) (*repository.Employee, error) {
+	employees, err := r.queryEmployees(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(employees) == 0 {
+		return nil, errors.New("employee not found")
+	}
+
+	return employees[0], nil
+}
+
+func (r *employeeSqliteRepository) GetEmployees() ([]*repository.Employee, error) {
+	return r.queryEmployees(`SELECT id, name, description, retired FROM employees WHERE retired = 0 ORDER BY id`)
+}
+
+func (r *employeeSqliteRepository) GetEmployeeById(id uint) (*repository.Employee, error) {
+	return r.getEmployee(`SELECT id, name, description, retired FROM employees WHERE id = ?`, id)
+}
+
+func (r *employeeSqliteRepository) GetEmployeeByName(name string) (*repository.Employee, error) {
+	return r.getEmployee(`SELECT id, name, description, retired FROM employees WHERE name = ? AND retired = 0`, name)
+}
+
+func (r *employeeSqliteRepository) CheckAvailability(employeeId uint, serviceId uint, locationId uint, bookingDate string, bookingTime string) (bool, error) {
+	employee, err := r.GetEmployeeById(employeeId)
+	if err != nil {
+		return false, err
+	}
+
+	service, err := r.serviceRepository.GetServiceById(serviceId)
+	if err != nil {
+		return false, err
+	}
+
+	// The branch of the day gives the time zone of the time
+	date, err := time.ParseInLocation("2006-01-02", bookingDate, r.timezone)
+	if err != nil {
+		return false, err
+	}
+	locationId = employee.ResolveLocation(locationId, date.Weekday())
+	timezone, err := repository.LocationTimezone(r.locationRepository, locationId, r.timezone)
+	if err != nil {
+		return false, err
+	}
+	checkTime, err := repository.ParseLocalTime("2006-01-02 15:04", bookingDate+" "+bookingTime, timezone)
+	if err != nil {
+		return false, err
+	}
+
+	return r.isAvailable(employee, service, locationId, checkTime)
+}
+
+// isAvailable reports whether the employee can perform the service at the
+// branch starting at checkTime.
+func (r *employeeSqliteRepository) isAvailable(employee *repository.Employee, service *repository.Service, locationId uint, checkTime time.Time) (bool, error) {
+	if checkTime.Before(time.Now()) {
+		return false, nil // The time is in the past
+	}
+
+	if employee.Retired || service.Retired {
+		return false, nil // Removed from the catalog
+	}
+
+	if !employee.WorksAt(locationId, checkTime.In(r.timezone).Weekday()) || !service.OfferedAt(locationId) {
+		return false, nil // Not at this branch on that day
+	}
+
+	// The working hours, the calendar and the opening hours are read in the
+	// time zone of the branch
+	timezone, err := repository.LocationTimezone(r.locationRepository, locationId, r.timezone)
+	if err != nil {
+		return false, err
+	}
+	checkTime = checkTime.In(timezone)
+
+	checkEndTime := checkTime.Add(time.Duration(service.Duration) * time.Minute)
+	working, err := r.isWorking(employee, checkTime, checkEndTime)
+	if err != nil || !working {
+		return false, err // Outside working hours or on time off
+	}
+
+	open, _, err := r.calendarRepository.IsOpen(checkTime, checkEndTime)
+	if err != nil || !open {
+		return false, err // The business is closed
+	}
+
+	err = repository.CheckLocationOpen(r.locationRepository, locationId, checkTime, checkEndTime)
+	if errors.Is(err, repository.ErrBusinessClosed) {
+		return false, nil // The branch is closed
+	}
+	if err != nil {
+		return false, err
+	}
+
+	blockedStart, blockedEnd := service.BlockedInterval(checkTime)
+	var overlapping int
+	err = r.db.QueryRow(`SELECT (SELECT COUNT(*) FROM bookings WHERE `+occupiesCondition+` AND `+overlapsCondition+`)
+		+ (SELECT COUNT(*) FROM holds WHERE expires_at > ? AND `+overlapsCondition+`)`,
+		employee.ID, blockedEnd.Unix(), blockedStart.Unix(),
+		time.Now().Unix(), employee.ID, blockedEnd.Unix(), blockedStart.Unix()).Scan(&overlapping)
+	if err != nil || overlapping > 0 {
+		return false, err
+	}
+
+	candidate := repository.Booking{EmployeeID: employee.ID, ServiceID: service.ID, LocationID: locationId, BookingDateTime: checkTime}
+	candidate.SetServiceSnapshot(service)
+	err = checkResources(r.db, &candidate, "")
+	if errors.Is(err, repository.ErrSlotNotAvailable) {
+		return false, nil // A resource is fully used
+	}
+
+	return err == nil, err
+}
+
+func (r *employeeSqliteRepository) FindAvailableSlots(serviceId uint, locationId uint, from time.Time, to time.Time, employeeId uint, limit int) ([]*repository.Slot, error) {
+	service, err := r.serviceRepository.GetServiceById(serviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	employees, err := r.queryEmployees(`SELECT e.id, e.name, e.description, e.retired FROM employees e
+		JOIN employee_services es ON es.employee_id = e.id
+		WHERE es.service_id = ? AND e.retired = 0 AND (? = 0 OR e.id = ?) ORDER BY e.id`, serviceId, employeeId, employeeId)
+	if err != nil {
+		return nil, err
+	}
+	if employeeId != 0 && len(employees) == 0 {
+		return nil, errors.New("employee does not offer the service")
+	}
+
+	slots := []*repository.Slot{}
+	for start := repository.FirstSlot(from.In(r.timezone), r.slotGranularity); start.Before(to); start = start.Add(r.slotGranularity) {
+		for _, employee := range employees {
+			slotLocationId := employee.ResolveLocation(locationId, start.Weekday())
+			available, err := r.isAvailable(employee, service, slotLocationId, start)
+			if err != nil {
+				return nil, err
+			}
+			if !available {
+				continue
+			}
+
+			slots = append(slots, &repository.Slot{EmployeeID: employee.ID, LocationID: slotLocationId, Start: start})
+			if limit > 0 && len(slots) == limit {
+				return slots, nil
+			}
+		}
+	}
+
+	return slots, nil
+}
+
+func (r *employeeSqliteRepository) FindBundleSlots(servicesIds []uint, locationId uint, from time.Time, to time.Time, limit int) ([]*repository.BundleSlot, error) {
+	steps, err := r.serviceSteps(servicesIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindBundleSlots(steps, locationId, from.In(r.timezone), to, r.slotGranularity, limit, r.isAvailable)
+}
+
+func (r *employeeSqliteRepository) FindGroupSlots(servicesIds []uint, locationId uint, from time.Time, to time.Time, limit int) ([]*repository.GroupSlot, error) {
+	steps, err := r.serviceSteps(servicesIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindGroupSlots(steps, locationId, from.In(r.timezone), to, r.slotGranularity, limit, r.isAvailable)
+}
+
+// serviceSteps returns the services with the employees offering them, sorted
+// by id.
+func (r *employeeSqliteRepository) serviceSteps(servicesIds []uint) ([]*repository.BundleStep, error) {
+	steps := make([]*repository.BundleStep, 0, len(servicesIds))
+	for _, serviceId := range servicesIds {
+		service, err := r.serviceRepository.GetServiceById(serviceId)
+		if err != nil {
+			return nil, err
+		}
+
+		employees, err := r.GetEmployeesForServiceId(serviceId)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, &repository.BundleStep{Service: service, Employees: employees})
+	}
+
+	return steps, nil
+}
+
+// checkEmployee returns an error wrapping ErrEmployeeNotWorking if the
+// employee of the booking can't take it, see Employee.CheckWorking. It runs on
+// q so it can be part of the transaction storing the booking. timezone is the
+// business time zone.
+func checkEmployee(q queryer, booking *repository.Booking, timezone *time.Location) error {
+	employee := repository.Employee{ID: booking.EmployeeID}
+	err := q.QueryRow(`SELECT name, retired FROM employees WHERE id = ?`, employee.ID).Scan(&employee.Name, &employee.Retired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("employee not found")
+	}
+	if err != nil {
+		return err
+	}
+	if err := loadServicesIds(q, &employee); err != nil {
+		return err
+	}
+	if err := loadSchedule(q, &employee); err != nil {
+		return err
+	}
+	if err := loadLocations(q, &employee); err != nil {
+		return err
+	}
+
+	timeOff, err := queryTimeOff(q, employee.ID, booking.BookingDateTime, booking.EndDateTime())
+	if err != nil {
+		return err
+	}
+	timezone, err = locationTimezone(q, booking.LocationID, timezone)
+	if err != nil {
+		return err
+	}
+
+	return employee.CheckWorking(booking, timeOff, timezone)
+}
+
+func (r *employeeSqliteRepository) isWorking(employee *repository.Employee, start time.Time, end time.Time) (bool, error) {
+	if !employee.Schedule.Covers(start, end) {
+		return false, nil
+	}
+
+	var absences int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM employee_time_off WHERE employee_id = ? AND starts_at < ? AND ends_at > ?`,
+		employee.ID, end.Unix(), start.Unix()).Scan(&absences)
+	if err != nil {
+		return false, err
+	}
+
+	return absences == 0, nil
+}
+
+func (r *employeeSqliteRepository) IsWorking(employeeId uint, start time.Time, end time.Time) (bool, error) {
+	employee, err := r.GetEmployeeById(employeeId)
+	if err != nil {
+		return false, err
+	}
+
+	return r.isWorking(employee, start, end)
+}
+
+func (r *employeeSqliteRepository) AddTimeOff(timeOff *repository.TimeOff) ([]*repository.Booking, error) {
+	if _, err := r.GetEmployeeById(timeOff.EmployeeID); err != nil {
+		return nil, err
+	}
+	if !timeOff.Start.Before(timeOff.End) {
+		return nil, errors.New("time off must end after it starts")
+	}
+
+	var id any
+	if timeOff.ID != 0 {
+		id = timeOff.ID
+	}
+
+	result, err := r.db.Exec(`INSERT INTO employee_time_off (id, employee_id, starts_at, ends_at, reason) VALUES (?, ?, ?, ?, ?)`,
+		id, timeOff.EmployeeID, timeOff.Start.Unix(), timeOff.End.Unix(), timeOff.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	timeOff.ID = uint(insertedId)
+
+	return queryBookings(r.db, r.timezone, `SELECT `+bookingColumns+` FROM bookings WHERE `+occupiesCondition+` AND `+overlapsCondition+` ORDER BY starts_at`,
+		timeOff.EmployeeID, timeOff.End.Unix(), timeOff.Start.Unix())
+}
+
+func (r *employeeSqliteRepository) GetTimeOff(employeeId uint, from time.Time, to time.Time) ([]*repository.TimeOff, error) {
+	return queryTimeOff(r.db, employeeId, from, to)
+}
+
+// queryTimeOff returns the absences of the employee overlapping [from, to),
+// with their times in from's location.
+func queryTimeOff(q queryer, employeeId uint, from time.Time, to time.Time) ([]*repository.TimeOff, error) {
+	rows, err := q.Query(`SELECT id, employee_id, starts_at, ends_at, reason FROM employee_time_off
+		WHERE employee_id = ? AND starts_at < ? AND ends_at > ? ORDER BY starts_at`,
+		employeeId, to.Unix(), from.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timeOffs []*repository.TimeOff
+	for rows.Next() {
+		var timeOff repository.TimeOff
+		var startsAt, endsAt int64
+		if err := rows.Scan(&timeOff.ID, &timeOff.EmployeeID, &startsAt, &endsAt, &timeOff.Reason); err != nil {
+			return nil, err
+		}
+		timeOff.Start = time.Unix(startsAt, 0).In(from.Location())
+		timeOff.End = time.Unix(endsAt, 0).In(from.Location())
+		timeOffs = append(timeOffs, &timeOff)
+	}
+
+	return timeOffs, rows.Err()
+}
+
+func (r *employeeSqliteRepository) RemoveTimeOff(id uint) error {
+	result, err := r.db.Exec(`DELETE FROM employee_time_off WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return errors.New("time off not found")
+	}
+
+	return nil
+}
+
+func (r *employeeSqliteRepository) GetServicesByEmployeeId(employeeId uint) ([]*repository.Service, error) {
+	if _, err := r.GetEmployeeById(employeeId); err != nil {
+		return nil, err
+	}
+
+	return queryServices(r.db, `SELECT `+serviceColumns+` FROM services
+		WHERE id IN (SELECT service_id FROM employee_services WHERE employee_id = ?) AND retired = 0 ORDER BY id`, employeeId)
+}
+
+func (r *employeeSqliteRepository) GetEmployeesForServiceId(serviceId uint) ([]*repository.Employee, error) {
+	return r.queryEmployees(`SELECT e.id, e.name, e.description, e.retired FROM employees e
+		JOIN employee_services es ON es.employee_id = e.id
+		WHERE es.service_id = ? AND e.retired = 0 ORDER BY e.id`, serviceId)
+}
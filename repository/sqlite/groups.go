@@ -0,0 +1,11 @@
+package sqlite_repository
+
+import (
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsSqliteRepository) ReserveGroup(bookings []*repository.Booking) error {
+	return r.reserveTogether(bookings, repository.ValidateGroup, "attendee %d of the group", "group_id", func(booking *repository.Booking, id uint) {
+		booking.GroupID = id
+	})
+}
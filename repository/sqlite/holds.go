@@ -0,0 +1,170 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+const holdColumns = `id, session_id, expires_at, employee_id, service_id, location_id, starts_at, duration, buffer_before, buffer_after, deposit`
+
+// scanHold reads a hold selected with holdColumns, with its times in timezone.
+func scanHold(row interface{ Scan(...any) error }, timezone *time.Location) (*repository.Hold, error) {
+	var hold repository.Hold
+	var expiresAt, startsAt int64
+	err := row.Scan(&hold.ID, &hold.SessionID, &expiresAt, &hold.Booking.EmployeeID, &hold.Booking.ServiceID, &hold.Booking.LocationID, &startsAt,
+		&hold.Booking.Duration, &hold.Booking.BufferBefore, &hold.Booking.BufferAfter, &hold.Booking.Deposit)
+	if err != nil {
+		return nil, err
+	}
+	hold.ExpiresAt = time.Unix(expiresAt, 0).In(timezone)
+	hold.Booking.BookingDateTime = time.Unix(startsAt, 0).In(timezone)
+	return &hold, nil
+}
+
+func queryHolds(db *sql.DB, timezone *time.Location, query string, args ...any) ([]*repository.Hold, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var holds []*repository.Hold
+	for rows.Next() {
+		hold, err := scanHold(rows, timezone)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, hold := range holds {
+		if err := loadHoldResources(db, hold); err != nil {
+			return nil, err
+		}
+	}
+
+	return holds, nil
+}
+
+func loadHoldResources(q queryer, hold *repository.Hold) error {
+	var err error
+	hold.Booking.ResourcesIds, err = queryIds(q, `SELECT resource_id FROM hold_resources WHERE hold_id = ? ORDER BY resource_id`, hold.ID)
+	return err
+}
+
+func (r *bookingsSqliteRepository) HoldSlot(hold *repository.Hold) error {
+	if err := r.prepareBooking(&hold.Booking); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM holds WHERE expires_at <= ?`, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	if err := checkConflicts(tx, &hold.Booking, hold.SessionID, r.timezone); err != nil {
+		return err
+	}
+
+	var id any
+	if hold.ID != 0 {
+		id = hold.ID
+	}
+
+	booking := &hold.Booking
+	result, err := tx.Exec(`INSERT INTO holds (`+holdColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, hold.SessionID, hold.ExpiresAt.Unix(), booking.EmployeeID, booking.ServiceID, booking.LocationID, booking.BookingDateTime.Unix(),
+		booking.Duration, booking.BufferBefore, booking.BufferAfter, booking.Deposit)
+	if err != nil {
+		return err
+	}
+
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, resourceId := range booking.ResourcesIds {
+		_, err := tx.Exec(`INSERT INTO hold_resources (hold_id, resource_id) VALUES (?, ?)`, insertedId, resourceId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	hold.ID = uint(insertedId)
+
+	return nil
+}
+
+func (r *bookingsSqliteRepository) GetOverlappingHolds(employeeId uint, start time.Time, end time.Time) ([]*repository.Hold, error) {
+	return queryHolds(r.db, r.timezone, `SELECT `+holdColumns+` FROM holds WHERE expires_at > ? AND `+overlapsCondition+` ORDER BY starts_at`,
+		time.Now().Unix(), employeeId, end.Unix(), start.Unix())
+}
+
+func (r *bookingsSqliteRepository) GetSessionHolds(sessionId string) ([]*repository.Hold, error) {
+	return queryHolds(r.db, r.timezone, `SELECT `+holdColumns+` FROM holds WHERE session_id = ? AND expires_at > ? ORDER BY id`,
+		sessionId, time.Now().Unix())
+}
+
+func (r *bookingsSqliteRepository) ReleaseSessionHolds(sessionId string) error {
+	_, err := r.db.Exec(`DELETE FROM holds WHERE session_id = ?`, sessionId)
+	return err
+}
+
+func (r *bookingsSqliteRepository) ConfirmHold(holdId uint, customerName string, customerPhone string) (*repository.Booking, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	hold, err := scanHold(tx.QueryRow(`SELECT `+holdColumns+` FROM holds WHERE id = ? AND expires_at > ?`,
+		holdId, time.Now().Unix()), r.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadHoldResources(tx, hold); err != nil {
+		return nil, err
+	}
+
+	booking := hold.Booking
+	booking.CustomerName = customerName
+	booking.CustomerPhone = customerPhone
+	if err := booking.InitStatus(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := checkConflicts(tx, &booking, hold.SessionID, r.timezone); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM holds WHERE id = ?`, holdId); err != nil {
+		return nil, err
+	}
+	if err := insertBooking(tx, &booking); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &booking, nil
+}
@@ -0,0 +1,143 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type locationsSqliteRepository struct {
+	db *sql.DB
+}
+
+// NewLocationsSqliteRepository returns a LocationRepository backed by db. The
+// locations are stored by the CatalogRepository.
+func NewLocationsSqliteRepository(db *sql.DB) repository.LocationRepository {
+	return &locationsSqliteRepository{db: db}
+}
+
+// locationTimezone is repository.LocationTimezone on q, so it can be part of a
+// transaction.
+func locationTimezone(q queryer, locationId uint, timezone *time.Location) (*time.Location, error) {
+	if locationId == 0 {
+		return timezone, nil
+	}
+
+	location := repository.Location{ID: locationId}
+	err := q.QueryRow(`SELECT timezone FROM locations WHERE id = ?`, locationId).Scan(&location.Timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("location not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return location.TimezoneOr(timezone)
+}
+
+// upsertLocations stores the locations in data with their opening hours, as
+// active.
+func upsertLocations(tx *sql.Tx, data map[uint]*repository.Location) error {
+	for _, location := range data {
+		_, err := tx.Exec(`INSERT INTO locations (id, name, address, timezone) VALUES (?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET name = excluded.name, address = excluded.address, timezone = excluded.timezone, retired = 0`,
+			location.ID, location.Name, location.Address, location.Timezone)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM location_opening_hours WHERE location_id = ?`, location.ID); err != nil {
+			return err
+		}
+		for weekday, intervals := range location.OpeningHours {
+			for _, interval := range intervals {
+				_, err := tx.Exec(`INSERT INTO location_opening_hours (location_id, weekday, start_time, end_time) VALUES (?, ?, ?, ?)`,
+					location.ID, weekday, interval.Start, interval.End)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *locationsSqliteRepository) queryLocations(query string, args ...any) ([]*repository.Location, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []*repository.Location
+	for rows.Next() {
+		var location repository.Location
+		if err := rows.Scan(&location.ID, &location.Name, &location.Address, &location.Timezone, &location.Retired); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		locations = append(locations, &location)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The opening hours are loaded after the location rows are closed, the
+	// database only has a single connection.
+	for _, location := range locations {
+		if err := r.loadOpeningHours(location); err != nil {
+			return nil, err
+		}
+	}
+
+	return locations, nil
+}
+
+func (r *locationsSqliteRepository) loadOpeningHours(location *repository.Location) error {
+	rows, err := r.db.Query(`SELECT weekday, start_time, end_time FROM location_opening_hours
+		WHERE location_id = ? ORDER BY weekday, start_time`, location.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	location.OpeningHours = nil
+	for rows.Next() {
+		var weekday time.Weekday
+		var interval repository.WorkingHours
+		if err := rows.Scan(&weekday, &interval.Start, &interval.End); err != nil {
+			return err
+		}
+		if location.OpeningHours == nil {
+			location.OpeningHours = repository.WeeklySchedule{}
+		}
+		location.OpeningHours[weekday] = append(location.OpeningHours[weekday], interval)
+	}
+
+	return rows.Err()
+}
+
+func (r *locationsSqliteRepository) GetLocations() ([]*repository.Location, error) {
+	return r.queryLocations(`SELECT id, name, address, timezone, retired FROM locations WHERE retired = 0 ORDER BY id`)
+}
+
+func (r *locationsSqliteRepository) getLocation(query string, args ...any) (*repository.Location, error) {
+	locations, err := r.queryLocations(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return nil, errors.New("location not found")
+	}
+	return locations[0], nil
+}
+
+func (r *locationsSqliteRepository) GetLocationById(id uint) (*repository.Location, error) {
+	return r.getLocation(`SELECT id, name, address, timezone, retired FROM locations WHERE id = ?`, id)
+}
+
+func (r *locationsSqliteRepository) GetLocationByName(name string) (*repository.Location, error) {
+	return r.getLocation(`SELECT id, name, address, timezone, retired FROM locations WHERE name = ? AND retired = 0`, name)
+}
@@ -0,0 +1,70 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsSqliteRepository) SetBookingPayment(id uint, paymentId string, link string, dueAt time.Time) error {
+	result, err := r.db.Exec(`UPDATE bookings SET payment_id = ?, payment_link = ?, payment_due_at = ? WHERE id = ?`,
+		paymentId, link, unixOrZero(dueAt), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repository.ErrBookingNotFound
+	}
+	return nil
+}
+
+func (r *bookingsSqliteRepository) GetBookingsByPaymentId(paymentId string) ([]*repository.Booking, error) {
+	bookings, err := queryBookings(r.db, r.timezone, `SELECT `+bookingColumns+` FROM bookings
+		WHERE payment_id = ? AND payment_id != '' ORDER BY starts_at, id`, paymentId)
+	if err != nil {
+		return nil, err
+	}
+	if len(bookings) == 0 {
+		return nil, repository.ErrPaymentNotFound
+	}
+
+	return bookings, nil
+}
+
+func (r *bookingsSqliteRepository) MarkBookingPaid(id uint, at time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	booking, err := scanBooking(tx.QueryRow(`SELECT `+bookingColumns+` FROM bookings WHERE id = ?`, id), r.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrBookingNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := updateStatus(tx, booking, repository.BookingConfirmed, at); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE bookings SET paid_at = ? WHERE id = ?`, at.Unix(), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *bookingsSqliteRepository) GetUnpaidBookings(dueBefore time.Time) ([]*repository.Booking, error) {
+	return queryBookings(r.db, r.timezone, `SELECT `+bookingColumns+` FROM bookings
+		WHERE status = 'pending' AND deposit > 0 AND paid_at = 0 AND payment_due_at != 0 AND payment_due_at < ?
+		ORDER BY starts_at, id`, dueBefore.Unix())
+}
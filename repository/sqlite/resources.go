@@ -0,0 +1,67 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type resourcesSqliteRepository struct {
+	db *sql.DB
+}
+
+// NewResourcesSqliteRepository returns a ResourceRepository backed by db. The
+// resources are stored by the CatalogRepository.
+func NewResourcesSqliteRepository(db *sql.DB) repository.ResourceRepository {
+	return &resourcesSqliteRepository{db: db}
+}
+
+// upsertResources stores the resources in data, as active.
+func upsertResources(tx *sql.Tx, data map[uint]*repository.Resource) error {
+	for _, resource := range data {
+		_, err := tx.Exec(`INSERT INTO resources (id, name, capacity) VALUES (?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET name = excluded.name, capacity = excluded.capacity, retired = 0`,
+			resource.ID, resource.Name, resource.Capacity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanResource(row interface{ Scan(...any) error }) (*repository.Resource, error) {
+	var resource repository.Resource
+	if err := row.Scan(&resource.ID, &resource.Name, &resource.Capacity, &resource.Retired); err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+func (r *resourcesSqliteRepository) GetResources() ([]*repository.Resource, error) {
+	rows, err := r.db.Query(`SELECT id, name, capacity, retired FROM resources WHERE retired = 0 ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resources []*repository.Resource
+	for rows.Next() {
+		resource, err := scanResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources, rows.Err()
+}
+
+func (r *resourcesSqliteRepository) GetResourceById(id uint) (*repository.Resource, error) {
+	resource, err := scanResource(r.db.QueryRow(`SELECT id, name, capacity, retired FROM resources WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("resource not found")
+	}
+	return resource, err
+}
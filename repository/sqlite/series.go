@@ -0,0 +1,148 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+func (r *bookingsSqliteRepository) ReserveSeries(series *repository.Series, bookings []*repository.Booking) error {
+	if len(bookings) == 0 {
+		return errors.New("series has no bookings")
+	}
+
+	var conflicts []*repository.OccurrenceConflict
+	var prepared []*repository.Booking
+	for _, booking := range bookings {
+		err := r.prepareBooking(booking)
+		if repository.IsOccurrenceConflict(err) {
+			conflicts = append(conflicts, &repository.OccurrenceConflict{Start: booking.BookingDateTime, Err: err})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		prepared = append(prepared, booking)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, booking := range prepared {
+		err := checkConflicts(tx, booking, "", r.timezone)
+		if repository.IsOccurrenceConflict(err) {
+			conflicts = append(conflicts, &repository.OccurrenceConflict{Start: booking.BookingDateTime, Err: err})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		return &repository.SeriesConflictError{Conflicts: conflicts}
+	}
+
+	var id any
+	if series.ID != 0 {
+		id = series.ID
+	}
+	result, err := tx.Exec(`INSERT INTO booking_series (id, repeat_interval, repeat_unit, repeat_count, repeat_until) VALUES (?, ?, ?, ?, ?)`,
+		id, series.Recurrence.Interval, series.Recurrence.Unit, series.Recurrence.Count, unixOrZero(series.Recurrence.Until))
+	if err != nil {
+		return err
+	}
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	seriesId := uint(insertedId)
+	bookingsIds := make([]uint, 0, len(bookings))
+	for _, booking := range bookings {
+		booking.SeriesID = seriesId
+		if err := insertBooking(tx, booking); err != nil {
+			return err
+		}
+		bookingsIds = append(bookingsIds, booking.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	series.ID = seriesId
+	series.BookingsIds = bookingsIds
+	return nil
+}
+
+func (r *bookingsSqliteRepository) GetSeries(id uint) (*repository.Series, error) {
+	var series repository.Series
+	var until int64
+	err := r.db.QueryRow(`SELECT id, repeat_interval, repeat_unit, repeat_count, repeat_until FROM booking_series WHERE id = ?`, id).
+		Scan(&series.ID, &series.Recurrence.Interval, &series.Recurrence.Unit, &series.Recurrence.Count, &until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrSeriesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if until != 0 {
+		series.Recurrence.Until = time.Unix(until, 0).In(r.timezone)
+	}
+
+	series.BookingsIds, err = queryIds(r.db, `SELECT id FROM bookings WHERE series_id = ? ORDER BY starts_at`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+func (r *bookingsSqliteRepository) CancelSeries(id uint) ([]*repository.Booking, error) {
+	if _, err := r.GetSeries(id); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	rows, err := tx.Query(`SELECT `+bookingColumns+` FROM bookings WHERE series_id = ? AND starts_at >= ? AND `+changeableCondition+`
+		ORDER BY starts_at`,
+		id, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	var cancelled []*repository.Booking
+	for rows.Next() {
+		booking, err := scanBooking(rows, r.timezone)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		cancelled = append(cancelled, booking)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, booking := range cancelled {
+		if err := loadBookingResources(tx, booking); err != nil {
+			return nil, err
+		}
+		if err := updateStatus(tx, booking, repository.BookingCancelled, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return cancelled, tx.Commit()
+}
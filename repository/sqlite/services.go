@@ -0,0 +1,142 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type servicesSqliteRepository struct {
+	db *sql.DB
+}
+
+// NewServicesSqliteRepository returns a ServiceRepository backed by db. The
+// services are stored by the CatalogRepository.
+func NewServicesSqliteRepository(db *sql.DB) repository.ServiceRepository {
+	return &servicesSqliteRepository{db: db}
+}
+
+// upsertServices stores the services in data with the resources they need and
+// the branches offering them, as active.
+func upsertServices(tx *sql.Tx, data map[uint]*repository.Service) error {
+	for _, service := range data {
+		_, err := tx.Exec(`INSERT INTO services (`+serviceColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET name = excluded.name, price = excluded.price, duration = excluded.duration,
+				buffer_before = excluded.buffer_before, buffer_after = excluded.buffer_after, recall_months = excluded.recall_months,
+				category = excluded.category, description = excluded.description, preparation = excluded.preparation,
+				aftercare = excluded.aftercare, min_age = excluded.min_age, max_age = excluded.max_age, deposit = excluded.deposit,
+				retired = 0`,
+			service.ID, service.Name, service.Price, service.Duration, service.BufferBefore, service.BufferAfter, service.RecallMonths,
+			service.Category, service.Description, service.Preparation, service.Aftercare, service.MinAge, service.MaxAge, service.Deposit, false)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM service_resources WHERE service_id = ?`, service.ID); err != nil {
+			return err
+		}
+		for _, resourceId := range service.ResourcesIds {
+			_, err := tx.Exec(`INSERT INTO service_resources (service_id, resource_id) VALUES (?, ?)`, service.ID, resourceId)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`DELETE FROM service_locations WHERE service_id = ?`, service.ID); err != nil {
+			return err
+		}
+		for _, locationId := range service.LocationsIds {
+			_, err := tx.Exec(`INSERT INTO service_locations (service_id, location_id) VALUES (?, ?)`, service.ID, locationId)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+const serviceColumns = `id, name, price, duration, buffer_before, buffer_after, recall_months,
+	category, description, preparation, aftercare, min_age, max_age, deposit, retired`
+
+func scanService(row interface{ Scan(...any) error }) (*repository.Service, error) {
+	var service repository.Service
+	err := row.Scan(&service.ID, &service.Name, &service.Price, &service.Duration, &service.BufferBefore, &service.BufferAfter, &service.RecallMonths,
+		&service.Category, &service.Description, &service.Preparation, &service.Aftercare, &service.MinAge, &service.MaxAge, &service.Deposit, &service.Retired)
+	if err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+// loadServiceLinks loads the resources needed by the service and the branches
+// offering it.
+func loadServiceLinks(q queryer, service *repository.Service) error {
+	var err error
+	service.ResourcesIds, err = queryIds(q, `SELECT resource_id FROM service_resources WHERE service_id = ? ORDER BY resource_id`, service.ID)
+	if err != nil {
+		return err
+	}
+
+	service.LocationsIds, err = queryIds(q, `SELECT location_id FROM service_locations WHERE service_id = ? ORDER BY location_id`, service.ID)
+	if len(service.LocationsIds) == 0 {
+		service.LocationsIds = nil // Offered at all the branches
+	}
+	return err
+}
+
+func (r *servicesSqliteRepository) GetServices() ([]*repository.Service, error) {
+	return queryServices(r.db, `SELECT `+serviceColumns+` FROM services WHERE retired = 0 ORDER BY id`)
+}
+
+func queryServices(db *sql.DB, query string, args ...any) ([]*repository.Service, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []*repository.Service{}
+	for rows.Next() {
+		service, err := scanService(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The resources and branches are loaded after the service rows are closed,
+	// the database only has a single connection.
+	for _, service := range services {
+		if err := loadServiceLinks(db, service); err != nil {
+			return nil, err
+		}
+	}
+
+	return services, nil
+}
+
+func (r *servicesSqliteRepository) getService(query string, args ...any) (*repository.Service, error) {
+	service, err := scanService(r.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("service not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return service, loadServiceLinks(r.db, service)
+}
+
+func (r *servicesSqliteRepository) GetServiceById(id uint) (*repository.Service, error) {
+	return r.getService(`SELECT `+serviceColumns+` FROM services WHERE id = ?`, id)
+}
+
+func (r *servicesSqliteRepository) GetServiceByName(name string) (*repository.Service, error) {
+	return r.getService(`SELECT `+serviceColumns+` FROM services WHERE name = ? AND retired = 0`, name)
+}
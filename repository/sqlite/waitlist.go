@@ -0,0 +1,114 @@
+package sqlite_repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+)
+
+type waitlistSqliteRepository struct {
+	db       *sql.DB
+	timezone *time.Location
+}
+
+func NewWaitlistSqliteRepository(db *sql.DB, timezone *time.Location) repository.WaitlistRepository {
+	return &waitlistSqliteRepository{db: db, timezone: timezone}
+}
+
+const waitlistColumns = `id, service_id, employee_id, location_id, from_at, to_at, customer_name, customer_phone, created_at,
+	status, offer_hold_id, offer_expires_at, booking_id`
+
+// scanWaitlistEntry reads an entry selected with waitlistColumns, with its times
+// in timezone.
+func scanWaitlistEntry(row interface{ Scan(...any) error }, timezone *time.Location) (*repository.WaitlistEntry, error) {
+	var entry repository.WaitlistEntry
+	var fromAt, toAt, createdAt, offerExpiresAt int64
+	err := row.Scan(&entry.ID, &entry.ServiceID, &entry.EmployeeID, &entry.LocationID, &fromAt, &toAt, &entry.CustomerName, &entry.CustomerPhone,
+		&createdAt, &entry.Status, &entry.OfferHoldID, &offerExpiresAt, &entry.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	entry.From = time.Unix(fromAt, 0).In(timezone)
+	entry.To = time.Unix(toAt, 0).In(timezone)
+	entry.CreatedAt = time.Unix(createdAt, 0).In(timezone)
+	entry.OfferExpiresAt = timeOrZero(offerExpiresAt, timezone)
+	return &entry, nil
+}
+
+func (r *waitlistSqliteRepository) AddEntry(entry *repository.WaitlistEntry) error {
+	var id any
+	if entry.ID != 0 {
+		id = entry.ID
+	}
+
+	result, err := r.db.Exec(`INSERT INTO waitlist (`+waitlistColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, entry.ServiceID, entry.EmployeeID, entry.LocationID, entry.From.Unix(), entry.To.Unix(), entry.CustomerName, entry.CustomerPhone,
+		entry.CreatedAt.Unix(), entry.Status, entry.OfferHoldID, unixOrZero(entry.OfferExpiresAt), entry.BookingID)
+	if err != nil {
+		return err
+	}
+
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = uint(insertedId)
+
+	return nil
+}
+
+func (r *waitlistSqliteRepository) GetEntryById(id uint) (*repository.WaitlistEntry, error) {
+	entry, err := scanWaitlistEntry(r.db.QueryRow(`SELECT `+waitlistColumns+` FROM waitlist WHERE id = ?`, id), r.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrWaitlistEntryNotFound
+	}
+	return entry, err
+}
+
+func (r *waitlistSqliteRepository) GetEntriesByStatus(status repository.WaitlistStatus) ([]*repository.WaitlistEntry, error) {
+	return r.queryEntries(`SELECT `+waitlistColumns+` FROM waitlist WHERE status = ? ORDER BY created_at, id`, status)
+}
+
+func (r *waitlistSqliteRepository) GetEntriesByCustomer(phone string) ([]*repository.WaitlistEntry, error) {
+	return r.queryEntries(`SELECT `+waitlistColumns+` FROM waitlist WHERE customer_phone = ? AND status IN (?, ?) ORDER BY created_at, id`,
+		repository.NormalizePhone(phone), repository.WaitlistWaiting, repository.WaitlistOffered)
+}
+
+func (r *waitlistSqliteRepository) queryEntries(query string, args ...any) ([]*repository.WaitlistEntry, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*repository.WaitlistEntry
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows, r.timezone)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (r *waitlistSqliteRepository) UpdateEntry(entry *repository.WaitlistEntry) error {
+	result, err := r.db.Exec(`UPDATE waitlist SET status = ?, offer_hold_id = ?, offer_expires_at = ?, booking_id = ? WHERE id = ?`,
+		entry.Status, entry.OfferHoldID, unixOrZero(entry.OfferExpiresAt), entry.BookingID, entry.ID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return repository.ErrWaitlistEntryNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type BookingStatus string
+
+const (
+	// BookingPending bookings take their slot but still need to be confirmed
+	BookingPending   BookingStatus = "pending"
+	BookingConfirmed BookingStatus = "confirmed"
+	BookingCheckedIn BookingStatus = "checked-in"
+	BookingCompleted BookingStatus = "completed"
+	BookingCancelled BookingStatus = "cancelled"
+	BookingNoShow    BookingStatus = "no-show"
+)
+
+// OccupyingStatuses are the statuses of the bookings that take their slot, the
+// other bookings are ignored by the availability checks.
+var OccupyingStatuses = []BookingStatus{BookingPending, BookingConfirmed, BookingCheckedIn, BookingCompleted}
+
+// bookingTransitions lists the statuses a booking can move to from each
+// status. Cancelled, completed and no-show bookings are final.
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingPending:   {BookingConfirmed, BookingCancelled},
+	BookingConfirmed: {BookingCheckedIn, BookingCancelled, BookingNoShow},
+	BookingCheckedIn: {BookingCompleted},
+}
+
+var ErrInvalidStatusTransition = errors.New("invalid booking status transition")
+
+// StatusTransitionError is returned when a booking can't move to a status. It
+// matches ErrInvalidStatusTransition.
+type StatusTransitionError struct {
+	BookingID uint
+	From      BookingStatus
+	To        BookingStatus
+	Reason    string
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("booking %d can't move from %s to %s: %s", e.BookingID, e.From, e.To, e.Reason)
+}
+
+func (e *StatusTransitionError) Is(target error) bool {
+	return target == ErrInvalidStatusTransition
+}
+
+// Occupies reports whether bookings with the status take their slot.
+func (s BookingStatus) Occupies() bool {
+	for _, status := range OccupyingStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that s is a known status.
+func (s BookingStatus) Validate() error {
+	switch s {
+	case BookingPending, BookingConfirmed, BookingCheckedIn, BookingCompleted, BookingCancelled, BookingNoShow:
+		return nil
+	}
+	return fmt.Errorf("invalid booking status %q", s)
+}
+
+// CanTransitionTo reports whether a booking with the status can move to next.
+func (s BookingStatus) CanTransitionTo(next BookingStatus) bool {
+	for _, status := range bookingTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// SetStatus moves the booking to status at the given time and records when it
+// happened. Bookings can only be marked completed or no-show once the
+// appointment has started. A *StatusTransitionError is returned if the move is
+// not allowed.
+func (b *Booking) SetStatus(status BookingStatus, at time.Time) error {
+	if err := status.Validate(); err != nil {
+		return err
+	}
+	if !b.Status.CanTransitionTo(status) {
+		return &StatusTransitionError{BookingID: b.ID, From: b.Status, To: status, Reason: "not allowed"}
+	}
+	if (status == BookingCompleted || status == BookingNoShow) && at.Before(b.BookingDateTime) {
+		return &StatusTransitionError{BookingID: b.ID, From: b.Status, To: status, Reason: "the appointment hasn't started yet"}
+	}
+
+	b.Status = status
+	switch status {
+	case BookingConfirmed:
+		b.ConfirmedAt = at
+	case BookingCheckedIn:
+		b.CheckedInAt = at
+	case BookingCompleted:
+		b.CompletedAt = at
+	case BookingCancelled:
+		b.CancelledAt = at
+	case BookingNoShow:
+		b.NoShowAt = at
+	}
+
+	return nil
+}
+
+// InitStatus sets the status of a new booking and its creation time. It is
+// confirmed unless it was set to pending or it has an unpaid deposit, see
+// AwaitingPayment.
+func (b *Booking) InitStatus(at time.Time) error {
+	switch {
+	case b.Deposit > 0 && b.PaidAt.IsZero():
+		if b.Status != "" && b.Status != BookingPending {
+			return fmt.Errorf("new bookings with an unpaid deposit can't be %s", b.Status)
+		}
+		b.Status = BookingPending
+	case b.Status == "":
+		b.Status = BookingConfirmed
+	case b.Status == BookingPending, b.Status == BookingConfirmed:
+	default:
+		return fmt.Errorf("new bookings can't be %s", b.Status)
+	}
+
+	b.CreatedAt = at
+	if b.Status == BookingConfirmed {
+		b.ConfirmedAt = at
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// timezones caches the time zones loaded by name, time.LoadLocation reads
+// them from disk every time.
+var timezones sync.Map
+
+// LoadTimezone returns the time zone with the IANA name, UTC for an empty
+// name, like time.LoadLocation.
+func LoadTimezone(name string) (*time.Location, error) {
+	if timezone, ok := timezones.Load(name); ok {
+		return timezone.(*time.Location), nil
+	}
+
+	timezone, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	timezones.Store(name, timezone)
+	return timezone, nil
+}
+
+// ParseLocalTime parses a date and time of day, e.g. "2006-01-02 15:04", in
+// the business time zone. Times skipped when the clocks move forward for
+// daylight saving time don't exist and are rejected; times repeated when the
+// clocks move back resolve to their first occurrence.
+func ParseLocalTime(layout string, value string, timezone *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(layout, value, timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// time.ParseInLocation normalizes a skipped time to an existing one, with
+	// a different wall clock
+	wall, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.Hour() != wall.Hour() || t.Minute() != wall.Minute() {
+		return time.Time{}, fmt.Errorf("%s doesn't exist in %s, the clocks move forward for daylight saving time", value, timezone)
+	}
+
+	return firstOccurrence(t), nil
+}
+
+// firstOccurrence returns the first time the wall clock of t is shown, an hour
+// before t when t is the second occurrence of a time repeated when the clocks
+// move back. time.Date and time.ParseInLocation may return either occurrence.
+func firstOccurrence(t time.Time) time.Time {
+	earlier := t.Add(-time.Hour)
+	if earlier.Hour() == t.Hour() && earlier.Minute() == t.Minute() {
+		return earlier
+	}
+	return t
+}
+
+// StartOfDay returns the midnight starting the day of t in the business time
+// zone.
+func StartOfDay(t time.Time, timezone *time.Location) time.Time {
+	y, m, d := t.In(timezone).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, timezone)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// dstYear is when the daylight saving time changes are tested, fixed so the
+// tests don't depend on the current date. FirstSlot starts from now, so it is
+// in the future.
+const dstYear = 2036
+
+// dstDays returns the days the clocks move forward and back in the European
+// Union in dstYear, the last Sundays of March and October. Both changes
+// happen at 01:00 UTC.
+func dstDays() (time.Time, time.Time) {
+	lastSunday := func(month time.Month) time.Time {
+		day := time.Date(dstYear, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	}
+	return lastSunday(time.March), lastSunday(time.October)
+}
+
+func bucharest(t *testing.T) *time.Location {
+	t.Helper()
+
+	timezone, err := time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		t.Fatalf("loading the time zone: %v", err)
+	}
+	return timezone
+}
+
+func TestParseLocalTimeAroundDST(t *testing.T) {
+	timezone := bucharest(t)
+	springForward, fallBack := dstDays()
+
+	tests := []struct {
+		day  time.Time
+		time string
+		// want is the offset from midnight UTC of the day, ignored when the
+		// time doesn't exist
+		want  time.Duration
+		valid bool
+	}{
+		{springForward, "02:59", 59 * time.Minute, true},
+		{springForward, "03:00", 0, false},
+		{springForward, "03:30", 0, false},
+		{springForward, "03:59", 0, false},
+		{springForward, "04:00", time.Hour, true},
+		{fallBack, "02:59", -time.Minute, true},
+		// The repeated times resolve to their first occurrence, in summer time
+		{fallBack, "03:00", 0, true},
+		{fallBack, "03:30", 30 * time.Minute, true},
+		{fallBack, "03:59", 59 * time.Minute, true},
+		{fallBack, "04:00", 2 * time.Hour, true},
+	}
+
+	for _, test := range tests {
+		value := test.day.Format("2006-01-02") + " " + test.time
+		got, err := ParseLocalTime("2006-01-02 15:04", value, timezone)
+		switch {
+		case !test.valid && err == nil:
+			t.Errorf("ParseLocalTime(%s) = %v, want an error", value, got)
+		case test.valid && err != nil:
+			t.Errorf("ParseLocalTime(%s): %v", value, err)
+		case test.valid && !got.Equal(test.day.Add(test.want)):
+			t.Errorf("ParseLocalTime(%s) = %v, want %v", value, got, test.day.Add(test.want).In(timezone))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+
+type WaitlistStatus string
+
+const (
+	// WaitlistWaiting entries wait for a matching slot to free up
+	WaitlistWaiting WaitlistStatus = "waiting"
+	// WaitlistOffered entries have a slot held for them until the offer
+	// expires
+	WaitlistOffered WaitlistStatus = "offered"
+	// WaitlistBooked entries claimed their offer
+	WaitlistBooked WaitlistStatus = "booked"
+	// WaitlistExpired entries didn't claim their offer in time, or no slot
+	// freed up during their window
+	WaitlistExpired   WaitlistStatus = "expired"
+	WaitlistCancelled WaitlistStatus = "cancelled"
+)
+
+// WaitlistEntry is a customer waiting for a slot for a service.
+type WaitlistEntry struct {
+	ID        uint
+	ServiceID uint
+	// EmployeeID and LocationID are 0 when any employee or branch will do
+	EmployeeID uint
+	LocationID uint
+	// The customer accepts any start time in [From, To)
+	From          time.Time
+	To            time.Time
+	CustomerName  string
+	CustomerPhone string
+	CreatedAt     time.Time
+	Status        WaitlistStatus
+	// The slot offered to the customer is held by OfferHoldID until
+	// OfferExpiresAt
+	OfferHoldID    uint
+	OfferExpiresAt time.Time
+	// BookingID is the booking made by claiming the offer
+	BookingID uint
+}
+
+type WaitlistRepository interface {
+	// AddEntry stores a new entry and sets its ID.
+	AddEntry(entry *WaitlistEntry) error
+	GetEntryById(id uint) (*WaitlistEntry, error)
+	// GetEntriesByStatus returns the entries with the status, in the order the
+	// customers joined the waitlist.
+	GetEntriesByStatus(status WaitlistStatus) ([]*WaitlistEntry, error)
+	// GetEntriesByCustomer returns the waiting and offered entries of the
+	// customer, in the order they joined the waitlist. The phone can be written
+	// in any format.
+	GetEntriesByCustomer(phone string) ([]*WaitlistEntry, error)
+	UpdateEntry(entry *WaitlistEntry) error
+}
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"crypto/subtle"
+	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"valighita/bookings-ai-agent/agent"
 
 	"github.com/go-chi/chi"
@@ -58,11 +62,15 @@ func handleWebSocket(agentFactory agent.AgentFactory) http.HandlerFunc {
 	}
 }
 
-func basicAuth(next http.Handler, username, password string) http.Handler {
+func basicAuth(next http.Handler, realm, username, password string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, pass, ok := r.BasicAuth()
-		if !ok || user != username || pass != password {
-			w.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
+		// Both are compared, in constant time, so the response time doesn't
+		// tell which one is wrong or how much of it is right
+		userMatches := subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1
+		passMatches := subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
+		if !ok || !userMatches || !passMatches {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -70,39 +78,153 @@ func basicAuth(next http.Handler, username, password string) http.Handler {
 	})
 }
 
-func RunHttpServer(agentFactory agent.AgentFactory) {
+// Tenant is a business hosted by the server, with its own agent (and so its
+// own repositories and prompt), branding and credentials.
+type Tenant struct {
+	// ID is used in the /t/{id}/ path prefix
+	ID string
+	// Name is shown in the chat page
+	Name string
+	// Hostnames route requests to the tenant without the path prefix
+	Hostnames []string
+	// Username and Password enable basic authentication when both are set
+	Username     string
+	Password     string
+	AgentFactory agent.AgentFactory
+	// PaymentCallback handles the calls of the payment provider at
+	// /payments/callback, without basic authentication. Optional.
+	PaymentCallback http.Handler
+}
+
+// tenantRouter serves the chat page and the web socket of a tenant, and the
+// payment callback if any.
+func tenantRouter(tenant *Tenant, index *template.Template) http.Handler {
+	router := chi.NewRouter()
+
+	// The payment provider doesn't know the tenant credentials
+	if tenant.PaymentCallback != nil {
+		router.Handle("/payments/callback", tenant.PaymentCallback)
+	}
+
+	router.Group(func(r chi.Router) {
+		if tenant.Username != "" && tenant.Password != "" {
+			r.Use(func(next http.Handler) http.Handler {
+				return basicAuth(next, tenant.ID, tenant.Username, tenant.Password)
+			})
+		}
+		chatRoutes(r, tenant, index)
+	})
+
+	return router
+}
+
+// chatRoutes adds the routes of the chat page and the web socket.
+func chatRoutes(r chi.Router, tenant *Tenant, index *template.Template) {
+	// Define WebSocket route
+	r.Get("/ws", handleWebSocket(tenant.AgentFactory))
+
+	// serve frontend/index.html on /, branded for the tenant
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := index.Execute(w, tenant); err != nil {
+			log.Println("Error rendering index:", err)
+		}
+	})
+	r.Get("/style.css", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "frontend/style.css")
+	})
+}
+
+// handleReload calls reload for the requests with the admin token as bearer
+// token. A rejected configuration is reported with 422, the current one keeps
+// serving.
+func handleReload(reload func() error, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := reload(); err != nil {
+			log.Println("Error reloading the configuration:", err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Write([]byte("reloaded\n"))
+	}
+}
+
+// RunHttpServer serves the tenants. Requests are routed to a tenant by the
+// /t/{id}/ path prefix or else by hostname; when there is a single tenant it
+// also gets the requests for unknown hostnames. When ADMIN_TOKEN is set,
+// POST /admin/reload calls reload.
+func RunHttpServer(tenants []*Tenant, reload func() error) {
 	port := os.Getenv("HTTP_SERVER_PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	username := os.Getenv("HTTP_SERVER_USERNAME")
-	password := os.Getenv("HTTP_SERVER_PASSWORD")
-	withAuth := username != "" && password != ""
+	index, err := template.ParseFiles("frontend/index.html")
+	if err != nil {
+		log.Fatalf("Error loading frontend: %v", err)
+	}
+
+	byID := make(map[string]http.Handler, len(tenants))
+	byHost := make(map[string]http.Handler)
+	var fallback http.Handler
+	for _, tenant := range tenants {
+		if _, ok := byID[tenant.ID]; ok {
+			log.Fatalf("Duplicate tenant %q", tenant.ID)
+		}
+		handler := tenantRouter(tenant, index)
+		byID[tenant.ID] = handler
+		for _, hostname := range tenant.Hostnames {
+			hostname = strings.ToLower(hostname)
+			if _, ok := byHost[hostname]; ok {
+				log.Fatalf("Hostname %s is used by more than one tenant", hostname)
+			}
+			byHost[hostname] = handler
+		}
+		if len(tenants) == 1 {
+			fallback = handler
+		}
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	if withAuth {
-		r.Use(func(next http.Handler) http.Handler {
-			return basicAuth(next, username, password)
-		})
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" && reload != nil {
+		r.Post("/admin/reload", handleReload(reload, token))
 	}
 
-	// Define WebSocket route
-	r.Get("/ws", handleWebSocket(agentFactory))
+	r.Mount("/t/{tenant}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := byID[chi.URLParam(r, "tenant")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
 
-	// serve frontend/index.html on /
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "frontend/index.html")
-	})
-	r.Get("/style.css", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "frontend/style.css")
-	})
+	r.Mount("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		handler, ok := byHost[strings.ToLower(host)]
+		if !ok {
+			handler = fallback
+		}
+		if handler == nil {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
 
-	log.Printf("Starting server on port %s\n", port)
-	err := http.ListenAndServe(":"+port, r)
+	log.Printf("Starting server on port %s with %d tenant(s)\n", port, len(tenants))
+	err = http.ListenAndServe(":"+port, r)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
@@ -0,0 +1,75 @@
+package waitlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Offer is a slot held for a waitlisted customer until they claim it.
+type Offer struct {
+	Entry         uint      `json:"waitlist"`
+	CustomerName  string    `json:"customerName"`
+	CustomerPhone string    `json:"customerPhone"`
+	Service       string    `json:"service"`
+	Employee      string    `json:"employee"`
+	Location      string    `json:"location,omitempty"`
+	Start         time.Time `json:"start"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
+
+// Notifier tells customers about the slots offered to them, e.g. by SMS. The
+// customer claims the offer by talking to the agent before it expires.
+type Notifier interface {
+	NotifyOffer(offer *Offer) error
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier returns a Notifier that only logs the offers, for
+// development.
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) NotifyOffer(offer *Offer) error {
+	log.Printf("Waitlist offer %d for %s (%s): %s with %s on %s, claim before %s\n",
+		offer.Entry, offer.CustomerName, offer.CustomerPhone, offer.Service, offer.Employee,
+		offer.Start.Format("2006-01-02 15:04"), offer.ExpiresAt.Format("2006-01-02 15:04"))
+	return nil
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a Notifier posting the offers as JSON to url,
+// e.g. to an SMS gateway.
+func NewWebhookNotifier(url string) Notifier {
+	return &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *webhookNotifier) NotifyOffer(offer *Offer) error {
+	body, err := json.Marshal(offer)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
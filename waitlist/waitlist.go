@@ -0,0 +1,311 @@
+package waitlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"valighita/bookings-ai-agent/repository"
+)
+
+// checkInterval is how often the waitlist is checked for slots freed up by
+// schedule changes or expired holds. Cancellations wake it up right away.
+const checkInterval = time.Minute
+
+var (
+	ErrNoOffer      = errors.New("no slot is offered for this waitlist entry")
+	ErrOfferExpired = errors.New("the offer has expired")
+)
+
+// Waitlist offers the slots that free up to the customers waiting for them,
+// first come first served. The offered slot is held for the customer until
+// they claim it or the claim time runs out, then it goes to the next one.
+type Waitlist struct {
+	// mu serializes the changes to the entries
+	mu           sync.Mutex
+	repositories *repository.Repositories
+	notifier     Notifier
+	claimTTL     time.Duration
+	wake         chan struct{}
+}
+
+func NewWaitlist(repositories *repository.Repositories, notifier Notifier, claimTTL time.Duration) *Waitlist {
+	return &Waitlist{
+		repositories: repositories,
+		notifier:     notifier,
+		claimTTL:     claimTTL,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// SessionID is the session holding the slot offered to an entry.
+func SessionID(entryId uint) string {
+	return fmt.Sprintf("waitlist-%d", entryId)
+}
+
+// Run checks the waitlist periodically and whenever it is woken up, until ctx
+// is done.
+func (w *Waitlist) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Process(); err != nil {
+			log.Printf("Error processing waitlist: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// Wake makes Run check the waitlist right away, e.g. after a cancellation.
+func (w *Waitlist) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default: // A check is already pending
+	}
+}
+
+// Join adds the customer to the waitlist and sets the entry ID.
+func (w *Waitlist) Join(entry *repository.WaitlistEntry) error {
+	if !entry.From.Before(entry.To) {
+		return errors.New("the waitlist window is empty")
+	}
+	if !entry.To.After(time.Now()) {
+		return errors.New("the waitlist window is in the past")
+	}
+	if _, err := w.repositories.Services.GetServiceById(entry.ServiceID); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entry.CustomerPhone = repository.NormalizePhone(entry.CustomerPhone)
+	entry.CreatedAt = time.Now()
+	entry.Status = repository.WaitlistWaiting
+	if err := w.repositories.Waitlist.AddEntry(entry); err != nil {
+		return err
+	}
+
+	w.Wake()
+	return nil
+}
+
+// getCustomerEntry returns the entry if it belongs to the customer with the
+// given phone number. The caller must hold the lock.
+func (w *Waitlist) getCustomerEntry(entryId uint, phone string) (*repository.WaitlistEntry, error) {
+	entry, err := w.repositories.Waitlist.GetEntryById(entryId)
+	if err != nil {
+		return nil, err
+	}
+	if !repository.SamePhone(entry.CustomerPhone, phone, w.repositories.PhoneCountry) {
+		return nil, repository.ErrWaitlistEntryNotFound
+	}
+	return entry, nil
+}
+
+// Leave removes the customer from the waitlist, releasing the slot offered to
+// them if any.
+func (w *Waitlist) Leave(entryId uint, phone string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entry, err := w.getCustomerEntry(entryId, phone)
+	if err != nil {
+		return err
+	}
+	if entry.Status != repository.WaitlistWaiting && entry.Status != repository.WaitlistOffered {
+		return fmt.Errorf("waitlist entry is %s", entry.Status)
+	}
+
+	if entry.Status == repository.WaitlistOffered {
+		if err := w.repositories.Bookings.ReleaseSessionHolds(SessionID(entry.ID)); err != nil {
+			return err
+		}
+		w.Wake() // Offer the slot to the next customer
+	}
+
+	entry.Status = repository.WaitlistCancelled
+	return w.repositories.Waitlist.UpdateEntry(entry)
+}
+
+// Claim books the slot offered to the entry. ErrOfferExpired is returned if
+// the claim time ran out.
+func (w *Waitlist) Claim(entryId uint, phone string) (*repository.Booking, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entry, err := w.getCustomerEntry(entryId, phone)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != repository.WaitlistOffered {
+		return nil, ErrNoOffer
+	}
+
+	booking, err := w.repositories.Bookings.ConfirmHold(entry.OfferHoldID, entry.CustomerName, entry.CustomerPhone)
+	if errors.Is(err, repository.ErrHoldNotFound) {
+		entry.Status = repository.WaitlistExpired
+		if err := w.repositories.Waitlist.UpdateEntry(entry); err != nil {
+			return nil, err
+		}
+		return nil, ErrOfferExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Status = repository.WaitlistBooked
+	entry.BookingID = booking.ID
+	return booking, w.repositories.Waitlist.UpdateEntry(entry)
+}
+
+// Process expires the unclaimed offers and the entries whose window has
+// passed, then offers the free slots to the waiting customers in the order
+// they joined.
+func (w *Waitlist) Process() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+
+	offered, err := w.repositories.Waitlist.GetEntriesByStatus(repository.WaitlistOffered)
+	if err != nil {
+		return err
+	}
+	for _, entry := range offered {
+		if entry.OfferExpiresAt.After(now) {
+			continue
+		}
+		// The hold expired on its own, the slot is free for the next customer
+		entry.Status = repository.WaitlistExpired
+		if err := w.repositories.Waitlist.UpdateEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	waiting, err := w.repositories.Waitlist.GetEntriesByStatus(repository.WaitlistWaiting)
+	if err != nil {
+		return err
+	}
+	for _, entry := range waiting {
+		if !entry.To.After(now) {
+			entry.Status = repository.WaitlistExpired
+			if err := w.repositories.Waitlist.UpdateEntry(entry); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := w.offerSlot(entry, now); err != nil {
+			log.Printf("Error offering a slot to waitlist entry %d: %v", entry.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// offerSlot holds the earliest free slot matching the entry, if any, and
+// notifies the customer. The caller must hold the lock.
+func (w *Waitlist) offerSlot(entry *repository.WaitlistEntry, now time.Time) error {
+	slots, err := w.repositories.Employees.FindAvailableSlots(entry.ServiceID, entry.LocationID, entry.From, entry.To, entry.EmployeeID, 1)
+	if err != nil || len(slots) == 0 {
+		return err
+	}
+	slot := slots[0]
+
+	hold := &repository.Hold{
+		SessionID: SessionID(entry.ID),
+		ExpiresAt: now.Add(w.claimTTL),
+		Booking: repository.Booking{
+			EmployeeID:      slot.EmployeeID,
+			ServiceID:       entry.ServiceID,
+			LocationID:      slot.LocationID,
+			BookingDateTime: slot.Start,
+		},
+	}
+	err = w.repositories.Bookings.HoldSlot(hold)
+	if errors.Is(err, repository.ErrSlotNotAvailable) {
+		return nil // Taken in the meantime, try again on the next check
+	}
+	if err != nil {
+		return err
+	}
+
+	entry.Status = repository.WaitlistOffered
+	entry.OfferHoldID = hold.ID
+	entry.OfferExpiresAt = hold.ExpiresAt
+	if err := w.repositories.Waitlist.UpdateEntry(entry); err != nil {
+		return err
+	}
+
+	offer, err := w.newOffer(entry, hold)
+	if err == nil {
+		err = w.notifier.NotifyOffer(offer)
+	}
+	if err != nil {
+		// The customer can't claim a slot they don't know about, give it to
+		// the next one and keep them waiting
+		return errors.Join(err, w.withdrawOffer(entry))
+	}
+	return nil
+}
+
+// withdrawOffer releases the slot held for the entry and puts it back to
+// waiting. The caller must hold the lock.
+func (w *Waitlist) withdrawOffer(entry *repository.WaitlistEntry) error {
+	if err := w.repositories.Bookings.ReleaseSessionHolds(SessionID(entry.ID)); err != nil {
+		return err
+	}
+
+	entry.Status = repository.WaitlistWaiting
+	entry.OfferHoldID = 0
+	entry.OfferExpiresAt = time.Time{}
+	return w.repositories.Waitlist.UpdateEntry(entry)
+}
+
+// newOffer describes the slot held for the entry to the customer, with the
+// times in the time zone of the branch.
+func (w *Waitlist) newOffer(entry *repository.WaitlistEntry, hold *repository.Hold) (*Offer, error) {
+	service, err := w.repositories.Services.GetServiceById(entry.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	employee, err := w.repositories.Employees.GetEmployeeById(hold.Booking.EmployeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := &Offer{
+		Entry:         entry.ID,
+		CustomerName:  entry.CustomerName,
+		CustomerPhone: entry.CustomerPhone,
+		Service:       service.Name,
+		Employee:      employee.Name,
+		Start:         hold.Booking.BookingDateTime.In(w.repositories.Timezone),
+		ExpiresAt:     hold.ExpiresAt.In(w.repositories.Timezone),
+	}
+	if hold.Booking.LocationID != 0 {
+		location, err := w.repositories.Locations.GetLocationById(hold.Booking.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		timezone, err := location.TimezoneOr(w.repositories.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		offer.Location = location.Name
+		offer.Start = offer.Start.In(timezone)
+		offer.ExpiresAt = offer.ExpiresAt.In(timezone)
+	}
+
+	return offer, nil
+}
@@ -0,0 +1,224 @@
+package waitlist
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"valighita/bookings-ai-agent/repository"
+	memory_repository "valighita/bookings-ai-agent/repository/memory"
+)
+
+// fakeNotifier records the offers, or fails with err.
+type fakeNotifier struct {
+	offers []*Offer
+	err    error
+}
+
+func (n *fakeNotifier) NotifyOffer(offer *Offer) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.offers = append(n.offers, offer)
+	return nil
+}
+
+// tomorrow is midnight UTC at the start of tomorrow.
+func tomorrow() time.Time {
+	return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+}
+
+// newTestWaitlist returns the waitlist of a business where Alice has a single
+// slot a day, a 30 minute Cleaning at 10:00.
+func newTestWaitlist(claimTTL time.Duration) (*Waitlist, *fakeNotifier) {
+	schedule := repository.WeeklySchedule{}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		schedule[weekday] = []repository.WorkingHours{{Start: "10:00", End: "10:30"}}
+	}
+
+	services := memory_repository.NewServicesMemoryRepository(map[uint]*repository.Service{
+		1: {ID: 1, Name: "Cleaning", Duration: 30},
+	})
+	calendar := memory_repository.NewCalendarMemoryRepository(repository.BusinessCalendar{})
+	resources := memory_repository.NewResourcesMemoryRepository(map[uint]*repository.Resource{})
+	locations := memory_repository.NewLocationsMemoryRepository(map[uint]*repository.Location{})
+	bookings := memory_repository.NewBookingsMemoryRepository(services, calendar, resources, locations, memory_repository.NewCustomersMemoryRepository(), time.UTC)
+	employees := memory_repository.NewEmployeeMemoryRepository(bookings, services, calendar, resources, locations, 15*time.Minute, time.UTC, map[uint]*repository.Employee{
+		1: {ID: 1, Name: "Alice", ServicesIds: []uint{1}, Schedule: schedule},
+	})
+
+	repositories := &repository.Repositories{
+		Bookings:  bookings,
+		Services:  services,
+		Employees: employees,
+		Calendar:  calendar,
+		Resources: resources,
+		Locations: locations,
+		Waitlist:  memory_repository.NewWaitlistMemoryRepository(),
+		Timezone:  time.UTC,
+	}
+	notifier := &fakeNotifier{}
+	return NewWaitlist(repositories, notifier, claimTTL), notifier
+}
+
+// join adds the customer to the waitlist for any time tomorrow.
+func join(t *testing.T, w *Waitlist, name string, phone string) *repository.WaitlistEntry {
+	t.Helper()
+
+	entry := &repository.WaitlistEntry{
+		ServiceID:     1,
+		From:          tomorrow(),
+		To:            tomorrow().AddDate(0, 0, 1),
+		CustomerName:  name,
+		CustomerPhone: phone,
+	}
+	if err := w.Join(entry); err != nil {
+		t.Fatalf("Join(%s): %v", name, err)
+	}
+	return entry
+}
+
+func process(t *testing.T, w *Waitlist) {
+	t.Helper()
+
+	if err := w.Process(); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+}
+
+// checkStatus checks the status of the entry and whether a slot is held for
+// it.
+func checkStatus(t *testing.T, w *Waitlist, entry *repository.WaitlistEntry, want repository.WaitlistStatus, held bool) {
+	t.Helper()
+
+	stored, err := w.repositories.Waitlist.GetEntryById(entry.ID)
+	if err != nil {
+		t.Fatalf("GetEntryById(%d): %v", entry.ID, err)
+	}
+	if stored.Status != want {
+		t.Errorf("%s is %s, want %s", stored.CustomerName, stored.Status, want)
+	}
+	holds, err := w.repositories.Bookings.GetSessionHolds(SessionID(entry.ID))
+	if err != nil {
+		t.Fatalf("GetSessionHolds: %v", err)
+	}
+	if len(holds) != 0 != held {
+		t.Errorf("%s has %d held slots, want held %v", stored.CustomerName, len(holds), held)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	w, notifier := newTestWaitlist(time.Hour)
+
+	// The slot is taken when the customers join
+	booking := &repository.Booking{EmployeeID: 1, ServiceID: 1, BookingDateTime: tomorrow().Add(10 * time.Hour), CustomerName: "Joe"}
+	if err := w.repositories.Bookings.ReserveBooking(booking); err != nil {
+		t.Fatalf("ReserveBooking: %v", err)
+	}
+	first := join(t, w, "Jane", "+40712345678")
+	second := join(t, w, "John", "+40712345679")
+	process(t, w)
+	if len(notifier.offers) != 0 {
+		t.Fatalf("%d offers without a free slot, want none", len(notifier.offers))
+	}
+
+	// The freed slot goes to the first customer who joined
+	if err := w.repositories.Bookings.CancelBooking(booking.ID); err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+	process(t, w)
+	checkStatus(t, w, first, repository.WaitlistOffered, true)
+	checkStatus(t, w, second, repository.WaitlistWaiting, false)
+	if len(notifier.offers) != 1 {
+		t.Fatalf("%d offers, want 1", len(notifier.offers))
+	}
+	if offer := notifier.offers[0]; offer.Entry != first.ID || !offer.Start.Equal(booking.BookingDateTime) {
+		t.Errorf("offered %d at %v, want %d at %v", offer.Entry, offer.Start, first.ID, booking.BookingDateTime)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	w, _ := newTestWaitlist(time.Hour)
+	entry := join(t, w, "Jane", "+40712345678")
+	if _, err := w.Claim(entry.ID, "+40712345678"); !errors.Is(err, ErrNoOffer) {
+		t.Errorf("Claim(before the offer) = %v, want ErrNoOffer", err)
+	}
+	process(t, w)
+
+	if _, err := w.Claim(entry.ID, "+40700000000"); !errors.Is(err, repository.ErrWaitlistEntryNotFound) {
+		t.Errorf("Claim(other phone) = %v, want ErrWaitlistEntryNotFound", err)
+	}
+	booking, err := w.Claim(entry.ID, "+40712345678")
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if want := tomorrow().Add(10 * time.Hour); !booking.BookingDateTime.Equal(want) || booking.CustomerName != "Jane" {
+		t.Errorf("booked %s at %v, want Jane at %v", booking.CustomerName, booking.BookingDateTime, want)
+	}
+	checkStatus(t, w, entry, repository.WaitlistBooked, false)
+
+	if _, err := w.Claim(entry.ID, "+40712345678"); !errors.Is(err, ErrNoOffer) {
+		t.Errorf("Claim(again) = %v, want ErrNoOffer", err)
+	}
+}
+
+func TestLeave(t *testing.T) {
+	w, _ := newTestWaitlist(time.Hour)
+	first := join(t, w, "Jane", "+40712345678")
+	second := join(t, w, "John", "+40712345679")
+	process(t, w)
+
+	// The slot offered to the first customer goes to the next one
+	if err := w.Leave(first.ID, "+40712345678"); err != nil {
+		t.Fatalf("Leave: %v", err)
+	}
+	checkStatus(t, w, first, repository.WaitlistCancelled, false)
+	process(t, w)
+	checkStatus(t, w, second, repository.WaitlistOffered, true)
+
+	if err := w.Leave(first.ID, "+40712345678"); err == nil {
+		t.Error("Leave(again) succeeded")
+	}
+}
+
+func TestOfferExpires(t *testing.T) {
+	const claimTTL = 50 * time.Millisecond
+	w, notifier := newTestWaitlist(claimTTL)
+	first := join(t, w, "Jane", "+40712345678")
+	second := join(t, w, "John", "+40712345679")
+	process(t, w)
+	checkStatus(t, w, first, repository.WaitlistOffered, true)
+	checkStatus(t, w, second, repository.WaitlistWaiting, false)
+
+	// The unclaimed offer expires and the slot is offered to the next one
+	time.Sleep(2 * claimTTL)
+	process(t, w)
+	checkStatus(t, w, first, repository.WaitlistExpired, false)
+	checkStatus(t, w, second, repository.WaitlistOffered, true)
+	if len(notifier.offers) != 2 || notifier.offers[1].Entry != second.ID {
+		t.Errorf("offers = %+v, want one for each customer", notifier.offers)
+	}
+
+	time.Sleep(2 * claimTTL)
+	if _, err := w.Claim(second.ID, "+40712345679"); !errors.Is(err, ErrOfferExpired) {
+		t.Errorf("Claim(expired) = %v, want ErrOfferExpired", err)
+	}
+	checkStatus(t, w, second, repository.WaitlistExpired, false)
+}
+
+func TestNotifyFailure(t *testing.T) {
+	w, notifier := newTestWaitlist(time.Hour)
+	notifier.err = errors.New("SMS gateway down")
+	first := join(t, w, "Jane", "+40712345678")
+	second := join(t, w, "John", "+40712345679")
+
+	// Nobody is told about the slot, so nobody keeps it
+	process(t, w)
+	checkStatus(t, w, first, repository.WaitlistWaiting, false)
+	checkStatus(t, w, second, repository.WaitlistWaiting, false)
+
+	notifier.err = nil
+	process(t, w)
+	checkStatus(t, w, first, repository.WaitlistOffered, true)
+	checkStatus(t, w, second, repository.WaitlistWaiting, false)
+}